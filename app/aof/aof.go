@@ -0,0 +1,236 @@
+package aof
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+var ErrRewriteInProgress = errors.New("ERR Background append only file rewriting already in progress")
+
+// Fsync is the policy deciding when the append only file is flushed to
+// disk.
+type Fsync string
+
+const (
+	// FsyncAlways flushes the file after every write command, so that no
+	// acknowledged write is lost.
+	FsyncAlways Fsync = "always"
+	// FsyncEverySec flushes the file once per second, so that at most about
+	// a second of writes is lost.
+	FsyncEverySec Fsync = "everysec"
+	// FsyncNo leaves flushing to the operating system.
+	FsyncNo Fsync = "no"
+)
+
+// ParseFsync parses the value of the appendfsync configuration directive.
+func ParseFsync(policy string) (Fsync, error) {
+	switch Fsync(policy) {
+	case FsyncAlways, FsyncEverySec, FsyncNo:
+		return Fsync(policy), nil
+	}
+	return "", fmt.Errorf("invalid appendfsync policy %q, expected always, everysec or no", policy)
+}
+
+// File is a multi-part append only file, logging the write commands applied
+// to the store in the same format they are propagated to replicas.
+type File struct {
+	mu       sync.Mutex
+	dir      string
+	filename string
+	manifest *manifest
+	// incr is the incremental file commands are appended to.
+	incr  *os.File
+	fsync Fsync
+	// selected_db is the database last selected in the incremental file, or
+	// -1 when the next command must select one.
+	selected_db int
+	// pending is set when writes were made since the last fsync.
+	pending bool
+
+	current_size int64
+	base_size    int64
+	rewriting    bool
+	// last_rewrite is when the last rewrite finished, successfully or not.
+	last_rewrite       time.Time
+	last_rewrite_error error
+}
+
+// Open opens the append only file named filename in the directory dir for
+// appending. If there is none yet, it is created with a base file holding
+// the current contents of store.
+func Open(dir string, filename string, fsync Fsync, store *core.Store) (*File, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("unable to create the append only directory: %w", err)
+	}
+
+	f := &File{dir: dir, filename: filename, fsync: fsync, selected_db: -1}
+	m, err := readManifest(f.path(manifestName(filename)))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		f.manifest = &manifest{}
+		base := baseName(filename, 1)
+		if err := writeFileAtomically(f.path(base), func(w io.Writer) error { return rdb.WriteAOFBase(w, store) }); err != nil {
+			return nil, fmt.Errorf("unable to write the append only base file: %w", err)
+		}
+		f.manifest.base = &manifestFile{name: base, seq: 1, file_type: fileTypeBase}
+		err = f.openIncr(1)
+	case err != nil:
+		return nil, err
+	case len(m.incrs) == 0:
+		f.manifest = m
+		err = f.openIncr(1)
+	default:
+		f.manifest = m
+		last := m.incrs[len(m.incrs)-1]
+		f.incr, err = os.OpenFile(f.path(last.name), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the append only file: %w", err)
+	}
+
+	f.current_size = f.filesSize(f.manifest.files())
+	f.base_size = f.current_size
+	return f, nil
+}
+
+func (f *File) path(name string) string {
+	return filepath.Join(f.dir, name)
+}
+
+// filesSize returns the total size of files.
+func (f *File) filesSize(files []manifestFile) int64 {
+	var size int64
+	for _, file := range files {
+		if info, err := os.Stat(f.path(file.name)); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// openIncr switches to a new incremental file with sequence number seq,
+// recording it in the manifest. The caller must hold f.mu unless the file is
+// not in use yet.
+func (f *File) openIncr(seq int64) error {
+	name := incrName(f.filename, seq)
+	incr, err := os.OpenFile(f.path(name), os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	m := &manifest{base: f.manifest.base, incrs: append([]manifestFile{}, f.manifest.incrs...)}
+	m.incrs = append(m.incrs, manifestFile{name: name, seq: seq, file_type: fileTypeIncremental})
+	if err := f.writeManifest(m); err != nil {
+		incr.Close()
+		os.Remove(incr.Name())
+		return err
+	}
+
+	if f.incr != nil {
+		f.pending = true
+		f.sync()
+		f.incr.Close()
+	}
+	f.manifest = m
+	f.incr = incr
+	// Every file is loaded from database 0.
+	f.selected_db = -1
+	return nil
+}
+
+func (f *File) writeManifest(m *manifest) error {
+	return writeFileAtomically(f.path(manifestName(f.filename)), m.write)
+}
+
+// Append writes a command applied to database db to the file, preceded by a
+// SELECT when the command applies to another database than the previous one.
+// Write errors are reported but do not fail the command, which was already
+// applied.
+func (f *File) Append(db int, call resp.Array) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data := []byte{}
+	if db != f.selected_db {
+		data = append(data, resp.StringsToArray([]string{"SELECT", strconv.Itoa(db)}).Encode()...)
+	}
+	data = append(data, call.Encode()...)
+
+	n, err := f.incr.Write(data)
+	f.current_size += int64(n)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing to the append only file: %v\n", err)
+		// Part of the SELECT may have been written, so select again.
+		f.selected_db = -1
+		return
+	}
+	f.selected_db = db
+	f.pending = true
+	if f.fsync == FsyncAlways {
+		f.sync()
+	}
+}
+
+// sync flushes the incremental file to disk. The caller must hold f.mu.
+func (f *File) sync() {
+	if !f.pending {
+		return
+	}
+	if err := f.incr.Sync(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error flushing the append only file to disk: %v\n", err)
+		return
+	}
+	f.pending = false
+}
+
+// RunFsync flushes the file to disk every second when the fsync policy is
+// everysec, until stop is closed.
+func (f *File) RunFsync(stop <-chan struct{}) {
+	if f.fsync != FsyncEverySec {
+		return
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			f.mu.Lock()
+			f.sync()
+			f.mu.Unlock()
+		}
+	}
+}
+
+// Stats reports the size of the file and the state of its rewrites.
+func (f *File) Stats() core.AppendOnlyLogStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return core.AppendOnlyLogStats{
+		CurrentSize:       f.current_size,
+		BaseSize:          f.base_size,
+		RewriteInProgress: f.rewriting,
+		LastRewriteError:  f.last_rewrite_error,
+	}
+}
+
+// Close flushes the file to disk and closes it.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pending = true
+	f.sync()
+	return f.incr.Close()
+}
@@ -0,0 +1,261 @@
+package aof
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+const testFilename = "appendonly.aof"
+
+func newStore() *core.Store {
+	store := new(core.Store)
+	store.Init()
+	return store
+}
+
+func writeCommands(t *testing.T, dir string) string {
+	t.Helper()
+	log, err := Open(dir, testFilename, FsyncAlways, newStore())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	log.Append(0, resp.StringsToArray([]string{"SET", "a", "1"}))
+	log.Append(3, resp.StringsToArray([]string{"RPUSH", "list", "x", "y"}))
+	log.Append(3, resp.StringsToArray([]string{"SET", "b", "binary\r\nvalue"}))
+	if err := log.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return filepath.Join(dir, incrName(testFilename, 1))
+}
+
+func dirEntries(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestAppendThenLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeCommands(t, dir)
+
+	manifest, _ := os.ReadFile(filepath.Join(dir, manifestName(testFilename)))
+	expected := "file appendonly.aof.1.base.rdb seq 1 type b\nfile appendonly.aof.1.incr.aof seq 1 type i\n"
+	if string(manifest) != expected {
+		t.Errorf("Expected: %q\nGot: %q", expected, manifest)
+	}
+
+	store := newStore()
+	loaded, err := Load(dir, testFilename, store, false)
+	if err != nil || !loaded {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if value, _ := store.Get("a"); value != resp.BulkString("1") {
+		t.Errorf("Expected: 1\nGot: %v", value)
+	}
+	if value, _ := store.DB(3).Get("b"); value != resp.BulkString("binary\r\nvalue") {
+		t.Errorf("Expected: %q\nGot: %v", "binary\r\nvalue", value)
+	}
+	if store.DB(3).TypeOfValue("list") != "list" || store.Len() != 1 {
+		t.Errorf("Expected the list to be in database 3 only")
+	}
+
+	if loaded, err := Load(t.TempDir(), testFilename, newStore(), false); loaded || err != nil {
+		t.Errorf("Expected nothing to be loaded from an empty directory, got %v, %v", loaded, err)
+	}
+}
+
+func TestLoadTruncated(t *testing.T) {
+	dir := t.TempDir()
+	incr := writeCommands(t, dir)
+	info, _ := os.Stat(incr)
+	if err := os.Truncate(incr, info.Size()-3); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := Load(dir, testFilename, newStore(), false); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("Expected: %v\nGot: %v", ErrTruncated, err)
+	}
+
+	store := newStore()
+	if _, err := Load(dir, testFilename, store, true); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := store.DB(3).Get("b"); ok {
+		t.Errorf("Expected the truncated command to be skipped")
+	}
+	if store.DB(3).TypeOfValue("list") != "list" {
+		t.Errorf("Expected the complete commands to be replayed")
+	}
+
+	// The incomplete command is cut off, so that appending resumes on a
+	// command boundary.
+	log, err := Open(dir, testFilename, FsyncNo, store)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	log.Append(0, resp.StringsToArray([]string{"SET", "c", "3"}))
+	log.Close()
+	store = newStore()
+	if _, err := Load(dir, testFilename, store, false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if value, _ := store.Get("c"); value != resp.BulkString("3") {
+		t.Errorf("Expected: 3\nGot: %v", value)
+	}
+}
+
+func TestLoadMalformed(t *testing.T) {
+	dir := t.TempDir()
+	incr := writeCommands(t, dir)
+	os.WriteFile(incr, []byte("*1\r\n$4\r\nPING\r\nGARBAGE\r\n"), 0644)
+
+	if _, err := Load(dir, testFilename, newStore(), true); err == nil || errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected a malformed file error, got %v", err)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	dir := t.TempDir()
+	store := newStore()
+	log, err := Open(dir, testFilename, FsyncNo, store)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer log.Close()
+	store.SetAppendOnlyLog(log)
+
+	store.Set("a", resp.BulkString("1"))
+	store.PropagateToReplicas(resp.StringsToArray([]string{"SET", "a", "1"}))
+	store.DB(2).Set("b", resp.BulkString("2"))
+	store.DB(2).PropagateToReplicas(resp.StringsToArray([]string{"SET", "b", "2"}))
+
+	store.Lock()
+	if err := log.Rewrite(store); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := log.Rewrite(store); err != ErrRewriteInProgress {
+		t.Errorf("Expected: %v\nGot: %v", ErrRewriteInProgress, err)
+	}
+	// Commands applied during the rewrite go to the new incremental file.
+	store.DB(2).Set("c", resp.BulkString("3"))
+	store.DB(2).PropagateToReplicas(resp.StringsToArray([]string{"SET", "c", "3"}))
+	store.Unlock()
+
+	for log.Stats().RewriteInProgress {
+		time.Sleep(time.Millisecond)
+	}
+	stats := log.Stats()
+	if stats.LastRewriteError != nil || stats.BaseSize != stats.CurrentSize {
+		t.Fatalf("Expected a successful rewrite\nGot: %+v", stats)
+	}
+
+	expected := []string{"appendonly.aof.2.base.rdb", "appendonly.aof.2.incr.aof", "appendonly.aof.manifest"}
+	if got := dirEntries(t, dir); !equalStrings(got, expected) {
+		t.Errorf("Expected: %v\nGot: %v", expected, got)
+	}
+
+	loaded := newStore()
+	if _, err := Load(dir, testFilename, loaded, false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, key := range []struct {
+		db    int
+		key   string
+		value string
+	}{{0, "a", "1"}, {2, "b", "2"}, {2, "c", "3"}} {
+		if value, _ := loaded.DB(key.db).Get(key.key); value != resp.BulkString(key.value) {
+			t.Errorf("Expected %s in db %d to be %s\nGot: %v", key.key, key.db, key.value, value)
+		}
+	}
+}
+
+func TestUpgradeLegacyFile(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, testFilename)
+	os.WriteFile(legacy, resp.StringsToArray([]string{"SET", "a", "1"}).Encode(), 0644)
+
+	aof_dir := filepath.Join(dir, "appendonlydir")
+	if err := UpgradeLegacyFile(legacy, aof_dir, testFilename); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(legacy); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the legacy file to be moved")
+	}
+
+	store := newStore()
+	if loaded, err := Load(aof_dir, testFilename, store, false); !loaded || err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if value, _ := store.Get("a"); value != resp.BulkString("1") {
+		t.Errorf("Expected: 1\nGot: %v", value)
+	}
+}
+
+func TestParseManifestLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected manifestFile
+		valid    bool
+	}{
+		{"file appendonly.aof.1.base.rdb seq 1 type b", manifestFile{"appendonly.aof.1.base.rdb", 1, "b"}, true},
+		{"type i seq 12 file appendonly.aof.12.incr.aof", manifestFile{"appendonly.aof.12.incr.aof", 12, "i"}, true},
+		{`file "my file.aof" seq 2 type i`, manifestFile{"my file.aof", 2, "i"}, true},
+		{"file appendonly.aof seq x type b", manifestFile{}, false},
+		{"file ../escape seq 1 type b", manifestFile{}, false},
+		{"file appendonly.aof seq 1", manifestFile{}, false},
+		{`file "unbalanced seq 1 type b`, manifestFile{}, false},
+	}
+
+	for _, test := range tests {
+		got, err := parseManifestLine(test.line)
+		if (err == nil) != test.valid || got != test.expected {
+			t.Errorf("%s\nExpected: %v\nGot: %v, %v", test.line, test.expected, got, err)
+		}
+	}
+}
+
+func TestShouldRewrite(t *testing.T) {
+	tests := []struct {
+		current  int64
+		base     int64
+		expected bool
+	}{
+		{100, 10, false},
+		{2000, 1500, false},
+		{2000, 1000, true},
+		{5000, 0, true},
+	}
+
+	for _, test := range tests {
+		if got := shouldRewrite(test.current, test.base, 100, 1000); got != test.expected {
+			t.Errorf("current %d, base %d\nExpected: %v\nGot: %v", test.current, test.base, test.expected, got)
+		}
+	}
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
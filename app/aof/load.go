@@ -0,0 +1,199 @@
+package aof
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/codecrafters-io/redis-starter-go/app/commands"
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// ErrTruncated is returned when the last part of the append only file ends in the middle of a
+// command and truncated files are not accepted.
+var ErrTruncated = errors.New("unexpected end of the append only file")
+
+// errMalformed is returned when the append only file is not a sequence of
+// commands.
+var errMalformed = errors.New("malformed append only file")
+
+// maxBulkLength is the longest argument accepted, as a guard against
+// allocating huge buffers for a corrupted length.
+const maxBulkLength = 512 << 20
+
+// UpgradeLegacyFile turns the single append only file legacy, as written
+// before append only files were split into parts, into the base file of the
+// append only file named filename in the directory dir. Nothing is done if
+// there is no such file, or if dir already holds an append only file.
+func UpgradeLegacyFile(legacy string, dir string, filename string) error {
+	manifest_path := filepath.Join(dir, manifestName(filename))
+	if _, err := os.Stat(manifest_path); err == nil {
+		return nil
+	}
+	if _, err := os.Stat(legacy); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("unable to create the append only directory: %w", err)
+	}
+	if err := os.Rename(legacy, filepath.Join(dir, filename)); err != nil {
+		return fmt.Errorf("unable to move the append only file: %w", err)
+	}
+	m := &manifest{base: &manifestFile{name: filename, seq: 1, file_type: fileTypeBase}}
+	if err := writeFileAtomically(manifest_path, m.write); err != nil {
+		return fmt.Errorf("unable to write the append only manifest: %w", err)
+	}
+	return nil
+}
+
+// Load loads the append only file named filename in the directory dir into
+// store, reporting whether there is one. The base file is loaded first,
+// then the commands of the incremental files are replayed in order. When the
+// last incremental file ends in the middle of a command, which happens if
+// the server stopped while writing it, the incomplete command is cut off if
+// truncated_ok is set, and ErrTruncated is returned otherwise.
+func Load(dir string, filename string, store *core.Store, truncated_ok bool) (bool, error) {
+	m, err := readManifest(filepath.Join(dir, manifestName(filename)))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return true, err
+	}
+
+	files := m.files()
+	for i, file := range files {
+		last := i == len(files)-1
+		if err := loadFile(filepath.Join(dir, file.name), store, truncated_ok && last); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// loadFile loads a part of the append only file, which holds either a
+// snapshot in the RDB format or commands.
+func loadFile(filename string, store *core.Store, truncated_ok bool) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("unable to open the append only file: %w", err)
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	if header, _ := r.Peek(5); string(header) == "REDIS" {
+		return rdb.Read(r, store)
+	}
+
+	valid, err := replay(r, store)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		if !truncated_ok {
+			return fmt.Errorf("%w %s at offset %d, set --aof-load-truncated to yes to load it anyway", ErrTruncated, filename, valid)
+		}
+		fmt.Fprintf(os.Stderr, "The append only file %s is truncated, cutting it off at offset %d\n", filename, valid)
+		if err := os.Truncate(filename, valid); err != nil {
+			return fmt.Errorf("unable to truncate the append only file: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w %s at offset %d", err, filename, valid)
+	}
+	return nil
+}
+
+// replay applies the commands read from r to store, returning the offset
+// after the last complete command.
+func replay(r *bufio.Reader, store *core.Store) (int64, error) {
+	// The commands are replayed as if sent by a client, which also keeps
+	// track of the selected database.
+	conn := core.NewConn(nil, core.ConnRelationTypeEnum.NORMAL)
+	var valid int64
+
+	store.Lock()
+	defer store.Unlock()
+	for {
+		call, n, err := readCommand(r)
+		if err == io.EOF {
+			return valid, nil
+		}
+		if err != nil {
+			return valid, err
+		}
+
+		commands.HandleCommand(call, conn, store)
+		valid += n
+	}
+}
+
+// readCommand reads a command encoded as an array of bulk strings, returning
+// it along with the number of bytes read. It returns io.EOF at the end of r,
+// and io.ErrUnexpectedEOF if r ends in the middle of the command.
+func readCommand(r *bufio.Reader) (resp.Array, int64, error) {
+	if _, err := r.Peek(1); err == io.EOF {
+		return nil, 0, io.EOF
+	}
+
+	count, n, err := readPrefixedInt(r, '*')
+	if err != nil {
+		return nil, 0, err
+	}
+	if count < 1 {
+		return nil, 0, errMalformed
+	}
+
+	// The arguments are appended as they are read, so that a corrupted
+	// count does not allocate a huge array.
+	call := resp.Array{}
+	for i := int64(0); i < count; i++ {
+		length, nn, err := readPrefixedInt(r, '$')
+		if err != nil {
+			return nil, 0, err
+		}
+		n += nn
+		if length < 0 || length > maxBulkLength {
+			return nil, 0, errMalformed
+		}
+
+		buf := make([]byte, length+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, 0, unexpectedEOF(err)
+		}
+		if buf[length] != '\r' || buf[length+1] != '\n' {
+			return nil, 0, errMalformed
+		}
+		n += length + 2
+		call = append(call, resp.BulkString(buf[:length]))
+	}
+	return call, n, nil
+}
+
+// readPrefixedInt reads a line made of prefix and an integer.
+func readPrefixedInt(r *bufio.Reader, prefix byte) (int64, int64, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return 0, 0, unexpectedEOF(err)
+	}
+	if len(line) < 4 || line[0] != prefix || line[len(line)-2] != '\r' {
+		return 0, 0, errMalformed
+	}
+	value, err := strconv.ParseInt(line[1:len(line)-2], 10, 64)
+	if err != nil {
+		return 0, 0, errMalformed
+	}
+	return value, int64(len(line)), nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
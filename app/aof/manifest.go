@@ -0,0 +1,225 @@
+package aof
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The append only file is split into parts, as in Redis 7, tracked by a
+// manifest in the append only directory:
+//
+//   - the base file, a snapshot of the data when the log was last
+//     rewritten, in the RDB format,
+//   - the incremental files, logging the write commands made since, in the
+//     order they were opened.
+//
+// Rewriting the log opens a new incremental file and writes a new base file
+// from a snapshot taken at the same time, after which the previous base and
+// incremental files are no longer needed.
+
+const (
+	fileTypeBase        = "b"
+	fileTypeIncremental = "i"
+)
+
+type manifestFile struct {
+	name      string
+	seq       int64
+	file_type string
+}
+
+type manifest struct {
+	base  *manifestFile
+	incrs []manifestFile
+}
+
+func manifestName(filename string) string {
+	return filename + ".manifest"
+}
+
+func baseName(filename string, seq int64) string {
+	return fmt.Sprintf("%s.%d.base.rdb", filename, seq)
+}
+
+func incrName(filename string, seq int64) string {
+	return fmt.Sprintf("%s.%d.incr.aof", filename, seq)
+}
+
+// lastIncrSeq returns the sequence number of the last incremental file, or 0
+// if there is none.
+func (m *manifest) lastIncrSeq() int64 {
+	if len(m.incrs) == 0 {
+		return 0
+	}
+	return m.incrs[len(m.incrs)-1].seq
+}
+
+// baseSeq returns the sequence number of the base file, or 0 if there is
+// none.
+func (m *manifest) baseSeq() int64 {
+	if m.base == nil {
+		return 0
+	}
+	return m.base.seq
+}
+
+// files returns the files to load, in order.
+func (m *manifest) files() []manifestFile {
+	files := []manifestFile{}
+	if m.base != nil {
+		files = append(files, *m.base)
+	}
+	return append(files, m.incrs...)
+}
+
+// readManifest reads the manifest at path. It returns an error satisfying
+// errors.Is(err, os.ErrNotExist) if there is none.
+func readManifest(path string) (*manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &manifest{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line_number := 1; scanner.Scan(); line_number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		file, err := parseManifestLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AOF manifest %s, line %d: %w", path, line_number, err)
+		}
+		switch file.file_type {
+		case fileTypeBase:
+			if m.base != nil {
+				return nil, fmt.Errorf("invalid AOF manifest %s, line %d: more than one base file", path, line_number)
+			}
+			m.base = &file
+		case fileTypeIncremental:
+			if file.seq <= m.lastIncrSeq() {
+				return nil, fmt.Errorf("invalid AOF manifest %s, line %d: incremental files out of order", path, line_number)
+			}
+			m.incrs = append(m.incrs, file)
+		default:
+			// Other types, such as the history files Redis keeps around until
+			// it deletes them, are not loaded.
+		}
+	}
+	if m.base == nil && len(m.incrs) == 0 {
+		return nil, fmt.Errorf("invalid AOF manifest %s: no files", path)
+	}
+	return m, nil
+}
+
+// parseManifestLine parses a line of the form "file <name> seq <seq> type
+// <type>", in which the keys may come in any order.
+func parseManifestLine(line string) (manifestFile, error) {
+	fields, err := splitManifestLine(line)
+	if err != nil {
+		return manifestFile{}, err
+	}
+	if len(fields)%2 != 0 {
+		return manifestFile{}, errors.New("expected key and value pairs")
+	}
+
+	file := manifestFile{}
+	for i := 0; i < len(fields); i += 2 {
+		switch fields[i] {
+		case "file":
+			file.name = fields[i+1]
+		case "seq":
+			file.seq, err = strconv.ParseInt(fields[i+1], 10, 64)
+			if err != nil {
+				return manifestFile{}, fmt.Errorf("invalid sequence number %q", fields[i+1])
+			}
+		case "type":
+			file.file_type = fields[i+1]
+		}
+	}
+	if file.name == "" || file.file_type == "" {
+		return manifestFile{}, errors.New("missing file name or type")
+	}
+	// File names must not point outside of the append only directory.
+	if filepath.Base(file.name) != file.name {
+		return manifestFile{}, fmt.Errorf("invalid file name %q", file.name)
+	}
+	return file, nil
+}
+
+// splitManifestLine splits line on spaces, unquoting quoted fields.
+func splitManifestLine(line string) ([]string, error) {
+	fields := []string{}
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if line == "" {
+			return fields, nil
+		}
+		if line[0] == '"' {
+			quoted, err := strconv.QuotedPrefix(line)
+			if err != nil {
+				return nil, errors.New("unbalanced quotes")
+			}
+			field, _ := strconv.Unquote(quoted)
+			fields = append(fields, field)
+			line = line[len(quoted):]
+			continue
+		}
+		end := strings.IndexAny(line, " \t")
+		if end == -1 {
+			end = len(line)
+		}
+		fields = append(fields, line[:end])
+		line = line[end:]
+	}
+}
+
+// write writes the manifest to w.
+func (m *manifest) write(w io.Writer) error {
+	var buf bytes.Buffer
+	for _, file := range m.files() {
+		name := file.name
+		if strings.ContainsAny(name, " \t\"\\") || !strconv.CanBackquote(name) {
+			name = strconv.Quote(name)
+		}
+		fmt.Fprintf(&buf, "file %s seq %d type %s\n", name, file.seq, file.file_type)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeFileAtomically writes a file through write. The data is written to a
+// temporary file in the same directory, which then replaces filename, so
+// that a failure never leaves a partial file behind.
+func writeFileAtomically(filename string, write func(io.Writer) error) error {
+	file, err := os.CreateTemp(filepath.Dir(filename), "temp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	w := bufio.NewWriter(file)
+	err = write(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if close_err := file.Close(); err == nil {
+		err = close_err
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filename)
+}
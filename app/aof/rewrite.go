@@ -0,0 +1,132 @@
+package aof
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+)
+
+// autoRewriteCheckInterval is how often the size of the file is checked
+// against the automatic rewrite thresholds.
+const autoRewriteCheckInterval = 100 * time.Millisecond
+
+// rewriteRetryDelay is how long to wait before retrying a failed automatic
+// rewrite.
+const rewriteRetryDelay = 5 * time.Second
+
+// Rewrite compacts the file in the background. Commands are appended to a
+// new incremental file from now on, while a new base file is written from a
+// snapshot of store taken at the same time. Once the base file is written,
+// the manifest is updated and the previous files are deleted. The caller
+// must hold the store lock, so that no command is applied in between.
+func (f *File) Rewrite(store *core.Store) error {
+	f.mu.Lock()
+	if f.rewriting {
+		f.mu.Unlock()
+		return ErrRewriteInProgress
+	}
+	incr_seq := f.manifest.lastIncrSeq() + 1
+	base_seq := f.manifest.baseSeq() + 1
+	if err := f.openIncr(incr_seq); err != nil {
+		f.last_rewrite = time.Now()
+		f.last_rewrite_error = err
+		f.mu.Unlock()
+		return fmt.Errorf("ERR unable to open a new append only file: %v", err)
+	}
+	f.rewriting = true
+	f.mu.Unlock()
+
+	snapshot := store.Snapshot()
+	go func() {
+		err := f.finishRewrite(snapshot, base_seq, incr_seq)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Background append only file rewriting failed: %v\n", err)
+		}
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.rewriting = false
+		f.last_rewrite = time.Now()
+		f.last_rewrite_error = err
+	}()
+	return nil
+}
+
+// finishRewrite writes the base file base_seq from snapshot, then replaces
+// the files the base file covers, those before the incremental file
+// incr_seq.
+func (f *File) finishRewrite(snapshot *core.Store, base_seq int64, incr_seq int64) error {
+	base := baseName(f.filename, base_seq)
+	err := writeFileAtomically(f.path(base), func(w io.Writer) error { return rdb.WriteAOFBase(w, snapshot) })
+	if err != nil {
+		return fmt.Errorf("unable to write the append only base file: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m := &manifest{base: &manifestFile{name: base, seq: base_seq, file_type: fileTypeBase}}
+	for _, incr := range f.manifest.incrs {
+		if incr.seq >= incr_seq {
+			m.incrs = append(m.incrs, incr)
+		}
+	}
+	if err := f.writeManifest(m); err != nil {
+		os.Remove(f.path(base))
+		return fmt.Errorf("unable to write the append only manifest: %w", err)
+	}
+
+	previous := f.manifest
+	f.manifest = m
+	for _, file := range previous.files() {
+		if file.file_type == fileTypeBase || file.seq < incr_seq {
+			os.Remove(f.path(file.name))
+		}
+	}
+
+	f.current_size = f.filesSize(m.files())
+	f.base_size = f.current_size
+	return nil
+}
+
+// RunAutoRewrite rewrites the file whenever it grew by percentage percent
+// since it was last rewritten, as long as it is at least min_size bytes. A
+// percentage of 0 disables automatic rewrites.
+func RunAutoRewrite(store *core.Store, f *File, percentage int64, min_size int64, stop <-chan struct{}) {
+	if percentage == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(autoRewriteCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			store.Lock()
+			f.mu.Lock()
+			rewrite := shouldRewrite(f.current_size, f.base_size, percentage, min_size) &&
+				!f.rewriting && (f.last_rewrite_error == nil || time.Since(f.last_rewrite) >= rewriteRetryDelay)
+			f.mu.Unlock()
+			if rewrite {
+				f.Rewrite(store)
+			}
+			store.Unlock()
+		}
+	}
+}
+
+func shouldRewrite(current_size int64, base_size int64, percentage int64, min_size int64) bool {
+	if current_size < min_size {
+		return false
+	}
+	base := base_size
+	if base == 0 {
+		base = 1
+	}
+	return (current_size-base_size)*100/base >= percentage
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+)
+
+// check reads a whole RDB file and reports what it holds or where it is
+// invalid, in the style of redis-check-rdb, returning the exit status.
+func check(filename string, w io.Writer) int {
+	fmt.Fprintf(w, "[offset 0] Checking RDB file %s\n", filename)
+	file, err := os.Open(filename)
+	if err != nil {
+		fmt.Fprintf(w, "--- RDB ERROR DETECTED ---\n[additional info] %v\n", err)
+		return 1
+	}
+	defer file.Close()
+
+	now := time.Now().UnixMilli()
+	keys, expires, expired := 0, 0, 0
+	var last rdb.Entry
+	scanner := rdb.Scanner{
+		VerifyChecksum: true,
+		Aux: func(key string, value string) {
+			fmt.Fprintf(w, "[info] AUX FIELD %s = '%s'\n", key, value)
+		},
+		Skipped: func(reason string) {
+			fmt.Fprintf(w, "[info] Skipping %s\n", reason)
+		},
+		Key: func(entry rdb.Entry) error {
+			keys++
+			if entry.HasExpiry {
+				expires++
+				if entry.Expiry < now {
+					expired++
+				}
+			}
+			last = entry
+			return nil
+		},
+	}
+	err = scanner.Scan(file)
+	if scanner.Version != 0 {
+		fmt.Fprintf(w, "[info] RDB version %d\n", scanner.Version)
+	}
+
+	if err != nil {
+		fmt.Fprintf(w, "--- RDB ERROR DETECTED ---\n")
+		var format_err *rdb.FormatError
+		if errors.As(err, &format_err) {
+			fmt.Fprintf(w, "[offset %d] %v\n", format_err.Offset, format_err.Err)
+			if format_err.Key != "" {
+				fmt.Fprintf(w, "[additional info] Reading key '%s'\n", format_err.Key)
+			}
+		} else {
+			fmt.Fprintf(w, "[additional info] %v\n", err)
+		}
+		if keys != 0 {
+			fmt.Fprintf(w, "[additional info] Last valid key: '%s' in db %d\n", last.Key, last.DB)
+		}
+		fmt.Fprintf(w, "[info] %d keys read\n", keys)
+		return 1
+	}
+
+	fmt.Fprintf(w, "[info] %d keys read\n", keys)
+	fmt.Fprintf(w, "[info] %d expires\n", expires)
+	fmt.Fprintf(w, "[info] %d already expired\n", expired)
+	fmt.Fprintf(w, "\\o/ RDB looks OK! \\o/\n")
+	return 0
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+)
+
+type dbKey struct {
+	db  int
+	key string
+}
+
+// keyDigest identifies the value of a key by a hash of its type and its
+// printed form, so that comparing two files does not hold both in memory.
+type keyDigest struct {
+	value      [sha256.Size]byte
+	expiry     int64
+	has_expiry bool
+}
+
+func digestEntry(entry rdb.Entry) (keyDigest, error) {
+	value, err := json.Marshal(jsonValue(entry.Value))
+	if err != nil {
+		return keyDigest{}, err
+	}
+	hash := sha256.New()
+	hash.Write([]byte(core.TypeName(entry.Value)))
+	hash.Write([]byte{0})
+	hash.Write(value)
+
+	digest := keyDigest{expiry: entry.Expiry, has_expiry: entry.HasExpiry}
+	hash.Sum(digest.value[:0])
+	return digest, nil
+}
+
+type keyChange struct {
+	dbKey
+	change string
+}
+
+// diff compares two RDB files key by key and lists the keys only found in
+// one of them, and the keys whose value or expiry differ, ordered by
+// database and key. It reports whether the files hold the same keys.
+func diff(filename1 string, filename2 string, w io.Writer) (bool, error) {
+	digests := make(map[dbKey]keyDigest)
+	err := scanFile(filename1, func(entry rdb.Entry) error {
+		digest, err := digestEntry(entry)
+		digests[dbKey{entry.DB, entry.Key}] = digest
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	var changes []keyChange
+	err = scanFile(filename2, func(entry rdb.Entry) error {
+		key := dbKey{entry.DB, entry.Key}
+		digest, err := digestEntry(entry)
+		if err != nil {
+			return err
+		}
+		old, ok := digests[key]
+		if !ok {
+			changes = append(changes, keyChange{key, "+"})
+			return nil
+		}
+		delete(digests, key)
+		switch {
+		case old.value != digest.value:
+			changes = append(changes, keyChange{key, "~ value"})
+		case old.has_expiry != digest.has_expiry || old.expiry != digest.expiry:
+			changes = append(changes, keyChange{key, "~ expiry"})
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	for key := range digests {
+		changes = append(changes, keyChange{key, "-"})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].db != changes[j].db {
+			return changes[i].db < changes[j].db
+		}
+		return changes[i].key < changes[j].key
+	})
+	for _, change := range changes {
+		fmt.Fprintf(w, "%s db %d %q\n", change.change, change.db, change.key)
+	}
+	return len(changes) == 0, nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// dumpLine is how a key is printed by dump. Expiries are unix times in
+// milliseconds.
+type dumpLine struct {
+	DB     int    `json:"db"`
+	Key    string `json:"key"`
+	Type   string `json:"type"`
+	Expiry *int64 `json:"expiry,omitempty"`
+	Value  any    `json:"value"`
+}
+
+// hashJSON holds the fields of a hash, along with the expiries of those
+// that have one.
+type hashJSON struct {
+	Fields        map[string]string `json:"fields"`
+	FieldExpiries map[string]int64  `json:"field_expiries,omitempty"`
+}
+
+type streamJSON struct {
+	Entries      []streamEntryJSON `json:"entries"`
+	LastID       string            `json:"last_id"`
+	MaxDeletedID string            `json:"max_deleted_id,omitempty"`
+	EntriesAdded uint64            `json:"entries_added"`
+	Groups       []streamGroupJSON `json:"groups,omitempty"`
+}
+
+type streamEntryJSON struct {
+	Id     string            `json:"id"`
+	Fields map[string]string `json:"fields"`
+}
+
+type streamGroupJSON struct {
+	Name        string               `json:"name"`
+	LastID      string               `json:"last_id"`
+	EntriesRead int64                `json:"entries_read"`
+	Pending     []streamPendingJSON  `json:"pending"`
+	Consumers   []streamConsumerJSON `json:"consumers"`
+}
+
+type streamPendingJSON struct {
+	Id            string `json:"id"`
+	Consumer      string `json:"consumer"`
+	DeliveryTime  int64  `json:"delivery_time"`
+	DeliveryCount uint64 `json:"delivery_count"`
+}
+
+type streamConsumerJSON struct {
+	Name       string `json:"name"`
+	SeenTime   int64  `json:"seen_time"`
+	ActiveTime int64  `json:"active_time"`
+}
+
+// dump prints every key of an RDB file as a line of JSON, in the order they
+// are stored.
+func dump(filename string, w io.Writer) error {
+	out := bufio.NewWriter(w)
+	defer out.Flush()
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+
+	return scanFile(filename, func(entry rdb.Entry) error {
+		return encoder.Encode(newDumpLine(entry))
+	})
+}
+
+func newDumpLine(entry rdb.Entry) dumpLine {
+	line := dumpLine{
+		DB:    entry.DB,
+		Key:   entry.Key,
+		Type:  core.TypeName(entry.Value),
+		Value: jsonValue(entry.Value),
+	}
+	if entry.HasExpiry {
+		line.Expiry = &entry.Expiry
+	}
+	return line
+}
+
+// jsonValue converts a value to the form it is printed in: strings, arrays
+// for lists and sets, an object with the fields and field expiries of
+// hashes, an object mapping members to scores for sorted sets, and an
+// object with the entries and consumer groups of streams. Set members are sorted so that equal sets print the same.
+func jsonValue(value resp.Object) any {
+	switch value := value.(type) {
+	case resp.BulkString:
+		return string(value)
+	case resp.SimpleString:
+		return string(value)
+	case core.Int:
+		return value.String()
+	case *core.List:
+		return value.Range(0, -1)
+	case *core.Set:
+		members := value.Members()
+		sort.Strings(members)
+		return members
+	case *core.Hash:
+		return newHashJSON(value)
+	case *core.SortedSet:
+		scores := make(map[string]any, value.Len())
+		for _, entry := range value.Entries() {
+			scores[entry.Member] = jsonScore(entry.Score)
+		}
+		return scores
+	case *resp.Stream:
+		return newStreamJSON(value)
+	}
+	return nil
+}
+
+// jsonScore returns a score as a number, or as a string for infinities,
+// which JSON has no numbers for.
+func jsonScore(score float64) any {
+	switch {
+	case math.IsInf(score, 1):
+		return "inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	}
+	return score
+}
+
+// newHashJSON converts a hash, keeping its field expiries so that diff tells
+// apart hashes that only differ by them.
+func newHashJSON(hash *core.Hash) hashJSON {
+	ret := hashJSON{Fields: make(map[string]string, hash.Len())}
+	hash.Range(func(field string, value string) bool {
+		ret.Fields[field] = value
+		if expiry, ok := hash.FieldExpiry(field); ok {
+			if ret.FieldExpiries == nil {
+				ret.FieldExpiries = make(map[string]int64)
+			}
+			ret.FieldExpiries[field] = expiry
+		}
+		return true
+	})
+	return ret
+}
+
+func newStreamJSON(stream *resp.Stream) streamJSON {
+	ret := streamJSON{
+		Entries:      make([]streamEntryJSON, 0, len(stream.Entries)),
+		LastID:       stream.LastID,
+		MaxDeletedID: stream.MaxDeletedID,
+		EntriesAdded: stream.EntriesAdded,
+	}
+	for _, entry := range stream.Entries {
+		fields := make(map[string]string, len(entry.Data))
+		for field, value := range entry.Data {
+			fields[field], _ = resp.ToString(value)
+		}
+		ret.Entries = append(ret.Entries, streamEntryJSON{Id: entry.Id, Fields: fields})
+	}
+	for _, group := range stream.Groups {
+		group_json := streamGroupJSON{
+			Name:        group.Name,
+			LastID:      group.LastID,
+			EntriesRead: group.EntriesRead,
+			Pending:     make([]streamPendingJSON, 0, len(group.Pending)),
+			Consumers:   make([]streamConsumerJSON, 0, len(group.Consumers)),
+		}
+		for _, pending := range group.Pending {
+			group_json.Pending = append(group_json.Pending, streamPendingJSON(pending))
+		}
+		for _, consumer := range group.Consumers {
+			group_json.Consumers = append(group_json.Consumers, streamConsumerJSON(consumer))
+		}
+		ret.Groups = append(ret.Groups, group_json)
+	}
+	return ret
+}
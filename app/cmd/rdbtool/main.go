@@ -0,0 +1,83 @@
+// Command rdbtool inspects RDB files without starting a server. It checks
+// that a file can be loaded, dumps its keys as JSON lines, reports how much
+// of the file each type of value takes, and compares the keys of two files.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+)
+
+const usage = `usage: rdbtool <command> <file> [<file>]
+
+commands:
+  check <file>            verify that the file can be loaded and report its keys
+  dump <file>             print each key as a JSON line, with its type, value and expiry
+  stats <file>            report the number of keys and the bytes taken by each type
+  diff <file1> <file2>    list the keys only in one file or with different values
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command given by args and returns the exit status: 0 on
+// success, 1 when the files are invalid or differ, and 2 on usage errors.
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	command, files := args[0], args[1:]
+	wanted := 1
+	if command == "diff" {
+		wanted = 2
+	}
+	if len(files) != wanted {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	var err error
+	switch command {
+	case "check":
+		return check(files[0], stdout)
+	case "dump":
+		err = dump(files[0], stdout)
+	case "stats":
+		err = stats(files[0], stdout)
+	case "diff":
+		var same bool
+		same, err = diff(files[0], files[1], stdout)
+		if err == nil && !same {
+			return 1
+		}
+	default:
+		fmt.Fprintf(stderr, "unknown command '%s'\n%s", command, usage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// scanFile calls fn with every key of an RDB file, verifying its checksum.
+func scanFile(filename string, fn func(entry rdb.Entry) error) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := rdb.Scanner{VerifyChecksum: true, Key: fn}
+	if err := scanner.Scan(file); err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	return nil
+}
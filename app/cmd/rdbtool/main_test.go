@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// writeFile writes the RDB file of store to a temporary directory.
+func writeFile(t *testing.T, store *core.Store) string {
+	filename := filepath.Join(t.TempDir(), "dump.rdb")
+	if err := os.WriteFile(filename, rdb.GenerateFile(store), 0o644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return filename
+}
+
+func testStore() *core.Store {
+	store := new(core.Store)
+	store.Init()
+	store.Set("string", resp.BulkString("hello"))
+	store.SetWithAbsoluteExpiry("expiring", resp.BulkString("soon"), 1<<45)
+	store.Set("set", core.NewSet("y", "x"))
+	sorted_set := core.NewSortedSet()
+	sorted_set.Add("member", 1.5)
+	store.Set("zset", sorted_set)
+	store.DB(2).Set("list", core.NewList("a", "b"))
+	return store
+}
+
+func runCommand(args ...string) (int, string) {
+	var stdout, stderr bytes.Buffer
+	status := run(args, &stdout, &stderr)
+	return status, stdout.String() + stderr.String()
+}
+
+func TestCheck(t *testing.T) {
+	filename := writeFile(t, testStore())
+	status, out := runCommand("check", filename)
+	if status != 0 || !strings.Contains(out, "RDB looks OK") || !strings.Contains(out, "[info] 5 keys read") {
+		t.Errorf("Expected the file to be valid with 5 keys\nGot: %d, %s", status, out)
+	}
+
+	data, _ := os.ReadFile(filename)
+	if err := os.WriteFile(filename, data[:len(data)-20], 0o644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	status, out = runCommand("check", filename)
+	if status != 1 || !strings.Contains(out, "RDB ERROR DETECTED") || !strings.Contains(out, "unexpected end") {
+		t.Errorf("Expected the truncated file to be reported\nGot: %d, %s", status, out)
+	}
+}
+
+func TestDump(t *testing.T) {
+	status, out := runCommand("dump", writeFile(t, testStore()))
+	if status != 0 {
+		t.Fatalf("Unexpected error: %s", out)
+	}
+
+	expected := []string{
+		`{"db":0,"key":"string","type":"string","value":"hello"}`,
+		`{"db":0,"key":"expiring","type":"string","expiry":35184372088832,"value":"soon"}`,
+		`{"db":0,"key":"set","type":"set","value":["x","y"]}`,
+		`{"db":0,"key":"zset","type":"zset","value":{"member":1.5}}`,
+		`{"db":2,"key":"list","type":"list","value":["a","b"]}`,
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected: %d lines\nGot: %s", len(expected), out)
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Expected: %s\nGot: %s", line, out)
+		}
+	}
+}
+
+func TestDumpHash(t *testing.T) {
+	store := new(core.Store)
+	store.Init()
+	hash := core.NewHash()
+	hash.Set("field", "value")
+	hash.Set("temporary", "value")
+	hash.SetFieldExpiry("temporary", 1<<45)
+	store.Set("hash", hash)
+	plain := core.NewHash()
+	plain.Set("field", "value")
+	store.Set("plain", plain)
+
+	status, out := runCommand("dump", writeFile(t, store))
+	if status != 0 {
+		t.Fatalf("Unexpected error: %s", out)
+	}
+	expected := []string{
+		`{"db":0,"key":"hash","type":"hash","value":{"fields":{"field":"value","temporary":"value"},"field_expiries":{"temporary":35184372088832}}}`,
+		`{"db":0,"key":"plain","type":"hash","value":{"fields":{"field":"value"}}}`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Expected: %s\nGot: %s", line, out)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	status, out := runCommand("stats", writeFile(t, testStore()))
+	if status != 0 {
+		t.Fatalf("Unexpected error: %s", out)
+	}
+	for _, line := range []string{"string  2", "total   5", "keys with an expiry: 1", "db 0: 4 keys", "db 2: 1 keys"} {
+		if !strings.Contains(out, line) {
+			t.Errorf("Expected: %s\nGot: %s", line, out)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	store := testStore()
+	hash := core.NewHash()
+	hash.Set("field", "value")
+	hash.SetFieldExpiry("field", 1<<45)
+	store.Set("hash", hash)
+	before := writeFile(t, store)
+
+	// The hash only differs by the expiry of its field.
+	hash.SetFieldExpiry("field", 1<<45+1)
+
+	store.Set("string", resp.BulkString("changed"))
+	store.Set("expiring", resp.BulkString("soon"))
+	store.Delete("set")
+	store.DB(1).Set("new", resp.BulkString("1"))
+	after := writeFile(t, store)
+
+	status, out := runCommand("diff", before, after)
+	expected := "~ expiry db 0 \"expiring\"\n~ value db 0 \"hash\"\n- db 0 \"set\"\n~ value db 0 \"string\"\n+ db 1 \"new\"\n"
+	if status != 1 || out != expected {
+		t.Errorf("Expected: %s\nGot: %d, %s", expected, status, out)
+	}
+
+	if status, out := runCommand("diff", before, before); status != 0 || out != "" {
+		t.Errorf("Expected no differences\nGot: %d, %s", status, out)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	tests := [][]string{{}, {"check"}, {"diff", "a"}, {"unknown", "a"}}
+	for _, args := range tests {
+		if status, _ := runCommand(args...); status != 2 {
+			t.Errorf("Expected: status 2 for %v\nGot: %d", args, status)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+)
+
+// typeStats sums up the keys of one type. Sizes are the bytes the keys,
+// their values and their expiries take in the file, which is how much of
+// the file each type accounts for rather than how much memory it takes once
+// loaded.
+type typeStats struct {
+	keys        int
+	bytes       uint64
+	largest     string
+	largest_db  int
+	largest_len uint64
+}
+
+func (s *typeStats) add(entry rdb.Entry) {
+	s.keys++
+	s.bytes += entry.Size
+	if entry.Size > s.largest_len {
+		s.largest = entry.Key
+		s.largest_db = entry.DB
+		s.largest_len = entry.Size
+	}
+}
+
+// stats reports the number of keys and bytes of each type of value in an
+// RDB file, along with the largest key of each type, the number of keys
+// with an expiry and the number of keys in each database.
+func stats(filename string, w io.Writer) error {
+	by_type := make(map[string]*typeStats)
+	by_db := make(map[int]int)
+	var total typeStats
+	expires := 0
+
+	err := scanFile(filename, func(entry rdb.Entry) error {
+		value_type := core.TypeName(entry.Value)
+		if by_type[value_type] == nil {
+			by_type[value_type] = &typeStats{}
+		}
+		by_type[value_type].add(entry)
+		total.add(entry)
+		by_db[entry.DB]++
+		if entry.HasExpiry {
+			expires++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	types := make([]string, 0, len(by_type))
+	for value_type := range by_type {
+		types = append(types, value_type)
+	}
+	sort.Strings(types)
+
+	table := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(table, "type\tkeys\tbytes\tlargest key\tlargest bytes\n")
+	for _, value_type := range types {
+		s := by_type[value_type]
+		fmt.Fprintf(table, "%s\t%d\t%d\tdb %d %q\t%d\n", value_type, s.keys, s.bytes, s.largest_db, s.largest, s.largest_len)
+	}
+	fmt.Fprintf(table, "total\t%d\t%d\t\t\n", total.keys, total.bytes)
+	table.Flush()
+
+	fmt.Fprintf(w, "\nkeys with an expiry: %d\n", expires)
+	dbs := make([]int, 0, len(by_db))
+	for db := range by_db {
+		dbs = append(dbs, db)
+	}
+	sort.Ints(dbs)
+	for _, db := range dbs {
+		fmt.Fprintf(w, "db %d: %d keys\n", db, by_db[db])
+	}
+	return nil
+}
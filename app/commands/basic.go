@@ -10,50 +10,86 @@ import (
 )
 
 func handleSetCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
-	if len(call) != 3 && len(call) != 5 {
-		return resp.SimpleError("invalid number of arguments to SET command")
+	if len(call) < 3 {
+		return wrongNumberOfArguments(call)
 	}
-	var err error
-	var expiry uint64
-	key, ok := call[1].(resp.BulkString)
+
+	args, ok := argsToStrings(call[1:])
 	if !ok {
-		return resp.SimpleError("key must be a string")
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	key, value := args[0], args[1]
+
+	// condition is NX or XX and expiration one of EX, PX, EXAT, PXAT or
+	// KEEPTTL. Each may be given at most once.
+	var condition, expiration string
+	var expiry int64
+	get := false
+	for i := 2; i < len(args); i++ {
+		option := strings.ToUpper(args[i])
+		switch {
+		case (option == "NX" || option == "XX") && condition == "":
+			condition = option
+		case option == "GET" && !get:
+			get = true
+		case option == "KEEPTTL" && expiration == "":
+			expiration = option
+		case (option == "EX" || option == "PX" || option == "EXAT" || option == "PXAT") && expiration == "" && i+1 < len(args):
+			var err error
+			expiry, err = parseExpireTime(option, args[i+1], "set")
+			if err != nil {
+				return resp.SimpleError(err.Error())
+			}
+			expiration = option
+			i++
+		default:
+			return resp.SimpleError(errSyntax.Error())
+		}
 	}
-	value, ok := call[2].(resp.BulkString)
-	if !ok {
-		return resp.SimpleError("value must be a string")
+
+	old, exists, err := getString(store, key)
+	if get && err != nil {
+		return resp.SimpleError(err.Error())
 	}
-	if len(call) == 5 {
-		flag, ok := call[3].(resp.BulkString)
-		if !ok {
-			return resp.SimpleError("expected flag to be a string")
+	if err == errWrongType {
+		exists = true
+	}
+
+	var reply resp.Object = resp.SimpleString("OK")
+	if get {
+		reply = resp.NullBulkString{}
+		if exists {
+			reply = resp.BulkString(old)
 		}
-		if strings.ToUpper(string(flag)) == "PX" {
-			expiry_str, ok := call[4].(resp.BulkString)
-			if !ok {
-				return resp.SimpleError("expected an expiry value")
-			}
-			expiry, err = strconv.ParseUint(string(expiry_str), 10, 64)
-			if err != nil {
-				return resp.SimpleError(fmt.Sprintf("expected expiry value to be an integer: %v\n", err))
-			}
+	}
+
+	if (condition == "NX" && exists) || (condition == "XX" && !exists) {
+		if get {
+			return reply
 		}
-		store.SetWithExpiry(string(key), value, expiry)
-	} else {
-		store.Set(string(key), call[2])
+		return resp.NullBulkString{}
 	}
 
-	if store.Master == nil || conn.Conn != store.Master.Conn {
-		return resp.SimpleString("OK")
+	propagated := []string{"SET", key, value}
+	switch expiration {
+	case "":
+		store.Set(key, resp.BulkString(value))
+	case "KEEPTTL":
+		store.SetKeepTTL(key, resp.BulkString(value))
+		propagated = append(propagated, "KEEPTTL")
+	default:
+		// Relative expiries are sent as absolute ones, so that replicas
+		// expire the key at the same time as the master.
+		store.SetWithAbsoluteExpiry(key, resp.BulkString(value), uint64(expiry))
+		propagated = append(propagated, "PXAT", strconv.FormatInt(expiry, 10))
 	}
-	return nil
+
+	store.PropagateToReplicas(Generate(propagated...))
+	return reply
 }
 
 func handlePingCommand(_ resp.Array, conn *core.Conn, store *core.Store) resp.Object {
-	if store.Master == nil || conn.Conn != store.Master.Conn {
-		return resp.SimpleString("PONG")
-	}
-	return nil
+	return resp.SimpleString("PONG")
 }
 
 func handleEchoCommand(call resp.Array, conn *core.Conn, _ *core.Store) resp.Object {
@@ -72,14 +108,14 @@ func handleGetCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.
 	if !ok {
 		return resp.SimpleError("key must be a string")
 	}
-	res, ok := store.Get(string(key))
-	if !ok {
-		res = resp.NullBulkString{}
+	value, ok, err := getString(store, string(key))
+	if err != nil {
+		return resp.SimpleError(err.Error())
 	}
-	if store.Master == nil || conn.Conn != store.Master.Conn {
-		return res
+	if !ok {
+		return resp.NullBulkString{}
 	}
-	return nil
+	return resp.BulkString(value)
 }
 
 func handleConfigCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
@@ -110,7 +146,7 @@ func handleConfigCommand(call resp.Array, conn *core.Conn, store *core.Store) re
 
 func handleKeysCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
 	if len(call) != 2 {
-		return resp.SimpleError("invalid number of arguments to CONFIG command")
+		return wrongNumberOfArguments(call)
 	}
 
 	search, ok := call[1].(resp.BulkString)
@@ -126,34 +162,77 @@ func handleKeysCommand(call resp.Array, conn *core.Conn, store *core.Store) resp
 	return res
 }
 
-func handleInfoCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
-	if len(call) != 2 {
-		return resp.SimpleError("invalid number of arguments to INFO command")
-	}
+// infoSections lists the INFO sections in the order they are reported, with
+// the functions generating their fields.
+var infoSections = []struct {
+	name   string
+	title  string
+	fields func(store *core.Store) []string
+}{
+	{"replication", "Replication", infoReplication},
+	{"persistence", "Persistence", infoPersistence},
+	{"stats", "Stats", infoStats},
+	{"keyspace", "Keyspace", infoKeyspace},
+}
 
-	arg, ok := call[1].(resp.BulkString)
+func handleInfoCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	args, ok := argsToStrings(call[1:])
 	if !ok {
 		return resp.SimpleError("expected a string argument for INFO")
 	}
-	if arg != "replication" {
-		return nil
+
+	requested := make(map[string]bool)
+	for _, arg := range args {
+		requested[strings.ToLower(arg)] = true
 	}
+	all := len(args) == 0 || requested["all"] || requested["default"] || requested["everything"]
 
+	sections := []string{}
+	for _, section := range infoSections {
+		if !all && !requested[section.name] {
+			continue
+		}
+		fields := section.fields(store)
+		sections = append(sections, strings.Join(append([]string{"# " + section.title}, fields...), "\r\n"))
+	}
+
+	return resp.BulkString(strings.Join(sections, "\r\n\r\n"))
+}
+
+func infoReplication(store *core.Store) []string {
 	role := "master"
 	if _, ok := store.GetParam("replicaof"); ok {
 		role = "slave"
 	}
-	strs := []string{"role:" + role}
+	fields := []string{"role:" + role}
 	if role == "master" {
 		master_replid, _ := store.GetParam("master_replid")
 		master_repl_offset, _ := store.GetParam("master_repl_offset")
-		strs = append(strs, "master_replid:"+master_replid)
-		strs = append(strs, "master_repl_offset:"+master_repl_offset)
+		fields = append(fields, "master_replid:"+master_replid)
+		fields = append(fields, "master_repl_offset:"+master_repl_offset)
 	}
+	return fields
+}
 
-	info := strings.Join(strs, "\r\n")
-	res := resp.BulkString(info)
-	return res
+func infoStats(store *core.Store) []string {
+	stats := store.ExpiryStats()
+	return []string{
+		fmt.Sprintf("expired_keys:%d", stats.ExpiredKeys),
+		fmt.Sprintf("expired_stale_perc:%.2f", stats.StalePercent),
+		fmt.Sprintf("expired_time_cap_reached_count:%d", stats.TimeCapReached),
+		fmt.Sprintf("expire_cycle_cpu_milliseconds:%d", stats.CycleTime.Milliseconds()),
+	}
+}
+
+func infoKeyspace(store *core.Store) []string {
+	fields := []string{}
+	for i := 0; i < store.Databases(); i++ {
+		db := store.DB(i)
+		if keys := db.Len(); keys > 0 {
+			fields = append(fields, fmt.Sprintf("db%d:keys=%d,expires=%d,avg_ttl=0", i, keys, db.ExpiresLen()))
+		}
+	}
+	return fields
 }
 
 func handleTypeCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
@@ -170,35 +249,3 @@ func handleTypeCommand(call resp.Array, conn *core.Conn, store *core.Store) resp
 	res := resp.SimpleString(value_type)
 	return res
 }
-
-func handleIncrCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
-	if len(call) != 2 {
-		return resp.SimpleError("invalid number of commands to INCR command")
-	}
-
-	key, ok := resp.ToString(call[1])
-	if !ok {
-		return resp.SimpleError("expected a string key")
-	}
-
-	value_raw, key_exists := store.Get(key)
-
-	var value int
-
-	if key_exists {
-		stored_value, is_a_number := resp.ToInt(value_raw)
-
-		if !is_a_number {
-			return resp.SimpleError("ERR value is not an integer or out of range")
-		}
-
-		value = stored_value + 1
-	} else {
-		value = 1
-	}
-
-	str := resp.BulkString(strconv.Itoa(value))
-	store.Set(key, str)
-
-	return resp.Integer(value)
-}
@@ -1,19 +1,30 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/codecrafters-io/redis-starter-go/app/core"
 	"github.com/codecrafters-io/redis-starter-go/app/rdb"
 	"github.com/codecrafters-io/redis-starter-go/app/resp"
 )
 
+var errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+var errNotInteger = errors.New("ERR value is not an integer or out of range")
+var errSyntax = errors.New("ERR syntax error")
+
 type commandHandlerFunc func(resp.Array, *core.Conn, *core.Store) resp.Object
 type commandHandlerFuncs map[string]commandHandlerFunc
 
 func GetCommandName(call resp.Array) (string, bool) {
+	if len(call) == 0 {
+		return "", false
+	}
 	command, ok := resp.ToString(call[0])
 	if !ok {
 		return "", false
@@ -52,6 +63,7 @@ func HandleCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Obj
 	conn.Mu.Unlock()
 
 	fmt.Printf("Received command %v\n", call)
+	store = store.DB(conn.DB)
 
 	var handlers commandHandlerFuncs = commandHandlerFuncs{
 		"PING":     handlePingCommand,
@@ -72,6 +84,157 @@ func HandleCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Obj
 		"MULTI":    handleMultiCommand,
 		"EXEC":     handleExecCommand,
 		"DISCARD":  handleDiscardCommand,
+
+		"APPEND":   handleAppendCommand,
+		"STRLEN":   handleStrlenCommand,
+		"GETRANGE": handleGetrangeCommand,
+		"SETRANGE": handleSetrangeCommand,
+		"MGET":     handleMgetCommand,
+		"MSET":     handleMsetCommand,
+		"MSETNX":   handleMsetnxCommand,
+		"GETDEL":   handleGetdelCommand,
+		"GETEX":    handleGetexCommand,
+		"GETSET":   handleGetsetCommand,
+		"LCS":      handleLcsCommand,
+
+		"DECR":        handleDecrCommand,
+		"INCRBY":      handleIncrbyCommand,
+		"DECRBY":      handleDecrbyCommand,
+		"INCRBYFLOAT": handleIncrbyfloatCommand,
+
+		"DEL":       handleDelCommand,
+		"UNLINK":    handleUnlinkCommand,
+		"EXISTS":    handleExistsCommand,
+		"TOUCH":     handleTouchCommand,
+		"RENAME":    handleRenameCommand,
+		"RENAMENX":  handleRenamenxCommand,
+		"COPY":      handleCopyCommand,
+		"RANDOMKEY": handleRandomkeyCommand,
+		"DBSIZE":    handleDbsizeCommand,
+		"SCAN":      handleScanCommand,
+
+		"SELECT":   handleSelectCommand,
+		"SWAPDB":   handleSwapdbCommand,
+		"MOVE":     handleMoveCommand,
+		"FLUSHDB":  handleFlushdbCommand,
+		"FLUSHALL": handleFlushallCommand,
+
+		"SAVE":     handleSaveCommand,
+		"BGSAVE":   handleBgsaveCommand,
+		"LASTSAVE": handleLastsaveCommand,
+		"DEBUG":    handleDebugCommand,
+
+		"BGREWRITEAOF": handleBgrewriteaofCommand,
+
+		"EXPIRE":      handleExpireCommand,
+		"PEXPIRE":     handlePexpireCommand,
+		"EXPIREAT":    handleExpireatCommand,
+		"PEXPIREAT":   handlePexpireatCommand,
+		"TTL":         handleTtlCommand,
+		"PTTL":        handlePttlCommand,
+		"EXPIRETIME":  handleExpiretimeCommand,
+		"PEXPIRETIME": handlePexpiretimeCommand,
+		"PERSIST":     handlePersistCommand,
+
+		"LPUSH":     handleLpushCommand,
+		"RPUSH":     handleRpushCommand,
+		"LPUSHX":    handleLpushxCommand,
+		"RPUSHX":    handleRpushxCommand,
+		"LPOP":      handleLpopCommand,
+		"RPOP":      handleRpopCommand,
+		"LMPOP":     handleLmpopCommand,
+		"LRANGE":    handleLrangeCommand,
+		"LINDEX":    handleLindexCommand,
+		"LSET":      handleLsetCommand,
+		"LREM":      handleLremCommand,
+		"LTRIM":     handleLtrimCommand,
+		"LINSERT":   handleLinsertCommand,
+		"LLEN":      handleLlenCommand,
+		"LPOS":      handleLposCommand,
+		"LMOVE":     handleLmoveCommand,
+		"RPOPLPUSH": handleRpoplpushCommand,
+
+		"BLPOP":      handleBlpopCommand,
+		"BRPOP":      handleBrpopCommand,
+		"BLMOVE":     handleBlmoveCommand,
+		"BRPOPLPUSH": handleBrpoplpushCommand,
+		"BLMPOP":     handleBlmpopCommand,
+
+		"HSET":         handleHsetCommand,
+		"HMSET":        handleHmsetCommand,
+		"HSETNX":       handleHsetnxCommand,
+		"HGET":         handleHgetCommand,
+		"HMGET":        handleHmgetCommand,
+		"HDEL":         handleHdelCommand,
+		"HGETALL":      handleHgetallCommand,
+		"HKEYS":        handleHkeysCommand,
+		"HVALS":        handleHvalsCommand,
+		"HSCAN":        handleHscanCommand,
+		"HLEN":         handleHlenCommand,
+		"HEXISTS":      handleHexistsCommand,
+		"HSTRLEN":      handleHstrlenCommand,
+		"HINCRBY":      handleHincrbyCommand,
+		"HINCRBYFLOAT": handleHincrbyfloatCommand,
+		"HRANDFIELD":   handleHrandfieldCommand,
+		"HEXPIRE":      handleHexpireCommand,
+		"HPEXPIRE":     handleHpexpireCommand,
+		"HEXPIREAT":    handleHexpireatCommand,
+		"HPEXPIREAT":   handleHpexpireatCommand,
+		"HTTL":         handleHttlCommand,
+		"HPTTL":        handleHpttlCommand,
+		"HEXPIRETIME":  handleHexpiretimeCommand,
+		"HPEXPIRETIME": handleHpexpiretimeCommand,
+		"HPERSIST":     handleHpersistCommand,
+
+		"SADD":        handleSaddCommand,
+		"SREM":        handleSremCommand,
+		"SMEMBERS":    handleSmembersCommand,
+		"SISMEMBER":   handleSismemberCommand,
+		"SMISMEMBER":  handleSmismemberCommand,
+		"SCARD":       handleScardCommand,
+		"SSCAN":       handleSscanCommand,
+		"SPOP":        handleSpopCommand,
+		"SRANDMEMBER": handleSrandmemberCommand,
+		"SMOVE":       handleSmoveCommand,
+		"SINTER":      handleSinterCommand,
+		"SUNION":      handleSunionCommand,
+		"SDIFF":       handleSdiffCommand,
+		"SINTERSTORE": handleSinterstoreCommand,
+		"SUNIONSTORE": handleSunionstoreCommand,
+		"SDIFFSTORE":  handleSdiffstoreCommand,
+		"SINTERCARD":  handleSintercardCommand,
+
+		"ZADD":             handleZaddCommand,
+		"ZINCRBY":          handleZincrbyCommand,
+		"ZREM":             handleZremCommand,
+		"ZCARD":            handleZcardCommand,
+		"ZSCAN":            handleZscanCommand,
+		"ZSCORE":           handleZscoreCommand,
+		"ZMSCORE":          handleZmscoreCommand,
+		"ZRANK":            handleZrankCommand,
+		"ZREVRANK":         handleZrevrankCommand,
+		"ZCOUNT":           handleZcountCommand,
+		"ZLEXCOUNT":        handleZlexcountCommand,
+		"ZRANGE":           handleZrangeCommand,
+		"ZREVRANGE":        handleZrevrangeCommand,
+		"ZRANGEBYSCORE":    handleZrangebyscoreCommand,
+		"ZREVRANGEBYSCORE": handleZrevrangebyscoreCommand,
+		"ZRANGEBYLEX":      handleZrangebylexCommand,
+		"ZREVRANGEBYLEX":   handleZrevrangebylexCommand,
+		"ZRANGESTORE":      handleZrangestoreCommand,
+		"ZUNION":           handleZunionCommand,
+		"ZINTER":           handleZinterCommand,
+		"ZDIFF":            handleZdiffCommand,
+		"ZUNIONSTORE":      handleZunionstoreCommand,
+		"ZINTERSTORE":      handleZinterstoreCommand,
+		"ZDIFFSTORE":       handleZdiffstoreCommand,
+		"ZPOPMIN":          handleZpopminCommand,
+		"ZPOPMAX":          handleZpopmaxCommand,
+		"ZMPOP":            handleZmpopCommand,
+
+		"BZPOPMIN": handleBzpopminCommand,
+		"BZPOPMAX": handleBzpopmaxCommand,
+		"BZMPOP":   handleBzmpopCommand,
 	}
 
 	handler, ok := handlers[command]
@@ -82,11 +245,131 @@ func HandleCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Obj
 	return handler(call, conn, store)
 }
 
-func sendCurrentState(conn *core.Conn) {
-	data := rdb.GenerateFile(nil)
-	res := resp.BulkString(data).Encode()
-	res = res[:len(res)-2]
-	conn.Write(res)
+func wrongNumberOfArguments(call resp.Array) resp.SimpleError {
+	name, _ := GetCommandName(call)
+	return resp.SimpleError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+}
+
+func argsToStrings(args []resp.Object) ([]string, bool) {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		str, ok := resp.ToString(arg)
+		if !ok {
+			return nil, false
+		}
+		strs[i] = str
+	}
+	return strs, true
+}
+
+// parseBlockingTimeout parses a timeout given in seconds. A zero timeout
+// blocks forever, which is represented by a nil timer.
+func parseBlockingTimeout(obj resp.Object) (<-chan time.Time, error) {
+	str, _ := resp.ToString(obj)
+	seconds, err := strconv.ParseFloat(str, 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return nil, errors.New("ERR timeout is not a float or out of range")
+	}
+	if seconds < 0 {
+		return nil, errors.New("ERR timeout is negative")
+	}
+	if seconds > float64(math.MaxInt64/time.Second) {
+		return nil, errors.New("ERR timeout is out of range")
+	}
+	if seconds == 0 {
+		return nil, nil
+	}
+	return time.After(time.Duration(seconds * float64(time.Second))), nil
+}
+
+// blockOnKeys serves the client from the first non-empty key among keys, or
+// blocks it until one of them is written to. Keys must hold values of the
+// given type. timed_out is returned when the timer fires first, or right away
+// when called from within a transaction.
+func blockOnKeys(conn *core.Conn, store *core.Store, keys []string, value_type string, timer <-chan time.Time, serve core.ServeFunc, timed_out resp.Object) resp.Object {
+	for _, key := range keys {
+		switch store.TypeOfValue(key) {
+		case "none":
+			continue
+		case value_type:
+		default:
+			return resp.SimpleError(errWrongType.Error())
+		}
+		if res, ok := serve(key); ok {
+			return res
+		}
+	}
+
+	if conn.Executing {
+		return timed_out
+	}
+
+	res, ok := store.BlockOnKeys(conn, keys, timer, serve)
+	if !ok {
+		return timed_out
+	}
+	return res
+}
+
+// parseMpopArgs parses the "numkeys key [key ...] side [COUNT count]"
+// arguments shared by LMPOP, BLMPOP, ZMPOP and BZMPOP. parseSide reports
+// whether the side is LEFT or MIN.
+func parseMpopArgs(args resp.Array, parseSide func(side string) (bool, bool)) (keys []string, left bool, count int, err error) {
+	numkeys, ok := resp.ToInt(args[0])
+	if !ok || numkeys <= 0 {
+		return nil, false, 0, errors.New("ERR numkeys should be greater than 0")
+	}
+	if numkeys > len(args)-2 {
+		return nil, false, 0, errors.New("ERR Number of keys can't be greater than number of args")
+	}
+
+	keys, ok = argsToStrings(args[1 : numkeys+1])
+	if !ok {
+		return nil, false, 0, errSyntax
+	}
+
+	side, _ := resp.ToString(args[numkeys+1])
+	left, ok = parseSide(side)
+	if !ok {
+		return nil, false, 0, errSyntax
+	}
+
+	count = 1
+	rest := args[numkeys+2:]
+	if len(rest) == 0 {
+		return keys, left, count, nil
+	}
+
+	option, _ := resp.ToString(rest[0])
+	if len(rest) != 2 || strings.ToUpper(option) != "COUNT" {
+		return nil, false, 0, errSyntax
+	}
+	count, ok = resp.ToInt(rest[1])
+	if !ok || count <= 0 {
+		return nil, false, 0, errors.New("ERR count should be greater than 0")
+	}
+
+	return keys, left, count, nil
+}
+
+func parseFloat(str string) (float64, error) {
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil || math.IsNaN(value) {
+		return 0, errors.New("ERR value is not a valid float")
+	}
+	return value, nil
+}
+
+// formatFloat formats a float the way Redis replies with computed floats:
+// in plain decimal notation without trailing zeros.
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func sendCurrentState(conn *core.Conn, store *core.Store) {
+	if err := rdb.WriteBulk(conn.Conn, store); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to send the snapshot to the replica: %v\n", err)
+	}
 }
 
 func Generate(strs ...string) resp.Array {
@@ -0,0 +1,614 @@
+package commands
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+func newTestStore() *core.Store {
+	store := new(core.Store)
+	store.Init()
+	return store
+}
+
+// run runs a command on store and returns its reply as RESP.
+func run(store *core.Store, args ...string) string {
+	conn := core.NewConn(nil, core.ConnRelationTypeEnum.NORMAL)
+	return string(HandleCommand(resp.StringsToArray(args), conn, store).Encode())
+}
+
+func errorReply(message string) string {
+	return string(resp.SimpleError(message).Encode())
+}
+
+func TestMpopNumkeys(t *testing.T) {
+	max := strconv.Itoa(1<<63 - 1)
+	too_many := errorReply("ERR Number of keys can't be greater than number of args")
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "LMPOP max numkeys", args: []string{"LMPOP", max, "a", "LEFT"}, want: too_many},
+		{name: "LMPOP numkeys past the keys", args: []string{"LMPOP", "2", "a", "LEFT"}, want: too_many},
+		{name: "LMPOP keys without side", args: []string{"LMPOP", "2", "a", "b"}, want: too_many},
+		{name: "BLMPOP max numkeys", args: []string{"BLMPOP", "0", max, "a", "LEFT"}, want: too_many},
+		{name: "ZMPOP max numkeys", args: []string{"ZMPOP", max, "a", "MIN"}, want: too_many},
+		{name: "BZMPOP max numkeys", args: []string{"BZMPOP", "0", max, "a", "MIN"}, want: too_many},
+		{name: "LMPOP", args: []string{"LMPOP", "1", "list", "LEFT"}, want: "*2\r\n$4\r\nlist\r\n*1\r\n$1\r\na\r\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newTestStore()
+			run(store, "RPUSH", "list", "a", "b")
+			if got := run(store, test.args...); got != test.want {
+				t.Errorf("Expected: %q\nGot: %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSortedSetOperationNumkeys(t *testing.T) {
+	max := strconv.Itoa(1<<63 - 1)
+	syntax := errorReply(errSyntax.Error())
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "ZUNIONSTORE max numkeys", args: []string{"ZUNIONSTORE", "dest", max, "a"}, want: syntax},
+		{name: "ZINTERSTORE max numkeys", args: []string{"ZINTERSTORE", "dest", max, "a"}, want: syntax},
+		{name: "ZDIFFSTORE max numkeys", args: []string{"ZDIFFSTORE", "dest", max, "a"}, want: syntax},
+		{name: "ZUNION max numkeys", args: []string{"ZUNION", max, "a"}, want: syntax},
+		{name: "ZINTER max numkeys", args: []string{"ZINTER", max, "a"}, want: syntax},
+		{name: "ZDIFF max numkeys", args: []string{"ZDIFF", max, "a"}, want: syntax},
+		{name: "ZUNION numkeys past the keys", args: []string{"ZUNION", "2", "a"}, want: syntax},
+		{name: "ZUNION", args: []string{"ZUNION", "1", "zset"}, want: "*1\r\n$1\r\na\r\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newTestStore()
+			run(store, "ZADD", "zset", "1", "a")
+			if got := run(store, test.args...); got != test.want {
+				t.Errorf("Expected: %q\nGot: %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSetrangeOffset(t *testing.T) {
+	too_long := errorReply(errStringTooLong.Error())
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "max offset", args: []string{"SETRANGE", "key", strconv.Itoa(1<<63 - 1), "x"}, want: too_long},
+		{name: "past the maximum length", args: []string{"SETRANGE", "key", strconv.Itoa(maxStringLength), "x"}, want: too_long},
+		{name: "negative offset", args: []string{"SETRANGE", "key", "-1", "x"}, want: errorReply("ERR offset is out of range")},
+		{name: "padded", args: []string{"SETRANGE", "key", "7", "x"}, want: ":8\r\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newTestStore()
+			run(store, "SET", "key", "hello")
+			if got := run(store, test.args...); got != test.want {
+				t.Errorf("Expected: %q\nGot: %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestHrandfieldCount(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "min count", args: []string{"HRANDFIELD", "hash", strconv.Itoa(-1 << 63)}, want: errorReply(errRandomCount.Error())},
+		{name: "count too large to allocate", args: []string{"HRANDFIELD", "hash", "-4611686018427387903"}, want: errorReply(errRandomCount.Error())},
+		{name: "count past the picks", args: []string{"HRANDFIELD", "hash", strconv.Itoa(-maxRandomPicks - 1)}, want: errorReply(errRandomCount.Error())},
+		{name: "max count", args: []string{"HRANDFIELD", "hash", strconv.Itoa(1<<63 - 1)}, want: errorReply(errRandomCount.Error())},
+		{name: "repeated fields", args: []string{"HRANDFIELD", "hash", "-3"}, want: "*3\r\n$1\r\nf\r\n$1\r\nf\r\n$1\r\nf\r\n"},
+		{name: "count past the fields", args: []string{"HRANDFIELD", "hash", "3"}, want: "*1\r\n$1\r\nf\r\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newTestStore()
+			run(store, "HSET", "hash", "f", "v")
+			if got := run(store, test.args...); got != test.want {
+				t.Errorf("Expected: %q\nGot: %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSrandmemberCount(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "min count", args: []string{"SRANDMEMBER", "set", strconv.Itoa(-1 << 63)}, want: errorReply(errRandomCount.Error())},
+		{name: "count too large to allocate", args: []string{"SRANDMEMBER", "set", "-4611686018427387903"}, want: errorReply(errRandomCount.Error())},
+		{name: "count past the picks", args: []string{"SRANDMEMBER", "set", strconv.Itoa(-maxRandomPicks - 1)}, want: errorReply(errRandomCount.Error())},
+		{name: "max count", args: []string{"SRANDMEMBER", "set", strconv.Itoa(1<<63 - 1)}, want: errorReply(errRandomCount.Error())},
+		{name: "repeated members", args: []string{"SRANDMEMBER", "set", "-2"}, want: "*2\r\n$1\r\nm\r\n$1\r\nm\r\n"},
+		{name: "count past the members", args: []string{"SRANDMEMBER", "set", "2"}, want: "*1\r\n$1\r\nm\r\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newTestStore()
+			run(store, "SADD", "set", "m")
+			if got := run(store, test.args...); got != test.want {
+				t.Errorf("Expected: %q\nGot: %q", test.want, got)
+			}
+		})
+	}
+}
+
+// commandSequence is a list of commands run in order on a new store, along
+// with the reply expected from each.
+type commandSequence struct {
+	name     string
+	commands [][]string
+	want     []string
+}
+
+func runSequences(t *testing.T, tests []commandSequence) {
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newTestStore()
+			for i, args := range test.commands {
+				if got := run(store, args...); got != test.want[i] {
+					t.Errorf("%v\nExpected: %q\nGot: %q", args, test.want[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestSetOptions(t *testing.T) {
+	ok := "+OK\r\n"
+	null := "$-1\r\n"
+	syntax := errorReply(errSyntax.Error())
+	invalid_expire := errorReply("ERR invalid expire time in 'set' command")
+
+	runSequences(t, []commandSequence{
+		{
+			name:     "NX and XX",
+			commands: [][]string{{"SET", "key", "v", "NX", "XX"}, {"GET", "key"}},
+			want:     []string{syntax, null},
+		},
+		{
+			name:     "NX twice",
+			commands: [][]string{{"SET", "key", "v", "NX", "NX"}},
+			want:     []string{syntax},
+		},
+		{
+			name:     "NX on a missing key",
+			commands: [][]string{{"SET", "key", "v", "NX"}, {"GET", "key"}},
+			want:     []string{ok, "$1\r\nv\r\n"},
+		},
+		{
+			name:     "NX on an existing key",
+			commands: [][]string{{"SET", "key", "old"}, {"SET", "key", "v", "NX"}, {"GET", "key"}},
+			want:     []string{ok, null, "$3\r\nold\r\n"},
+		},
+		{
+			name:     "XX on a missing key",
+			commands: [][]string{{"SET", "key", "v", "XX"}, {"GET", "key"}},
+			want:     []string{null, null},
+		},
+		{
+			name:     "GET with NX on an existing key",
+			commands: [][]string{{"SET", "key", "old"}, {"SET", "key", "v", "NX", "GET"}, {"GET", "key"}},
+			want:     []string{ok, "$3\r\nold\r\n", "$3\r\nold\r\n"},
+		},
+		{
+			name:     "GET with NX on a missing key",
+			commands: [][]string{{"SET", "key", "v", "GET", "NX"}, {"GET", "key"}},
+			want:     []string{null, "$1\r\nv\r\n"},
+		},
+		{
+			name:     "GET on another type",
+			commands: [][]string{{"RPUSH", "key", "a"}, {"SET", "key", "v", "GET"}},
+			want:     []string{":1\r\n", errorReply(errWrongType.Error())},
+		},
+		{
+			name:     "KEEPTTL with EX",
+			commands: [][]string{{"SET", "key", "v", "KEEPTTL", "EX", "10"}},
+			want:     []string{syntax},
+		},
+		{
+			name:     "EX with PX",
+			commands: [][]string{{"SET", "key", "v", "EX", "10", "PX", "10000"}},
+			want:     []string{syntax},
+		},
+		{
+			name:     "KEEPTTL keeps the expiry",
+			commands: [][]string{{"SET", "key", "v", "EX", "100"}, {"SET", "key", "w", "KEEPTTL"}, {"TTL", "key"}},
+			want:     []string{ok, ok, ":100\r\n"},
+		},
+		{
+			name:     "EX 0",
+			commands: [][]string{{"SET", "key", "v", "EX", "0"}, {"GET", "key"}},
+			want:     []string{invalid_expire, null},
+		},
+		{
+			name:     "negative EX",
+			commands: [][]string{{"SET", "key", "v", "EX", "-1"}},
+			want:     []string{invalid_expire},
+		},
+		{
+			name:     "EX overflowing milliseconds",
+			commands: [][]string{{"SET", "key", "v", "EX", strconv.Itoa(1<<63 - 1)}},
+			want:     []string{invalid_expire},
+		},
+		{
+			name:     "PX not an integer",
+			commands: [][]string{{"SET", "key", "v", "PX", "soon"}},
+			want:     []string{errorReply(errNotInteger.Error())},
+		},
+		{
+			name:     "EX without a value",
+			commands: [][]string{{"SET", "key", "v", "EX"}},
+			want:     []string{syntax},
+		},
+		{
+			name:     "PXAT in the past",
+			commands: [][]string{{"SET", "key", "v", "PXAT", "1"}, {"EXISTS", "key"}},
+			want:     []string{ok, ":0\r\n"},
+		},
+	})
+}
+
+func TestIncrementOverflow(t *testing.T) {
+	max := strconv.Itoa(1<<63 - 1)
+	min := strconv.Itoa(-1 << 63)
+	overflow := errorReply("ERR increment or decrement would overflow")
+	not_integer := errorReply(errNotInteger.Error())
+
+	runSequences(t, []commandSequence{
+		{
+			name:     "INCRBY past the maximum",
+			commands: [][]string{{"SET", "n", max}, {"INCRBY", "n", "1"}, {"GET", "n"}},
+			want:     []string{"+OK\r\n", overflow, "$19\r\n" + max + "\r\n"},
+		},
+		{
+			name:     "INCR past the maximum",
+			commands: [][]string{{"SET", "n", max}, {"INCR", "n"}},
+			want:     []string{"+OK\r\n", overflow},
+		},
+		{
+			name:     "INCRBY past the minimum",
+			commands: [][]string{{"SET", "n", "0"}, {"INCRBY", "n", min}, {"INCRBY", "n", "-1"}},
+			want:     []string{"+OK\r\n", ":" + min + "\r\n", overflow},
+		},
+		{
+			name:     "DECRBY past the minimum",
+			commands: [][]string{{"SET", "n", min}, {"DECRBY", "n", "1"}, {"DECR", "n"}},
+			want:     []string{"+OK\r\n", overflow, overflow},
+		},
+		{
+			name:     "DECRBY the minimum",
+			commands: [][]string{{"DECRBY", "n", min}, {"EXISTS", "n"}},
+			want:     []string{errorReply("ERR decrement would overflow"), ":0\r\n"},
+		},
+		{
+			name:     "INCRBY an increment out of range",
+			commands: [][]string{{"INCRBY", "n", "9223372036854775808"}},
+			want:     []string{not_integer},
+		},
+		{
+			name:     "INCRBY a value out of range",
+			commands: [][]string{{"SET", "n", "9223372036854775808"}, {"INCRBY", "n", "1"}},
+			want:     []string{"+OK\r\n", not_integer},
+		},
+		{
+			name:     "INCRBY up to the maximum",
+			commands: [][]string{{"SET", "n", "9223372036854775806"}, {"INCRBY", "n", "1"}},
+			want:     []string{"+OK\r\n", ":" + max + "\r\n"},
+		},
+		{
+			name:     "INCRBYFLOAT to infinity",
+			commands: [][]string{{"SET", "f", "1.7976931348623157e308"}, {"INCRBYFLOAT", "f", "1.7976931348623157e308"}, {"GET", "f"}},
+			want:     []string{"+OK\r\n", errorReply("ERR increment would produce NaN or Infinity"), "$22\r\n1.7976931348623157e308\r\n"},
+		},
+		{
+			name:     "INCRBYFLOAT an infinite increment",
+			commands: [][]string{{"INCRBYFLOAT", "f", "inf"}},
+			want:     []string{errorReply("ERR increment would produce NaN or Infinity")},
+		},
+		{
+			name:     "INCRBYFLOAT not a float",
+			commands: [][]string{{"INCRBYFLOAT", "f", "one"}},
+			want:     []string{errorReply("ERR value is not a valid float")},
+		},
+		{
+			name:     "INCRBYFLOAT",
+			commands: [][]string{{"SET", "f", "10.5"}, {"INCRBYFLOAT", "f", "0.1"}},
+			want:     []string{"+OK\r\n", "$4\r\n10.6\r\n"},
+		},
+	})
+}
+
+// recordingLog is an append only log that keeps the propagated commands.
+type recordingLog struct {
+	calls [][]string
+}
+
+func (l *recordingLog) Append(db int, call resp.Array) {
+	args := make([]string, len(call))
+	for i, arg := range call {
+		switch arg := arg.(type) {
+		case *resp.BulkString:
+			args[i] = string(*arg)
+		default:
+			args[i], _ = resp.ToString(arg)
+		}
+	}
+	l.calls = append(l.calls, args)
+}
+
+func (l *recordingLog) Rewrite(store *core.Store) error { return nil }
+
+func (l *recordingLog) Stats() core.AppendOnlyLogStats { return core.AppendOnlyLogStats{} }
+
+func TestHincrbyfloatPropagatesFieldExpiry(t *testing.T) {
+	store := newTestStore()
+	log := &recordingLog{}
+	store.SetAppendOnlyLog(log)
+	run(store, "HSET", "hash", "f", "1", "g", "1")
+	run(store, "HEXPIRE", "hash", "100", "FIELDS", "1", "f")
+	run(store, "HINCRBYFLOAT", "hash", "f", "0.5")
+	run(store, "HINCRBYFLOAT", "hash", "g", "0.5")
+
+	// Replaying the propagated commands, as a replica or a restart would,
+	// keeps the expiry of the field.
+	replica := newTestStore()
+	for _, call := range log.calls {
+		run(replica, call...)
+	}
+	for _, field := range []string{"f", "g"} {
+		want := run(store, "HPEXPIRETIME", "hash", "FIELDS", "1", field)
+		if got := run(replica, "HPEXPIRETIME", "hash", "FIELDS", "1", field); got != want {
+			t.Errorf("%s\nExpected: %q\nGot: %q", field, want, got)
+		}
+	}
+	if got := run(replica, "HGET", "hash", "f"); got != "$3\r\n1.5\r\n" {
+		t.Errorf("Expected: 1.5\nGot: %q", got)
+	}
+}
+
+func TestBlockingTimeout(t *testing.T) {
+	out_of_range := errorReply("ERR timeout is out of range")
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "overflowing timeout", args: []string{"BLPOP", "list", "1e20"}, want: out_of_range},
+		{name: "just past the maximum", args: []string{"BLPOP", "list", "9223372037"}, want: out_of_range},
+		{name: "BZPOPMIN overflowing timeout", args: []string{"BZPOPMIN", "zset", "1e20"}, want: out_of_range},
+		{name: "BLMPOP overflowing timeout", args: []string{"BLMPOP", "1e20", "1", "list", "LEFT"}, want: out_of_range},
+		{name: "negative timeout", args: []string{"BLPOP", "list", "-1"}, want: errorReply("ERR timeout is negative")},
+		{name: "not a float", args: []string{"BLPOP", "list", "soon"}, want: errorReply("ERR timeout is not a float or out of range")},
+		{name: "largest timeout", args: []string{"BLPOP", "list", "9223372036"}, want: "*2\r\n$4\r\nlist\r\n$1\r\na\r\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newTestStore()
+			run(store, "RPUSH", "list", "a")
+			if got := run(store, test.args...); got != test.want {
+				t.Errorf("Expected: %q\nGot: %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestExpireOptions(t *testing.T) {
+	ok := "+OK\r\n"
+	set := []string{"SET", "key", "v"}
+	later := []string{"PEXPIREAT", "key", "4102444800000"}
+	expiretime := []string{"PEXPIRETIME", "key"}
+	not_compatible := errorReply("ERR NX and XX, GT or LT options at the same time are not compatible")
+
+	runSequences(t, []commandSequence{
+		{
+			name:     "NX without an expiry",
+			commands: [][]string{set, {"PEXPIREAT", "key", "4102444800000", "NX"}, expiretime},
+			want:     []string{ok, ":1\r\n", ":4102444800000\r\n"},
+		},
+		{
+			name:     "NX with an expiry",
+			commands: [][]string{set, later, {"PEXPIREAT", "key", "4102444900000", "nx"}, expiretime},
+			want:     []string{ok, ":1\r\n", ":0\r\n", ":4102444800000\r\n"},
+		},
+		{
+			name:     "XX without an expiry",
+			commands: [][]string{set, {"EXPIRE", "key", "100", "XX"}, {"TTL", "key"}},
+			want:     []string{ok, ":0\r\n", ":-1\r\n"},
+		},
+		{
+			name:     "XX with an expiry",
+			commands: [][]string{set, later, {"PEXPIREAT", "key", "4102444900000", "XX"}, expiretime},
+			want:     []string{ok, ":1\r\n", ":1\r\n", ":4102444900000\r\n"},
+		},
+		{
+			name:     "GT without an expiry",
+			commands: [][]string{set, {"EXPIRE", "key", "100", "GT"}, {"TTL", "key"}},
+			want:     []string{ok, ":0\r\n", ":-1\r\n"},
+		},
+		{
+			name:     "GT with a lower expiry",
+			commands: [][]string{set, later, {"PEXPIREAT", "key", "4102444700000", "GT"}, expiretime},
+			want:     []string{ok, ":1\r\n", ":0\r\n", ":4102444800000\r\n"},
+		},
+		{
+			name:     "GT with a higher expiry",
+			commands: [][]string{set, later, {"PEXPIREAT", "key", "4102444900000", "GT"}, expiretime},
+			want:     []string{ok, ":1\r\n", ":1\r\n", ":4102444900000\r\n"},
+		},
+		{
+			name:     "LT without an expiry",
+			commands: [][]string{set, {"EXPIRE", "key", "100", "LT"}, {"TTL", "key"}},
+			want:     []string{ok, ":1\r\n", ":100\r\n"},
+		},
+		{
+			name:     "LT with an equal expiry",
+			commands: [][]string{set, later, {"PEXPIREAT", "key", "4102444800000", "LT"}, expiretime},
+			want:     []string{ok, ":1\r\n", ":0\r\n", ":4102444800000\r\n"},
+		},
+		{
+			name:     "LT with a lower expiry",
+			commands: [][]string{set, later, {"EXPIREAT", "key", "4102444700", "LT"}, expiretime},
+			want:     []string{ok, ":1\r\n", ":1\r\n", ":4102444700000\r\n"},
+		},
+		{
+			name:     "XX and GT",
+			commands: [][]string{set, later, {"PEXPIREAT", "key", "4102444900000", "XX", "GT"}, expiretime},
+			want:     []string{ok, ":1\r\n", ":1\r\n", ":4102444900000\r\n"},
+		},
+		{
+			name:     "NX and XX",
+			commands: [][]string{set, {"EXPIRE", "key", "100", "NX", "XX"}},
+			want:     []string{ok, not_compatible},
+		},
+		{
+			name:     "NX and GT",
+			commands: [][]string{set, {"EXPIRE", "key", "100", "NX", "GT"}},
+			want:     []string{ok, not_compatible},
+		},
+		{
+			name:     "GT and LT",
+			commands: [][]string{set, {"EXPIRE", "key", "100", "GT", "LT"}},
+			want:     []string{ok, errorReply("ERR GT and LT options at the same time are not compatible")},
+		},
+		{
+			name:     "unsupported option",
+			commands: [][]string{set, {"EXPIRE", "key", "100", "KEEPTTL"}},
+			want:     []string{ok, errorReply("ERR Unsupported option KEEPTTL")},
+		},
+		{
+			name:     "options checked before the time",
+			commands: [][]string{{"EXPIRE", "key", "soon", "NX", "LT"}},
+			want:     []string{not_compatible},
+		},
+		{
+			name:     "missing key",
+			commands: [][]string{{"EXPIRE", "key", "100"}, {"EXPIRE", "key", "100", "XX"}, {"TTL", "key"}},
+			want:     []string{":0\r\n", ":0\r\n", ":-2\r\n"},
+		},
+	})
+}
+
+func TestExpireTimes(t *testing.T) {
+	ok := "+OK\r\n"
+	set := []string{"SET", "key", "v"}
+	missing := []string{"GET", "key"}
+
+	runSequences(t, []commandSequence{
+		{
+			name:     "negative EXPIRE",
+			commands: [][]string{set, {"EXPIRE", "key", "-1"}, missing, {"TTL", "key"}},
+			want:     []string{ok, ":1\r\n", "$-1\r\n", ":-2\r\n"},
+		},
+		{
+			name:     "zero PEXPIRE",
+			commands: [][]string{set, {"PEXPIRE", "key", "0"}, missing},
+			want:     []string{ok, ":1\r\n", "$-1\r\n"},
+		},
+		{
+			name:     "EXPIREAT in the past",
+			commands: [][]string{set, {"EXPIREAT", "key", "1"}, missing},
+			want:     []string{ok, ":1\r\n", "$-1\r\n"},
+		},
+		{
+			name:     "PEXPIREAT in the past",
+			commands: [][]string{set, {"PEXPIREAT", "key", "-5"}, missing},
+			want:     []string{ok, ":1\r\n", "$-1\r\n"},
+		},
+		{
+			name:     "EXPIRE overflowing milliseconds",
+			commands: [][]string{set, {"EXPIRE", "key", strconv.Itoa(1<<63 - 1)}, {"TTL", "key"}},
+			want:     []string{ok, errorReply("ERR invalid expire time in 'expire' command"), ":-1\r\n"},
+		},
+		{
+			name:     "EXPIRE overflowing negative milliseconds",
+			commands: [][]string{set, {"EXPIRE", "key", strconv.Itoa(-1 << 63)}},
+			want:     []string{ok, errorReply("ERR invalid expire time in 'expire' command")},
+		},
+		{
+			name:     "EXPIRE overflowing when added to now",
+			commands: [][]string{set, {"EXPIRE", "key", strconv.Itoa((1<<63 - 1) / 1000)}},
+			want:     []string{ok, errorReply("ERR invalid expire time in 'expire' command")},
+		},
+		{
+			name:     "PEXPIRE overflowing when added to now",
+			commands: [][]string{set, {"PEXPIRE", "key", strconv.Itoa(1<<63 - 1)}},
+			want:     []string{ok, errorReply("ERR invalid expire time in 'pexpire' command")},
+		},
+		{
+			name:     "EXPIREAT overflowing milliseconds",
+			commands: [][]string{set, {"EXPIREAT", "key", strconv.Itoa(1<<63 - 1)}},
+			want:     []string{ok, errorReply("ERR invalid expire time in 'expireat' command")},
+		},
+		{
+			name:     "largest PEXPIREAT",
+			commands: [][]string{set, {"PEXPIREAT", "key", strconv.Itoa(1<<63 - 1)}, {"PEXPIRETIME", "key"}},
+			want:     []string{ok, ":1\r\n", ":9223372036854775807\r\n"},
+		},
+		{
+			name:     "not an integer",
+			commands: [][]string{set, {"EXPIRE", "key", "1.5"}},
+			want:     []string{ok, errorReply(errNotInteger.Error())},
+		},
+	})
+}
+
+func TestExpiryReports(t *testing.T) {
+	ok := "+OK\r\n"
+	set := []string{"SET", "key", "v"}
+
+	runSequences(t, []commandSequence{
+		{
+			name:     "missing key",
+			commands: [][]string{{"TTL", "key"}, {"PTTL", "key"}, {"EXPIRETIME", "key"}, {"PEXPIRETIME", "key"}, {"PERSIST", "key"}},
+			want:     []string{":-2\r\n", ":-2\r\n", ":-2\r\n", ":-2\r\n", ":0\r\n"},
+		},
+		{
+			name:     "no expiry",
+			commands: [][]string{set, {"TTL", "key"}, {"PTTL", "key"}, {"EXPIRETIME", "key"}, {"PEXPIRETIME", "key"}, {"PERSIST", "key"}},
+			want:     []string{ok, ":-1\r\n", ":-1\r\n", ":-1\r\n", ":-1\r\n", ":0\r\n"},
+		},
+		{
+			name:     "expiry",
+			commands: [][]string{set, {"EXPIRE", "key", "100"}, {"TTL", "key"}, {"EXPIREAT", "key", "4102444800"}, {"EXPIRETIME", "key"}, {"PEXPIRETIME", "key"}},
+			want:     []string{ok, ":1\r\n", ":100\r\n", ":1\r\n", ":4102444800\r\n", ":4102444800000\r\n"},
+		},
+		{
+			name:     "PERSIST",
+			commands: [][]string{set, {"EXPIRE", "key", "100"}, {"PERSIST", "key"}, {"TTL", "key"}, {"PERSIST", "key"}},
+			want:     []string{ok, ":1\r\n", ":1\r\n", ":-1\r\n", ":0\r\n"},
+		},
+		{
+			name:     "SET clears the expiry",
+			commands: [][]string{set, {"EXPIRE", "key", "100"}, set, {"PTTL", "key"}},
+			want:     []string{ok, ":1\r\n", ok, ":-1\r\n"},
+		},
+	})
+}
@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+var errDBIndexOutOfRange = errors.New("ERR DB index is out of range")
+var errSameObject = errors.New("ERR source and destination objects are the same")
+
+func handleSelectCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 2 {
+		return wrongNumberOfArguments(call)
+	}
+
+	arg, _ := resp.ToString(call[1])
+	db, err := getDB(store, arg)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	conn.DB = db.Index()
+	return resp.SimpleString("OK")
+}
+
+func handleSwapdbCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	first, err := strconv.Atoi(args[0])
+	if err != nil {
+		return resp.SimpleError("ERR invalid first DB index")
+	}
+	second, err := strconv.Atoi(args[1])
+	if err != nil {
+		return resp.SimpleError("ERR invalid second DB index")
+	}
+	if store.DB(first) == nil || store.DB(second) == nil {
+		return resp.SimpleError(errDBIndexOutOfRange.Error())
+	}
+
+	if first != second {
+		store.SwapDB(first, second)
+	}
+	store.PropagateToReplicas(call)
+	return resp.SimpleString("OK")
+}
+
+func handleMoveCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	key := args[0]
+	db, err := getDB(store, args[1])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if db == store {
+		return resp.SimpleError(errSameObject.Error())
+	}
+
+	if !store.Move(key, db) {
+		return resp.Integer(0)
+	}
+	db.SignalKeyReady(key)
+	store.PropagateToReplicas(call)
+	return resp.Integer(1)
+}
+
+func handleFlushdbCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if err := parseFlushMode(call); err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	store.Flush()
+	store.PropagateToReplicas(call)
+	return resp.SimpleString("OK")
+}
+
+func handleFlushallCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if err := parseFlushMode(call); err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	store.FlushAll()
+	store.PropagateToReplicas(call)
+	return resp.SimpleString("OK")
+}
+
+// parseFlushMode checks the optional ASYNC or SYNC argument of FLUSHDB and
+// FLUSHALL. Flushed contents are always released in the background, so both
+// modes behave the same.
+func parseFlushMode(call resp.Array) error {
+	if len(call) > 2 {
+		return errSyntax
+	}
+	if len(call) == 2 {
+		mode, _ := resp.ToString(call[1])
+		if mode := strings.ToUpper(mode); mode != "ASYNC" && mode != "SYNC" {
+			return errSyntax
+		}
+	}
+	return nil
+}
+
+// getDB returns the database with the given index.
+func getDB(store *core.Store, arg string) (*core.Store, error) {
+	index, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, errNotInteger
+	}
+	db := store.DB(index)
+	if db == nil {
+		return nil, errDBIndexOutOfRange
+	}
+	return db, nil
+}
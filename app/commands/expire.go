@@ -0,0 +1,186 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+func handleExpireCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return expireKey(call, store, 1000, false)
+}
+
+func handlePexpireCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return expireKey(call, store, 1, false)
+}
+
+func handleExpireatCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return expireKey(call, store, 1000, true)
+}
+
+func handlePexpireatCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return expireKey(call, store, 1, true)
+}
+
+func handleTtlCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return reportKeyExpiry(call, store, func(expiry int64, now int64) int64 {
+		return (max(expiry-now, 0) + 500) / 1000
+	})
+}
+
+func handlePttlCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return reportKeyExpiry(call, store, func(expiry int64, now int64) int64 {
+		return max(expiry-now, 0)
+	})
+}
+
+func handleExpiretimeCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return reportKeyExpiry(call, store, func(expiry int64, now int64) int64 {
+		return expiry / 1000
+	})
+}
+
+func handlePexpiretimeCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return reportKeyExpiry(call, store, func(expiry int64, now int64) int64 {
+		return expiry
+	})
+}
+
+func handlePersistCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 2 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, _ := resp.ToString(call[1])
+	if !store.Persist(key) {
+		return resp.Integer(0)
+	}
+
+	store.PropagateToReplicas(call)
+	return resp.Integer(1)
+}
+
+// expireKey serves the EXPIRE family. The expiry is given in unit
+// milliseconds, either from now or, when absolute, since the unix epoch.
+func expireKey(call resp.Array, store *core.Store, unit int64, absolute bool) resp.Object {
+	if len(call) < 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	key := args[0]
+
+	// The options are checked before the time, as Redis does.
+	condition, err := parseExpireCondition(args[2:])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	name, _ := GetCommandName(call)
+	invalid_expire_time := fmt.Sprintf("ERR invalid expire time in '%s' command", strings.ToLower(name))
+	when, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return resp.SimpleError(errNotInteger.Error())
+	}
+	if when > math.MaxInt64/unit || when < math.MinInt64/unit {
+		return resp.SimpleError(invalid_expire_time)
+	}
+	expiry := when * unit
+	now := time.Now().UnixMilli()
+	if !absolute {
+		if expiry > math.MaxInt64-now {
+			return resp.SimpleError(invalid_expire_time)
+		}
+		expiry += now
+	}
+
+	if _, ok := store.Get(key); !ok {
+		return resp.Integer(0)
+	}
+
+	current, has_expiry := store.Expiry(key)
+	if !condition.allows(expiry, current, has_expiry) {
+		return resp.Integer(0)
+	}
+
+	if expiry <= now {
+		store.Delete(key)
+		store.PropagateToReplicas(Generate("DEL", key))
+		return resp.Integer(1)
+	}
+
+	store.SetExpiry(key, expiry)
+	// Propagating the absolute expiry keeps replicas in sync no matter when
+	// they apply the command.
+	store.PropagateToReplicas(Generate("PEXPIREAT", key, strconv.FormatInt(expiry, 10)))
+	return resp.Integer(1)
+}
+
+// expireCondition holds the NX, XX, GT and LT options of the EXPIRE family.
+type expireCondition struct {
+	nx bool
+	xx bool
+	gt bool
+	lt bool
+}
+
+func parseExpireCondition(args []string) (expireCondition, error) {
+	condition := expireCondition{}
+	for _, arg := range args {
+		switch strings.ToUpper(arg) {
+		case "NX":
+			condition.nx = true
+		case "XX":
+			condition.xx = true
+		case "GT":
+			condition.gt = true
+		case "LT":
+			condition.lt = true
+		default:
+			return condition, fmt.Errorf("ERR Unsupported option %s", arg)
+		}
+	}
+
+	if condition.nx && (condition.xx || condition.gt || condition.lt) {
+		return condition, errors.New("ERR NX and XX, GT or LT options at the same time are not compatible")
+	}
+	if condition.gt && condition.lt {
+		return condition, errors.New("ERR GT and LT options at the same time are not compatible")
+	}
+	return condition, nil
+}
+
+// allows reports whether a key may be given the new expiry. Keys without an
+// expiry count as expiring at infinity.
+func (c expireCondition) allows(expiry int64, current int64, has_expiry bool) bool {
+	return !(c.nx && has_expiry) &&
+		!(c.xx && !has_expiry) &&
+		!(c.gt && (!has_expiry || expiry <= current)) &&
+		!(c.lt && has_expiry && expiry >= current)
+}
+
+func reportKeyExpiry(call resp.Array, store *core.Store, report func(expiry int64, now int64) int64) resp.Object {
+	if len(call) != 2 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, _ := resp.ToString(call[1])
+	if _, ok := store.Get(key); !ok {
+		return resp.Integer(-2)
+	}
+
+	expiry, ok := store.Expiry(key)
+	if !ok {
+		return resp.Integer(-1)
+	}
+	return resp.Integer(report(expiry, time.Now().UnixMilli()))
+}
@@ -0,0 +1,766 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+func handleHsetCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 4 || len(call)%2 != 0 {
+		return wrongNumberOfArguments(call)
+	}
+
+	added, err := setHashFields(call, store)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	return resp.Integer(added)
+}
+
+func handleHmsetCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 4 || len(call)%2 != 0 {
+		return wrongNumberOfArguments(call)
+	}
+
+	_, err := setHashFields(call, store)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	return resp.SimpleString("OK")
+}
+
+func handleHsetnxCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	key, field, value := args[0], args[1], args[2]
+
+	hash, err := getOrCreateHash(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if _, exists := hash.Get(field); exists {
+		return resp.Integer(0)
+	}
+
+	hash.Set(field, value)
+	store.PropagateToReplicas(call)
+	return resp.Integer(1)
+}
+
+func handleHgetCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+
+	hash, err := getHash(store, args[0])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if hash == nil {
+		return resp.NullBulkString{}
+	}
+
+	value, ok := hash.Get(args[1])
+	if !ok {
+		return resp.NullBulkString{}
+	}
+	return resp.BulkString(value)
+}
+
+func handleHmgetCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+
+	hash, err := getHash(store, args[0])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	res := make(resp.Array, len(args)-1)
+	for i, field := range args[1:] {
+		res[i] = resp.NullBulkString{}
+		if hash == nil {
+			continue
+		}
+		if value, ok := hash.Get(field); ok {
+			res[i] = resp.BulkString(value)
+		}
+	}
+	return res
+}
+
+func handleHdelCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	key := args[0]
+
+	hash, err := getHash(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if hash == nil {
+		return resp.Integer(0)
+	}
+
+	deleted := 0
+	for _, field := range args[1:] {
+		if hash.Delete(field) {
+			deleted++
+		}
+	}
+	if hash.Len() == 0 {
+		store.Delete(key)
+	}
+	if deleted > 0 {
+		store.PropagateToReplicas(call)
+	}
+
+	return resp.Integer(deleted)
+}
+
+func handleHgetallCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return listHash(call, store, true, true)
+}
+
+func handleHkeysCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return listHash(call, store, true, false)
+}
+
+func handleHvalsCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return listHash(call, store, false, true)
+}
+
+func handleHscanCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return scanValue(call, func(key string, cursor uint64, options scanOptions) (uint64, resp.Array, error) {
+		hash, err := getHash(store, key)
+		if hash == nil {
+			return 0, resp.Array{}, err
+		}
+
+		elements := resp.Array{}
+		cursor = hash.Scan(cursor, options.count, func(field string, value string) {
+			if !options.matches(field) {
+				return
+			}
+			elements = append(elements, resp.BulkString(field))
+			if !options.no_values {
+				elements = append(elements, resp.BulkString(value))
+			}
+		})
+		return cursor, elements, nil
+	})
+}
+
+func handleHlenCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 2 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, ok := resp.ToString(call[1])
+	if !ok {
+		return resp.SimpleError("ERR expected a string key")
+	}
+
+	hash, err := getHash(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if hash == nil {
+		return resp.Integer(0)
+	}
+	return resp.Integer(hash.Len())
+}
+
+func handleHexistsCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+
+	hash, err := getHash(store, args[0])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if hash == nil {
+		return resp.Integer(0)
+	}
+	if _, ok := hash.Get(args[1]); !ok {
+		return resp.Integer(0)
+	}
+	return resp.Integer(1)
+}
+
+func handleHstrlenCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+
+	hash, err := getHash(store, args[0])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if hash == nil {
+		return resp.Integer(0)
+	}
+	value, _ := hash.Get(args[1])
+	return resp.Integer(len(value))
+}
+
+func handleHincrbyCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	key, field := args[0], args[1]
+	increment, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return resp.SimpleError(errNotInteger.Error())
+	}
+
+	hash, err := getOrCreateHash(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	var current int64
+	if value, ok := hash.Get(field); ok {
+		current, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			deleteIfEmptyHash(store, key, hash)
+			return resp.SimpleError("ERR hash value is not an integer")
+		}
+	}
+
+	if (increment > 0 && current > math.MaxInt64-increment) || (increment < 0 && current < math.MinInt64-increment) {
+		deleteIfEmptyHash(store, key, hash)
+		return resp.SimpleError("ERR increment or decrement would overflow")
+	}
+
+	current += increment
+	hash.SetKeepTTL(field, strconv.FormatInt(current, 10))
+	store.PropagateToReplicas(call)
+
+	return resp.Integer(current)
+}
+
+func handleHincrbyfloatCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	key, field := args[0], args[1]
+	increment, err := parseFloat(args[2])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	hash, err := getOrCreateHash(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	var current float64
+	if value, ok := hash.Get(field); ok {
+		current, err = parseFloat(value)
+		if err != nil {
+			deleteIfEmptyHash(store, key, hash)
+			return resp.SimpleError("ERR hash value is not a float")
+		}
+	}
+
+	current += increment
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		deleteIfEmptyHash(store, key, hash)
+		return resp.SimpleError("ERR increment would produce NaN or Infinity")
+	}
+
+	value := formatFloat(current)
+	hash.SetKeepTTL(field, value)
+
+	// Replicas must end up with the exact same value, regardless of how
+	// they would round the addition. HSET drops the expiry of the field, so
+	// it is set again.
+	store.PropagateToReplicas(Generate("HSET", key, field, value))
+	if expiry, ok := hash.FieldExpiry(field); ok {
+		store.PropagateToReplicas(generateHashFieldsCall("HPEXPIREAT", key, []string{strconv.FormatInt(expiry, 10)}, []string{field}))
+	}
+
+	return resp.BulkString(value)
+}
+
+func handleHrandfieldCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 2 || len(call) > 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, ok := resp.ToString(call[1])
+	if !ok {
+		return resp.SimpleError("ERR expected a string key")
+	}
+
+	has_count := len(call) > 2
+	count := 1
+	if has_count {
+		count, ok = resp.ToInt(call[2])
+		if !ok {
+			return resp.SimpleError(errNotInteger.Error())
+		}
+		if err := checkRandomCount(count); err != nil {
+			return resp.SimpleError(err.Error())
+		}
+	}
+	with_values := false
+	if len(call) == 4 {
+		option, _ := resp.ToString(call[3])
+		if strings.ToUpper(option) != "WITHVALUES" {
+			return resp.SimpleError(errSyntax.Error())
+		}
+		with_values = true
+	}
+
+	hash, err := getHash(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if hash == nil {
+		if has_count {
+			return resp.Array{}
+		}
+		return resp.NullBulkString{}
+	}
+
+	fields := randomElements(hash.Fields(), count)
+	if !has_count {
+		return resp.BulkString(fields[0])
+	}
+
+	res := resp.Array{}
+	for _, field := range fields {
+		res = append(res, resp.BulkString(field))
+		if with_values {
+			value, _ := hash.Get(field)
+			res = append(res, resp.BulkString(value))
+		}
+	}
+	return res
+}
+
+func handleHexpireCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return expireHashFields(call, store, 1000, false)
+}
+
+func handleHpexpireCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return expireHashFields(call, store, 1, false)
+}
+
+func handleHexpireatCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return expireHashFields(call, store, 1000, true)
+}
+
+func handleHpexpireatCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return expireHashFields(call, store, 1, true)
+}
+
+func handleHttlCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return reportHashFieldExpiry(call, store, func(expiry int64, now int64) int64 {
+		return (expiry - now + 500) / 1000
+	})
+}
+
+func handleHpttlCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return reportHashFieldExpiry(call, store, func(expiry int64, now int64) int64 {
+		return expiry - now
+	})
+}
+
+func handleHexpiretimeCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return reportHashFieldExpiry(call, store, func(expiry int64, now int64) int64 {
+		return expiry / 1000
+	})
+}
+
+func handleHpexpiretimeCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return reportHashFieldExpiry(call, store, func(expiry int64, now int64) int64 {
+		return expiry
+	})
+}
+
+func handleHpersistCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 5 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	key := args[0]
+	fields, err := parseHashFieldsArg(args[1:])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	hash, err := getHash(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	res := make(resp.Array, len(fields))
+	persisted := []string{}
+	for i, field := range fields {
+		res[i] = resp.Integer(-2)
+		if hash == nil {
+			continue
+		}
+		if _, ok := hash.Get(field); !ok {
+			continue
+		}
+		res[i] = resp.Integer(-1)
+		if hash.Persist(field) {
+			res[i] = resp.Integer(1)
+			persisted = append(persisted, field)
+		}
+	}
+
+	if len(persisted) > 0 {
+		store.PropagateToReplicas(generateHashFieldsCall("HPERSIST", key, nil, persisted))
+	}
+	return res
+}
+
+// expireHashFields implements the HEXPIRE family. unit is the number of
+// milliseconds in the unit of the given time, which is a unix timestamp when
+// absolute is set, and relative to now otherwise.
+func expireHashFields(call resp.Array, store *core.Store, unit int64, absolute bool) resp.Object {
+	if len(call) < 6 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	key := args[0]
+
+	name, _ := GetCommandName(call)
+	invalid_expire_time := fmt.Sprintf("ERR invalid expire time in '%s' command", strings.ToLower(name))
+	when, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return resp.SimpleError(errNotInteger.Error())
+	}
+	if when < 0 || when > math.MaxInt64/unit {
+		return resp.SimpleError(invalid_expire_time)
+	}
+	expiry := when * unit
+	now := time.Now().UnixMilli()
+	if !absolute {
+		if expiry > math.MaxInt64-now {
+			return resp.SimpleError(invalid_expire_time)
+		}
+		expiry += now
+	}
+
+	condition := ""
+	rest := args[2:]
+	switch strings.ToUpper(rest[0]) {
+	case "NX", "XX", "GT", "LT":
+		condition = strings.ToUpper(rest[0])
+		rest = rest[1:]
+	}
+	fields, err := parseHashFieldsArg(rest)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	hash, err := getHash(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	res := make(resp.Array, len(fields))
+	updated := []string{}
+	deleted := []string{}
+	for i, field := range fields {
+		res[i] = resp.Integer(-2)
+		if hash == nil {
+			continue
+		}
+		if _, ok := hash.Get(field); !ok {
+			continue
+		}
+
+		current, has_expiry := hash.FieldExpiry(field)
+		if (condition == "NX" && has_expiry) ||
+			(condition == "XX" && !has_expiry) ||
+			(condition == "GT" && (!has_expiry || expiry <= current)) ||
+			(condition == "LT" && has_expiry && expiry >= current) {
+			res[i] = resp.Integer(0)
+			continue
+		}
+
+		if expiry <= now {
+			hash.Delete(field)
+			deleted = append(deleted, field)
+			res[i] = resp.Integer(2)
+			continue
+		}
+
+		hash.SetFieldExpiry(field, expiry)
+		updated = append(updated, field)
+		res[i] = resp.Integer(1)
+	}
+
+	if len(deleted) > 0 {
+		store.PropagateToReplicas(Generate(append([]string{"HDEL", key}, deleted...)...))
+		if hash.Len() == 0 {
+			store.Delete(key)
+		}
+	}
+	if len(updated) > 0 {
+		store.TrackHashFieldExpiry(key)
+		// Propagating the absolute expiry keeps replicas in sync no matter
+		// when they apply the command.
+		store.PropagateToReplicas(generateHashFieldsCall("HPEXPIREAT", key, []string{strconv.FormatInt(expiry, 10)}, updated))
+	}
+
+	return res
+}
+
+// reportHashFieldExpiry implements HTTL and its variants. report converts the
+// absolute expiry of a field, in unix milliseconds, to the reported value.
+func reportHashFieldExpiry(call resp.Array, store *core.Store, report func(expiry int64, now int64) int64) resp.Object {
+	if len(call) < 5 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	fields, err := parseHashFieldsArg(args[1:])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	hash, err := getHash(store, args[0])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	now := time.Now().UnixMilli()
+	res := make(resp.Array, len(fields))
+	for i, field := range fields {
+		res[i] = resp.Integer(-2)
+		if hash == nil {
+			continue
+		}
+		if _, ok := hash.Get(field); !ok {
+			continue
+		}
+		expiry, ok := hash.FieldExpiry(field)
+		if !ok {
+			res[i] = resp.Integer(-1)
+			continue
+		}
+		res[i] = resp.Integer(report(expiry, now))
+	}
+	return res
+}
+
+// parseHashFieldsArg parses the "FIELDS numfields field [field ...]"
+// arguments of the hash field expiry commands.
+func parseHashFieldsArg(args []string) ([]string, error) {
+	if len(args) < 2 || strings.ToUpper(args[0]) != "FIELDS" {
+		return nil, errors.New("ERR Mandatory argument FIELDS is missing or not at the right position")
+	}
+	numfields, err := strconv.Atoi(args[1])
+	if err != nil || numfields <= 0 {
+		return nil, errors.New("ERR Parameter `numFields` should be greater than 0")
+	}
+	if numfields != len(args)-2 {
+		return nil, errors.New("ERR The `numfields` parameter must match the number of arguments")
+	}
+	return args[2:], nil
+}
+
+func generateHashFieldsCall(command string, key string, options []string, fields []string) resp.Array {
+	strs := append([]string{command, key}, options...)
+	strs = append(strs, "FIELDS", strconv.Itoa(len(fields)))
+	strs = append(strs, fields...)
+	return Generate(strs...)
+}
+
+func setHashFields(call resp.Array, store *core.Store) (int, error) {
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return 0, errors.New("ERR expected string arguments")
+	}
+	key := args[0]
+
+	hash, err := getOrCreateHash(store, key)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for i := 1; i < len(args); i += 2 {
+		if hash.Set(args[i], args[i+1]) {
+			added++
+		}
+	}
+
+	store.PropagateToReplicas(call)
+	return added, nil
+}
+
+func listHash(call resp.Array, store *core.Store, with_fields bool, with_values bool) resp.Object {
+	if len(call) != 2 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, ok := resp.ToString(call[1])
+	if !ok {
+		return resp.SimpleError("ERR expected a string key")
+	}
+
+	hash, err := getHash(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	res := resp.Array{}
+	if hash == nil {
+		return res
+	}
+	for _, field := range hash.Fields() {
+		if with_fields {
+			res = append(res, resp.BulkString(field))
+		}
+		if with_values {
+			value, _ := hash.Get(field)
+			res = append(res, resp.BulkString(value))
+		}
+	}
+	return res
+}
+
+// maxRandomCount bounds the counts of random elements, as in Redis. Positive
+// counts are clamped to the number of elements anyway.
+const maxRandomCount = math.MaxInt64 / 2
+
+// maxRandomPicks bounds negative counts of random elements, which reply with
+// exactly that many elements, so that the reply can be built in memory.
+const maxRandomPicks = 1 << 24
+
+var errRandomCount = errors.New("ERR value is out of range")
+
+// checkRandomCount validates the count of random elements to pick.
+func checkRandomCount(count int) error {
+	if count < -maxRandomPicks || count > maxRandomCount {
+		return errRandomCount
+	}
+	return nil
+}
+
+// randomElements picks count distinct elements when count is positive, and
+// exactly -count elements that may repeat when it is negative. The count
+// must have been validated with checkRandomCount.
+func randomElements(elements []string, count int) []string {
+	if count < 0 {
+		picked := make([]string, -count)
+		for i := range picked {
+			picked[i] = elements[rand.Intn(len(elements))]
+		}
+		return picked
+	}
+
+	if count > len(elements) {
+		count = len(elements)
+	}
+	rand.Shuffle(len(elements), func(i, j int) {
+		elements[i], elements[j] = elements[j], elements[i]
+	})
+	return elements[:count]
+}
+
+func getHash(store *core.Store, key string) (*core.Hash, error) {
+	value, ok := store.Get(key)
+	if !ok {
+		return nil, nil
+	}
+	hash, ok := value.(*core.Hash)
+	if !ok {
+		return nil, errWrongType
+	}
+	if !store.ExpireHashFields(key, hash) {
+		return nil, nil
+	}
+	return hash, nil
+}
+
+func getOrCreateHash(store *core.Store, key string) (*core.Hash, error) {
+	hash, err := getHash(store, key)
+	if err != nil {
+		return nil, err
+	}
+	if hash == nil {
+		hash = core.NewHash()
+		store.Set(key, hash)
+	}
+	return hash, nil
+}
+
+// deleteIfEmptyHash drops a hash created by a command that then failed.
+func deleteIfEmptyHash(store *core.Store, key string, hash *core.Hash) {
+	if hash.Len() == 0 {
+		store.Delete(key)
+	}
+}
@@ -0,0 +1,292 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+func handleDelCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return deleteKeys(call, store, store.Delete)
+}
+
+func handleUnlinkCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return deleteKeys(call, store, store.Unlink)
+}
+
+func handleExistsCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return countExistingKeys(call, store)
+}
+
+func handleTouchCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return countExistingKeys(call, store)
+}
+
+func handleRenameCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string keys")
+	}
+	source, destination := args[0], args[1]
+
+	if !store.Rename(source, destination) {
+		return resp.SimpleError("ERR no such key")
+	}
+	store.SignalKeyReady(destination)
+	store.PropagateToReplicas(call)
+
+	return resp.SimpleString("OK")
+}
+
+func handleRenamenxCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string keys")
+	}
+	source, destination := args[0], args[1]
+
+	if _, ok := store.Get(source); !ok {
+		return resp.SimpleError("ERR no such key")
+	}
+	if _, ok := store.Get(destination); ok {
+		return resp.Integer(0)
+	}
+
+	store.Rename(source, destination)
+	store.SignalKeyReady(destination)
+	store.PropagateToReplicas(call)
+
+	return resp.Integer(1)
+}
+
+func handleCopyCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	source, destination := args[0], args[1]
+
+	replace := false
+	db := store
+	for i := 2; i < len(args); i++ {
+		switch {
+		case strings.ToUpper(args[i]) == "REPLACE":
+			replace = true
+		case strings.ToUpper(args[i]) == "DB" && i+1 < len(args):
+			var err error
+			if db, err = getDB(store, args[i+1]); err != nil {
+				return resp.SimpleError(err.Error())
+			}
+			i++
+		default:
+			return resp.SimpleError(errSyntax.Error())
+		}
+	}
+	if db == store && source == destination {
+		return resp.SimpleError(errSameObject.Error())
+	}
+
+	if !store.Copy(source, db, destination, replace) {
+		return resp.Integer(0)
+	}
+	db.SignalKeyReady(destination)
+	store.PropagateToReplicas(call)
+
+	return resp.Integer(1)
+}
+
+func handleRandomkeyCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 1 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, ok := store.RandomKey()
+	if !ok {
+		return resp.NullBulkString{}
+	}
+	return resp.BulkString(key)
+}
+
+func handleDbsizeCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 1 {
+		return wrongNumberOfArguments(call)
+	}
+	return resp.Integer(store.Len())
+}
+
+func handleScanCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 2 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+
+	cursor, options, err := parseScanArgs(args[0], args[1:], "SCAN")
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	cursor, keys := store.Scan(cursor, options.count)
+	matches := resp.Array{}
+	for _, key := range keys {
+		if !options.matches(key) {
+			continue
+		}
+		if options.value_type != "" && store.TypeOfValue(key) != options.value_type {
+			continue
+		}
+		matches = append(matches, resp.BulkString(key))
+	}
+	return scanReply(cursor, matches)
+}
+
+// scanOptions holds the options of SCAN and of the per-type HSCAN, SSCAN and
+// ZSCAN.
+type scanOptions struct {
+	pattern    string
+	count      int
+	value_type string
+	no_values  bool
+}
+
+// parseScanArgs parses the cursor and options of a SCAN family command. TYPE
+// is only accepted by SCAN and NOVALUES only by HSCAN.
+func parseScanArgs(cursor_arg string, args []string, command string) (uint64, scanOptions, error) {
+	options := scanOptions{count: 10}
+	cursor, err := strconv.ParseUint(cursor_arg, 10, 64)
+	if err != nil {
+		return 0, options, errors.New("ERR invalid cursor")
+	}
+
+	for i := 0; i < len(args); i++ {
+		option := strings.ToUpper(args[i])
+		if option == "NOVALUES" && command == "HSCAN" {
+			options.no_values = true
+			continue
+		}
+		if i+1 == len(args) {
+			return 0, options, errSyntax
+		}
+		i++
+		switch {
+		case option == "MATCH":
+			options.pattern = args[i]
+		case option == "COUNT":
+			count, err := strconv.Atoi(args[i])
+			if err != nil {
+				return 0, options, errNotInteger
+			}
+			if count < 1 {
+				return 0, options, errSyntax
+			}
+			options.count = count
+		case option == "TYPE" && command == "SCAN":
+			switch args[i] {
+			case "string", "list", "set", "zset", "hash", "stream":
+			default:
+				return 0, options, fmt.Errorf("ERR unknown type name '%s'", args[i])
+			}
+			options.value_type = args[i]
+		default:
+			return 0, options, errSyntax
+		}
+	}
+	return cursor, options, nil
+}
+
+func (o scanOptions) matches(key string) bool {
+	return o.pattern == "" || o.pattern == "*" || core.GlobMatch(o.pattern, key)
+}
+
+// scanValue serves HSCAN, SSCAN and ZSCAN. scan is called with the cursor
+// and options unless the key is missing, and returns the next cursor along
+// with the matching elements.
+func scanValue(call resp.Array, scan func(key string, cursor uint64, options scanOptions) (uint64, resp.Array, error)) resp.Object {
+	if len(call) < 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+
+	name, _ := GetCommandName(call)
+	cursor, options, err := parseScanArgs(args[1], args[2:], strings.ToUpper(name))
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	cursor, elements, err := scan(args[0], cursor, options)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	return scanReply(cursor, elements)
+}
+
+func scanReply(cursor uint64, elements resp.Array) resp.Array {
+	return resp.Array{resp.BulkString(strconv.FormatUint(cursor, 10)), elements}
+}
+
+func deleteKeys(call resp.Array, store *core.Store, remove func(key string) bool) resp.Object {
+	if len(call) < 2 {
+		return wrongNumberOfArguments(call)
+	}
+
+	keys, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string keys")
+	}
+
+	deleted := 0
+	for _, key := range keys {
+		if remove(key) {
+			deleted++
+		}
+	}
+
+	if deleted > 0 {
+		store.PropagateToReplicas(call)
+	}
+	return resp.Integer(deleted)
+}
+
+// countExistingKeys counts how many of the keys exist. Keys given more than
+// once are counted every time.
+func countExistingKeys(call resp.Array, store *core.Store) resp.Object {
+	if len(call) < 2 {
+		return wrongNumberOfArguments(call)
+	}
+
+	keys, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string keys")
+	}
+
+	count := 0
+	for _, key := range keys {
+		if _, ok := store.Get(key); ok {
+			count++
+		}
+	}
+	return resp.Integer(count)
+}
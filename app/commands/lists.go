@@ -0,0 +1,714 @@
+package commands
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+func handleLpushCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return pushToList(call, store, true, false)
+}
+
+func handleRpushCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return pushToList(call, store, false, false)
+}
+
+func handleLpushxCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return pushToList(call, store, true, true)
+}
+
+func handleRpushxCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return pushToList(call, store, false, true)
+}
+
+func handleLpopCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return popFromList(call, store, true)
+}
+
+func handleRpopCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return popFromList(call, store, false)
+}
+
+func handleLmpopCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	keys, left, count, err := parseMpopArgs(call[1:], parseListSide)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	for _, key := range keys {
+		list, err := getList(store, key)
+		if err != nil {
+			return resp.SimpleError(err.Error())
+		}
+		if list == nil {
+			continue
+		}
+		return popMultipleFromList(store, key, list, left, count)
+	}
+
+	return resp.NullArray{}
+}
+
+func handleLrangeCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, ok := resp.ToString(call[1])
+	if !ok {
+		return resp.SimpleError("ERR expected a string key")
+	}
+	start, ok := resp.ToInt(call[2])
+	if !ok {
+		return resp.SimpleError(errNotInteger.Error())
+	}
+	stop, ok := resp.ToInt(call[3])
+	if !ok {
+		return resp.SimpleError(errNotInteger.Error())
+	}
+
+	list, err := getList(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if list == nil {
+		return resp.Array{}
+	}
+
+	return resp.StringsToArray(list.Range(start, stop))
+}
+
+func handleLindexCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, ok := resp.ToString(call[1])
+	if !ok {
+		return resp.SimpleError("ERR expected a string key")
+	}
+	index, ok := resp.ToInt(call[2])
+	if !ok {
+		return resp.SimpleError(errNotInteger.Error())
+	}
+
+	list, err := getList(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if list == nil {
+		return resp.NullBulkString{}
+	}
+
+	value, ok := list.Index(index)
+	if !ok {
+		return resp.NullBulkString{}
+	}
+	return resp.BulkString(value)
+}
+
+func handleLsetCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, ok := resp.ToString(call[1])
+	if !ok {
+		return resp.SimpleError("ERR expected a string key")
+	}
+	index, ok := resp.ToInt(call[2])
+	if !ok {
+		return resp.SimpleError(errNotInteger.Error())
+	}
+	value, ok := resp.ToString(call[3])
+	if !ok {
+		return resp.SimpleError("ERR expected a string element")
+	}
+
+	list, err := getList(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if list == nil {
+		return resp.SimpleError("ERR no such key")
+	}
+
+	if !list.SetIndex(index, value) {
+		return resp.SimpleError("ERR index out of range")
+	}
+
+	store.PropagateToReplicas(call)
+	return resp.SimpleString("OK")
+}
+
+func handleLremCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, ok := resp.ToString(call[1])
+	if !ok {
+		return resp.SimpleError("ERR expected a string key")
+	}
+	count, ok := resp.ToInt(call[2])
+	if !ok {
+		return resp.SimpleError(errNotInteger.Error())
+	}
+	value, ok := resp.ToString(call[3])
+	if !ok {
+		return resp.SimpleError("ERR expected a string element")
+	}
+
+	list, err := getList(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if list == nil {
+		return resp.Integer(0)
+	}
+
+	removed := list.Remove(count, value)
+	if list.Len() == 0 {
+		store.Delete(key)
+	}
+	if removed > 0 {
+		store.PropagateToReplicas(call)
+	}
+
+	return resp.Integer(removed)
+}
+
+func handleLtrimCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, ok := resp.ToString(call[1])
+	if !ok {
+		return resp.SimpleError("ERR expected a string key")
+	}
+	start, ok := resp.ToInt(call[2])
+	if !ok {
+		return resp.SimpleError(errNotInteger.Error())
+	}
+	stop, ok := resp.ToInt(call[3])
+	if !ok {
+		return resp.SimpleError(errNotInteger.Error())
+	}
+
+	list, err := getList(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if list == nil {
+		return resp.SimpleString("OK")
+	}
+
+	list.Trim(start, stop)
+	if list.Len() == 0 {
+		store.Delete(key)
+	}
+
+	store.PropagateToReplicas(call)
+	return resp.SimpleString("OK")
+}
+
+func handleLinsertCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 5 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	key, where, pivot, value := args[0], strings.ToUpper(args[1]), args[2], args[3]
+	if where != "BEFORE" && where != "AFTER" {
+		return resp.SimpleError(errSyntax.Error())
+	}
+
+	list, err := getList(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if list == nil {
+		return resp.Integer(0)
+	}
+
+	if !list.Insert(pivot, value, where == "BEFORE") {
+		return resp.Integer(-1)
+	}
+
+	store.PropagateToReplicas(call)
+	return resp.Integer(list.Len())
+}
+
+func handleLlenCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 2 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, ok := resp.ToString(call[1])
+	if !ok {
+		return resp.SimpleError("ERR expected a string key")
+	}
+
+	list, err := getList(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if list == nil {
+		return resp.Integer(0)
+	}
+	return resp.Integer(list.Len())
+}
+
+func handleLposCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 3 || len(call)%2 != 1 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, ok := resp.ToString(call[1])
+	if !ok {
+		return resp.SimpleError("ERR expected a string key")
+	}
+	value, ok := resp.ToString(call[2])
+	if !ok {
+		return resp.SimpleError("ERR expected a string element")
+	}
+
+	rank, count, maxlen := 1, -1, 0
+	for i := 3; i < len(call); i += 2 {
+		option, ok := resp.ToString(call[i])
+		if !ok {
+			return resp.SimpleError(errSyntax.Error())
+		}
+		n, ok := resp.ToInt(call[i+1])
+		if !ok {
+			return resp.SimpleError(errNotInteger.Error())
+		}
+		switch strings.ToUpper(option) {
+		case "RANK":
+			if n == 0 {
+				return resp.SimpleError("ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the last match")
+			}
+			rank = n
+		case "COUNT":
+			if n < 0 {
+				return resp.SimpleError("ERR COUNT can't be negative")
+			}
+			count = n
+		case "MAXLEN":
+			if n < 0 {
+				return resp.SimpleError("ERR MAXLEN can't be negative")
+			}
+			maxlen = n
+		default:
+			return resp.SimpleError(errSyntax.Error())
+		}
+	}
+
+	list, err := getList(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	matches := resp.Array{}
+	if list != nil {
+		skip := rank - 1
+		if rank < 0 {
+			skip = -rank - 1
+		}
+		for i := 0; i < list.Len() && (maxlen == 0 || i < maxlen); i++ {
+			index := i
+			if rank < 0 {
+				index = list.Len() - 1 - i
+			}
+			element, _ := list.Index(index)
+			if element != value {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			matches = append(matches, resp.Integer(index))
+			if count == -1 || len(matches) == count {
+				break
+			}
+		}
+	}
+
+	if count != -1 {
+		return matches
+	}
+	if len(matches) == 0 {
+		return resp.NullBulkString{}
+	}
+	return matches[0]
+}
+
+func handleLmoveCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 5 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	from_left, ok := parseListSide(args[2])
+	if !ok {
+		return resp.SimpleError(errSyntax.Error())
+	}
+	to_left, ok := parseListSide(args[3])
+	if !ok {
+		return resp.SimpleError(errSyntax.Error())
+	}
+
+	value, ok, err := moveListElement(store, args[0], args[1], from_left, to_left)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if !ok {
+		return resp.NullBulkString{}
+	}
+
+	store.PropagateToReplicas(call)
+	return resp.BulkString(value)
+}
+
+func handleRpoplpushCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+
+	value, ok, err := moveListElement(store, args[0], args[1], false, true)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if !ok {
+		return resp.NullBulkString{}
+	}
+
+	store.PropagateToReplicas(call)
+	return resp.BulkString(value)
+}
+
+func handleBlpopCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return blockingPopFromList(call, conn, store, true)
+}
+
+func handleBrpopCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return blockingPopFromList(call, conn, store, false)
+}
+
+func handleBlmpopCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 5 {
+		return wrongNumberOfArguments(call)
+	}
+
+	timer, err := parseBlockingTimeout(call[1])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	keys, left, count, err := parseMpopArgs(call[2:], parseListSide)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	serve := func(key string) (resp.Object, bool) {
+		list, err := getList(store, key)
+		if err != nil || list == nil {
+			return nil, false
+		}
+		return popMultipleFromList(store, key, list, left, count), true
+	}
+
+	return blockOnKeys(conn, store, keys, "list", timer, serve, resp.NullArray{})
+}
+
+func handleBlmoveCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 6 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:5])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	from_left, ok := parseListSide(args[2])
+	if !ok {
+		return resp.SimpleError(errSyntax.Error())
+	}
+	to_left, ok := parseListSide(args[3])
+	if !ok {
+		return resp.SimpleError(errSyntax.Error())
+	}
+	timer, err := parseBlockingTimeout(call[5])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	propagated := Generate("LMOVE", args[0], args[1], args[2], args[3])
+	return blockingMoveListElement(conn, store, args[0], args[1], from_left, to_left, timer, propagated)
+}
+
+func handleBrpoplpushCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:3])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	timer, err := parseBlockingTimeout(call[3])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	propagated := Generate("RPOPLPUSH", args[0], args[1])
+	return blockingMoveListElement(conn, store, args[0], args[1], false, true, timer, propagated)
+}
+
+func blockingPopFromList(call resp.Array, conn *core.Conn, store *core.Store, left bool) resp.Object {
+	if len(call) < 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	keys, ok := argsToStrings(call[1 : len(call)-1])
+	if !ok {
+		return resp.SimpleError("ERR expected string keys")
+	}
+	timer, err := parseBlockingTimeout(call[len(call)-1])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	pop_command := "RPOP"
+	if left {
+		pop_command = "LPOP"
+	}
+
+	serve := func(key string) (resp.Object, bool) {
+		list, err := getList(store, key)
+		if err != nil || list == nil {
+			return nil, false
+		}
+		values := popListElements(store, key, list, left, 1)
+		store.PropagateToReplicas(Generate(pop_command, key))
+		return resp.Array{resp.BulkString(key), resp.BulkString(values[0])}, true
+	}
+
+	return blockOnKeys(conn, store, keys, "list", timer, serve, resp.NullArray{})
+}
+
+func blockingMoveListElement(conn *core.Conn, store *core.Store, source string, destination string, from_left bool, to_left bool, timer <-chan time.Time, propagated resp.Array) resp.Object {
+	if _, err := getList(store, destination); err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	serve := func(key string) (resp.Object, bool) {
+		value, ok, err := moveListElement(store, source, destination, from_left, to_left)
+		if err != nil || !ok {
+			return nil, false
+		}
+		store.PropagateToReplicas(propagated)
+		return resp.BulkString(value), true
+	}
+
+	return blockOnKeys(conn, store, []string{source}, "list", timer, serve, resp.NullBulkString{})
+}
+
+func pushToList(call resp.Array, store *core.Store, left bool, only_existing bool) resp.Object {
+	if len(call) < 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, ok := resp.ToString(call[1])
+	if !ok {
+		return resp.SimpleError("ERR expected a string key")
+	}
+	values, ok := argsToStrings(call[2:])
+	if !ok {
+		return resp.SimpleError("ERR expected string elements")
+	}
+
+	list, err := getList(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if list == nil {
+		if only_existing {
+			return resp.Integer(0)
+		}
+		list = core.NewList()
+		store.Set(key, list)
+	}
+
+	if left {
+		list.PushLeft(values...)
+	} else {
+		list.PushRight(values...)
+	}
+
+	store.SignalKeyReady(key)
+	store.PropagateToReplicas(call)
+	return resp.Integer(list.Len())
+}
+
+func popFromList(call resp.Array, store *core.Store, left bool) resp.Object {
+	if len(call) != 2 && len(call) != 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, ok := resp.ToString(call[1])
+	if !ok {
+		return resp.SimpleError("ERR expected a string key")
+	}
+
+	has_count := len(call) == 3
+	count := 1
+	if has_count {
+		count, ok = resp.ToInt(call[2])
+		if !ok || count < 0 {
+			return resp.SimpleError("ERR value is out of range, must be positive")
+		}
+	}
+
+	list, err := getList(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if list == nil {
+		if has_count {
+			return resp.NullArray{}
+		}
+		return resp.NullBulkString{}
+	}
+
+	values := popListElements(store, key, list, left, count)
+	if len(values) > 0 {
+		store.PropagateToReplicas(call)
+	}
+
+	if !has_count {
+		return resp.BulkString(values[0])
+	}
+	return resp.StringsToArray(values)
+}
+
+// popMultipleFromList pops up to count elements for LMPOP and BLMPOP, which
+// are propagated as the equivalent LPOP or RPOP.
+func popMultipleFromList(store *core.Store, key string, list *core.List, left bool, count int) resp.Object {
+	values := popListElements(store, key, list, left, count)
+
+	pop_command := "RPOP"
+	if left {
+		pop_command = "LPOP"
+	}
+	store.PropagateToReplicas(Generate(pop_command, key, strconv.Itoa(len(values))))
+
+	return resp.Array{resp.BulkString(key), resp.StringsToArray(values)}
+}
+
+// popListElements pops up to count elements from one end of the list and
+// removes the key from the store once the list is empty.
+func popListElements(store *core.Store, key string, list *core.List, left bool, count int) []string {
+	values := make([]string, 0, count)
+	for len(values) < count {
+		var value string
+		var ok bool
+		if left {
+			value, ok = list.PopLeft()
+		} else {
+			value, ok = list.PopRight()
+		}
+		if !ok {
+			break
+		}
+		values = append(values, value)
+	}
+
+	if list.Len() == 0 {
+		store.Delete(key)
+	}
+	return values
+}
+
+// moveListElement pops an element from the source list and pushes it to the
+// destination list. It reports false when the source list does not exist.
+func moveListElement(store *core.Store, source string, destination string, from_left bool, to_left bool) (string, bool, error) {
+	src, err := getList(store, source)
+	if err != nil {
+		return "", false, err
+	}
+	dst, err := getList(store, destination)
+	if err != nil {
+		return "", false, err
+	}
+	if src == nil {
+		return "", false, nil
+	}
+
+	var value string
+	if from_left {
+		value, _ = src.PopLeft()
+	} else {
+		value, _ = src.PopRight()
+	}
+
+	if dst == nil {
+		dst = core.NewList()
+		store.Set(destination, dst)
+	}
+	if to_left {
+		dst.PushLeft(value)
+	} else {
+		dst.PushRight(value)
+	}
+
+	if src.Len() == 0 {
+		store.Delete(source)
+	}
+
+	store.SignalKeyReady(destination)
+	return value, true, nil
+}
+
+func parseListSide(side string) (left bool, ok bool) {
+	switch strings.ToUpper(side) {
+	case "LEFT":
+		return true, true
+	case "RIGHT":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+func getList(store *core.Store, key string) (*core.List, error) {
+	value, ok := store.Get(key)
+	if !ok {
+		return nil, nil
+	}
+	list, ok := value.(*core.List)
+	if !ok {
+		return nil, errWrongType
+	}
+	return list, nil
+}
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/rdb"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+func handleSaveCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 1 {
+		return wrongNumberOfArguments(call)
+	}
+
+	if err := rdb.Save(store); err != nil {
+		if err == rdb.ErrSaveInProgress {
+			return resp.SimpleError(err.Error())
+		}
+		return resp.SimpleError("ERR " + err.Error())
+	}
+	return resp.SimpleString("OK")
+}
+
+func handleBgsaveCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) > 2 {
+		return wrongNumberOfArguments(call)
+	}
+
+	schedule := false
+	if len(call) == 2 {
+		option, _ := resp.ToString(call[1])
+		if strings.ToUpper(option) != "SCHEDULE" {
+			return resp.SimpleError(errSyntax.Error())
+		}
+		schedule = true
+	}
+
+	if err := rdb.BackgroundSave(store); err != nil {
+		if !schedule {
+			return resp.SimpleError(err.Error())
+		}
+		store.ScheduleBackgroundSave()
+		return resp.SimpleString("Background saving scheduled")
+	}
+	return resp.SimpleString("Background saving started")
+}
+
+func handleLastsaveCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 1 {
+		return wrongNumberOfArguments(call)
+	}
+
+	return resp.Integer(store.SaveStats().LastSave.Unix())
+}
+
+func handleBgrewriteaofCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 1 {
+		return wrongNumberOfArguments(call)
+	}
+
+	log := store.AppendOnlyLog()
+	if log == nil {
+		return resp.SimpleError("ERR append only file is disabled, start the server with --appendonly yes")
+	}
+	if err := log.Rewrite(store); err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	return resp.SimpleString("Background append only file rewriting started")
+}
+
+// handleDebugCommand supports DEBUG RELOAD, which saves the data and loads
+// it back from the RDB file, through the same code as a restart.
+func handleDebugCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 2 {
+		return wrongNumberOfArguments(call)
+	}
+	subcommand, _ := resp.ToString(call[1])
+	if strings.ToUpper(subcommand) != "RELOAD" {
+		return resp.SimpleError(fmt.Sprintf("ERR unknown subcommand '%s'. Try DEBUG HELP.", subcommand))
+	}
+	if len(call) != 2 {
+		return resp.SimpleError(errSyntax.Error())
+	}
+
+	if err := rdb.Save(store); err != nil {
+		fmt.Fprintf(os.Stderr, "DEBUG RELOAD failed to save the DB: %v\n", err)
+		return resp.SimpleError("ERR Error trying to save the DB")
+	}
+	store.FlushAll()
+	if err := rdb.ReadFile(rdb.Filename(store), store); err != nil {
+		fmt.Fprintf(os.Stderr, "DEBUG RELOAD failed to load the RDB file: %v\n", err)
+		return resp.SimpleError("ERR Error trying to load the RDB dump, check server logs.")
+	}
+	return resp.SimpleString("OK")
+}
+
+func infoPersistence(store *core.Store) []string {
+	stats := store.SaveStats()
+	status := "ok"
+	if stats.LastError != nil {
+		status = "err"
+	}
+	in_progress := 0
+	if stats.InProgress {
+		in_progress = 1
+	}
+	fields := []string{
+		"loading:0",
+		fmt.Sprintf("rdb_changes_since_last_save:%d", stats.Dirty),
+		fmt.Sprintf("rdb_bgsave_in_progress:%d", in_progress),
+		fmt.Sprintf("rdb_last_save_time:%d", stats.LastSave.Unix()),
+		"rdb_last_bgsave_status:" + status,
+	}
+
+	log := store.AppendOnlyLog()
+	if log == nil {
+		return append(fields, "aof_enabled:0")
+	}
+	aof_stats := log.Stats()
+	rewrite_in_progress := 0
+	if aof_stats.RewriteInProgress {
+		rewrite_in_progress = 1
+	}
+	rewrite_status := "ok"
+	if aof_stats.LastRewriteError != nil {
+		rewrite_status = "err"
+	}
+	return append(fields,
+		"aof_enabled:1",
+		fmt.Sprintf("aof_rewrite_in_progress:%d", rewrite_in_progress),
+		"aof_last_bgrewrite_status:"+rewrite_status,
+		fmt.Sprintf("aof_current_size:%d", aof_stats.CurrentSize),
+		fmt.Sprintf("aof_base_size:%d", aof_stats.BaseSize),
+	)
+}
@@ -43,6 +43,10 @@ func handleWaitCommand(call resp.Array, conn *core.Conn, store *core.Store) resp
 			replica.Mu.Unlock()
 		}
 	}
+	// The ACKs are handled as commands on the replica connections, so the
+	// store must be released while waiting for them.
+	store.Unlock()
+	defer store.Lock()
 	for replicatation_count < numreplicas && !timed_out {
 		select {
 		case <-timer:
@@ -118,7 +122,7 @@ func handlePsyncCommand(call resp.Array, conn *core.Conn, store *core.Store) res
 	}
 	res := resp.SimpleString(strings.Join(strs, " "))
 	conn.Write(res.Encode())
-	sendCurrentState(conn)
+	sendCurrentState(conn, store)
 	conn.Mu.Lock()
 	conn.Total_propagated = 0
 	conn.Offset = 0
@@ -0,0 +1,517 @@
+package commands
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+type setOperation func(sets []*core.Set) *core.Set
+
+func handleSaddCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	key := args[0]
+
+	set, err := getSet(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if set == nil {
+		set = core.NewSet()
+		store.Set(key, set)
+	}
+
+	added := 0
+	for _, member := range args[1:] {
+		if set.Add(member) {
+			added++
+		}
+	}
+
+	if added > 0 {
+		store.PropagateToReplicas(call)
+	}
+	return resp.Integer(added)
+}
+
+func handleSremCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	key := args[0]
+
+	set, err := getSet(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if set == nil {
+		return resp.Integer(0)
+	}
+
+	removed := 0
+	for _, member := range args[1:] {
+		if set.Remove(member) {
+			removed++
+		}
+	}
+	if set.Len() == 0 {
+		store.Delete(key)
+	}
+
+	if removed > 0 {
+		store.PropagateToReplicas(call)
+	}
+	return resp.Integer(removed)
+}
+
+func handleSmembersCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 2 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, ok := resp.ToString(call[1])
+	if !ok {
+		return resp.SimpleError("ERR expected a string key")
+	}
+
+	set, err := getSet(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if set == nil {
+		return resp.Array{}
+	}
+	return resp.StringsToArray(set.Members())
+}
+
+func handleSismemberCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+
+	set, err := getSet(store, args[0])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if set == nil || !set.Contains(args[1]) {
+		return resp.Integer(0)
+	}
+	return resp.Integer(1)
+}
+
+func handleSmismemberCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+
+	set, err := getSet(store, args[0])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	res := make(resp.Array, len(args)-1)
+	for i, member := range args[1:] {
+		res[i] = resp.Integer(0)
+		if set != nil && set.Contains(member) {
+			res[i] = resp.Integer(1)
+		}
+	}
+	return res
+}
+
+func handleScardCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 2 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, ok := resp.ToString(call[1])
+	if !ok {
+		return resp.SimpleError("ERR expected a string key")
+	}
+
+	set, err := getSet(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if set == nil {
+		return resp.Integer(0)
+	}
+	return resp.Integer(set.Len())
+}
+
+func handleSscanCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return scanValue(call, func(key string, cursor uint64, options scanOptions) (uint64, resp.Array, error) {
+		set, err := getSet(store, key)
+		if set == nil {
+			return 0, resp.Array{}, err
+		}
+
+		elements := resp.Array{}
+		cursor = set.Scan(cursor, options.count, func(member string) {
+			if options.matches(member) {
+				elements = append(elements, resp.BulkString(member))
+			}
+		})
+		return cursor, elements, nil
+	})
+}
+
+func handleSpopCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 2 && len(call) != 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, ok := resp.ToString(call[1])
+	if !ok {
+		return resp.SimpleError("ERR expected a string key")
+	}
+
+	has_count := len(call) == 3
+	count := 1
+	if has_count {
+		count, ok = resp.ToInt(call[2])
+		if !ok || count < 0 {
+			return resp.SimpleError("ERR value is out of range, must be positive")
+		}
+	}
+
+	set, err := getSet(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if set == nil {
+		if has_count {
+			return resp.Array{}
+		}
+		return resp.NullBulkString{}
+	}
+
+	members := randomElements(set.Members(), count)
+	for _, member := range members {
+		set.Remove(member)
+	}
+	if set.Len() == 0 {
+		store.Delete(key)
+	}
+
+	// The popped members are random, so replicas are told which ones to remove.
+	if len(members) > 0 {
+		store.PropagateToReplicas(Generate(append([]string{"SREM", key}, members...)...))
+	}
+
+	if !has_count {
+		return resp.BulkString(members[0])
+	}
+	return resp.StringsToArray(members)
+}
+
+func handleSrandmemberCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 2 && len(call) != 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, ok := resp.ToString(call[1])
+	if !ok {
+		return resp.SimpleError("ERR expected a string key")
+	}
+
+	has_count := len(call) == 3
+	count := 1
+	if has_count {
+		count, ok = resp.ToInt(call[2])
+		if !ok {
+			return resp.SimpleError(errNotInteger.Error())
+		}
+		if err := checkRandomCount(count); err != nil {
+			return resp.SimpleError(err.Error())
+		}
+	}
+
+	set, err := getSet(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if set == nil {
+		if has_count {
+			return resp.Array{}
+		}
+		return resp.NullBulkString{}
+	}
+
+	members := randomElements(set.Members(), count)
+	if !has_count {
+		return resp.BulkString(members[0])
+	}
+	return resp.StringsToArray(members)
+}
+
+func handleSmoveCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	source, destination, member := args[0], args[1], args[2]
+
+	src, err := getSet(store, source)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	dst, err := getSet(store, destination)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	if src == nil || !src.Contains(member) {
+		return resp.Integer(0)
+	}
+	if src == dst {
+		return resp.Integer(1)
+	}
+
+	src.Remove(member)
+	if src.Len() == 0 {
+		store.Delete(source)
+	}
+	if dst == nil {
+		dst = core.NewSet()
+		store.Set(destination, dst)
+	}
+	dst.Add(member)
+
+	store.PropagateToReplicas(call)
+	return resp.Integer(1)
+}
+
+func handleSinterCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return replySetOperation(call, store, intersectSets)
+}
+
+func handleSunionCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return replySetOperation(call, store, unionSets)
+}
+
+func handleSdiffCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return replySetOperation(call, store, diffSets)
+}
+
+func handleSinterstoreCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return storeSetOperation(call, store, intersectSets)
+}
+
+func handleSunionstoreCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return storeSetOperation(call, store, unionSets)
+}
+
+func handleSdiffstoreCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return storeSetOperation(call, store, diffSets)
+}
+
+func handleSintercardCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+
+	numkeys, err := strconv.Atoi(args[0])
+	if err != nil || numkeys <= 0 {
+		return resp.SimpleError("ERR numkeys should be greater than 0")
+	}
+	if numkeys > len(args)-1 {
+		return resp.SimpleError("ERR Number of keys can't be greater than number of args")
+	}
+
+	limit := 0
+	rest := args[numkeys+1:]
+	if len(rest) != 0 {
+		if len(rest) != 2 || !strings.EqualFold(rest[0], "LIMIT") {
+			return resp.SimpleError(errSyntax.Error())
+		}
+		limit, err = strconv.Atoi(rest[1])
+		if err != nil {
+			return resp.SimpleError(errNotInteger.Error())
+		}
+		if limit < 0 {
+			return resp.SimpleError("ERR LIMIT can't be negative")
+		}
+	}
+
+	sets, err := getSets(store, args[1:numkeys+1])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	return resp.Integer(len(intersection(sets, limit)))
+}
+
+func replySetOperation(call resp.Array, store *core.Store, operation setOperation) resp.Object {
+	if len(call) < 2 {
+		return wrongNumberOfArguments(call)
+	}
+
+	keys, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string keys")
+	}
+
+	sets, err := getSets(store, keys)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	return resp.StringsToArray(operation(sets).Members())
+}
+
+func storeSetOperation(call resp.Array, store *core.Store, operation setOperation) resp.Object {
+	if len(call) < 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	keys, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string keys")
+	}
+	destination := keys[0]
+
+	sets, err := getSets(store, keys[1:])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	result := operation(sets)
+	store.Delete(destination)
+	if result.Len() > 0 {
+		store.Set(destination, result)
+	}
+
+	store.PropagateToReplicas(call)
+	return resp.Integer(result.Len())
+}
+
+func intersectSets(sets []*core.Set) *core.Set {
+	result := core.NewSet()
+	for _, member := range intersection(sets, 0) {
+		result.Add(member)
+	}
+	return result
+}
+
+// intersection returns the members common to all the sets, stopping once
+// limit members are found unless limit is zero. It walks the smallest set
+// and looks its members up in the others.
+func intersection(sets []*core.Set, limit int) []string {
+	sorted := append([]*core.Set{}, sets...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Len() < sorted[j].Len()
+	})
+
+	members := []string{}
+	if len(sorted) == 0 || sorted[0].Len() == 0 {
+		return members
+	}
+	for _, member := range sorted[0].Members() {
+		in_all := true
+		for _, set := range sorted[1:] {
+			if !set.Contains(member) {
+				in_all = false
+				break
+			}
+		}
+		if !in_all {
+			continue
+		}
+		members = append(members, member)
+		if limit != 0 && len(members) == limit {
+			break
+		}
+	}
+	return members
+}
+
+func unionSets(sets []*core.Set) *core.Set {
+	result := core.NewSet()
+	for _, set := range sets {
+		for _, member := range set.Members() {
+			result.Add(member)
+		}
+	}
+	return result
+}
+
+func diffSets(sets []*core.Set) *core.Set {
+	result := core.NewSet()
+	for _, member := range sets[0].Members() {
+		in_other := false
+		for _, set := range sets[1:] {
+			if set.Contains(member) {
+				in_other = true
+				break
+			}
+		}
+		if !in_other {
+			result.Add(member)
+		}
+	}
+	return result
+}
+
+// getSets looks up the sets stored at the given keys, treating missing keys
+// as empty sets.
+func getSets(store *core.Store, keys []string) ([]*core.Set, error) {
+	sets := make([]*core.Set, len(keys))
+	for i, key := range keys {
+		set, err := getSet(store, key)
+		if err != nil {
+			return nil, err
+		}
+		if set == nil {
+			set = core.NewSet()
+		}
+		sets[i] = set
+	}
+	return sets, nil
+}
+
+func getSet(store *core.Store, key string) (*core.Set, error) {
+	value, ok := store.Get(key)
+	if !ok {
+		return nil, nil
+	}
+	set, ok := value.(*core.Set)
+	if !ok {
+		return nil, errWrongType
+	}
+	return set, nil
+}
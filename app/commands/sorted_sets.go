@@ -0,0 +1,1087 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+var errNotFloatRange = errors.New("ERR min or max is not a float")
+var errNotLexRange = errors.New("ERR min or max not valid string range item")
+var errScoreNaN = errors.New("ERR resulting score is not a number (NaN)")
+
+type zsetAggregate func(a float64, b float64) float64
+
+type sortedSetOperation func(zsets []*core.SortedSet, weights []float64, aggregate zsetAggregate) *core.SortedSet
+
+// sortedSetOperationArgs holds the parsed arguments of ZUNION, ZINTER, ZDIFF
+// and their STORE variants.
+type sortedSetOperationArgs struct {
+	keys        []string
+	weights     []float64
+	aggregate   zsetAggregate
+	with_scores bool
+}
+
+type zrangeType int
+
+const (
+	zrangeByRank zrangeType = iota
+	zrangeByScore
+	zrangeByLex
+)
+
+// zrangeSpec describes a range query on a sorted set, as given to ZRANGE and
+// the older commands it unifies.
+type zrangeSpec struct {
+	by          zrangeType
+	reverse     bool
+	start       int
+	stop        int
+	min_score   core.ScoreBound
+	max_score   core.ScoreBound
+	min_lex     core.LexBound
+	max_lex     core.LexBound
+	offset      int
+	count       int
+	has_limit   bool
+	with_scores bool
+}
+
+func handleZaddCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	key := args[0]
+
+	var nx, xx, gt, lt, ch, incr bool
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		case "CH":
+			ch = true
+		case "INCR":
+			incr = true
+		default:
+			break options
+		}
+	}
+
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return resp.SimpleError(errSyntax.Error())
+	}
+	if nx && xx {
+		return resp.SimpleError("ERR XX and NX options at the same time are not compatible")
+	}
+	if (gt && lt) || (nx && (gt || lt)) {
+		return resp.SimpleError("ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	if incr && len(pairs) > 2 {
+		return resp.SimpleError("ERR INCR option supports a single increment-element pair")
+	}
+
+	scores := make([]float64, len(pairs)/2)
+	for j := range scores {
+		score, err := parseFloat(pairs[2*j])
+		if err != nil {
+			return resp.SimpleError(err.Error())
+		}
+		scores[j] = score
+	}
+
+	zset, err := getSortedSet(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	created := zset == nil
+	if created {
+		zset = core.NewSortedSet()
+	}
+
+	added, changed := 0, 0
+	var result float64
+	var updated bool
+	for j, score := range scores {
+		member := pairs[2*j+1]
+		current, exists := zset.Score(member)
+		if (nx && exists) || (xx && !exists) {
+			continue
+		}
+		if incr && exists {
+			score += current
+			if math.IsNaN(score) {
+				return resp.SimpleError(errScoreNaN.Error())
+			}
+		}
+		if exists && ((gt && score <= current) || (lt && score >= current)) {
+			continue
+		}
+
+		result, updated = score, true
+		if !exists {
+			added++
+		} else if score != current {
+			changed++
+		}
+		zset.Add(member, score)
+	}
+
+	if created && zset.Len() > 0 {
+		store.Set(key, zset)
+	}
+	if added+changed > 0 {
+		store.SignalKeyReady(key)
+		store.PropagateToReplicas(call)
+	}
+
+	if incr {
+		if !updated {
+			return resp.NullBulkString{}
+		}
+		return resp.BulkString(formatScore(result))
+	}
+	if ch {
+		return resp.Integer(added + changed)
+	}
+	return resp.Integer(added)
+}
+
+func handleZincrbyCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	key, member := args[0], args[2]
+	increment, err := parseFloat(args[1])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	zset, err := getSortedSet(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	score := increment
+	if zset != nil {
+		current, _ := zset.Score(member)
+		score += current
+		if math.IsNaN(score) {
+			return resp.SimpleError(errScoreNaN.Error())
+		}
+	} else {
+		zset = core.NewSortedSet()
+		store.Set(key, zset)
+	}
+
+	zset.Add(member, score)
+	store.SignalKeyReady(key)
+	store.PropagateToReplicas(call)
+
+	return resp.BulkString(formatScore(score))
+}
+
+func handleZremCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	key := args[0]
+
+	zset, err := getSortedSet(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if zset == nil {
+		return resp.Integer(0)
+	}
+
+	removed := 0
+	for _, member := range args[1:] {
+		if zset.Remove(member) {
+			removed++
+		}
+	}
+	if zset.Len() == 0 {
+		store.Delete(key)
+	}
+
+	if removed > 0 {
+		store.PropagateToReplicas(call)
+	}
+	return resp.Integer(removed)
+}
+
+func handleZcardCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 2 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, _ := resp.ToString(call[1])
+	zset, err := getSortedSet(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if zset == nil {
+		return resp.Integer(0)
+	}
+	return resp.Integer(zset.Len())
+}
+
+func handleZscanCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return scanValue(call, func(key string, cursor uint64, options scanOptions) (uint64, resp.Array, error) {
+		sorted_set, err := getSortedSet(store, key)
+		if sorted_set == nil {
+			return 0, resp.Array{}, err
+		}
+
+		elements := resp.Array{}
+		cursor = sorted_set.Scan(cursor, options.count, func(entry core.ZEntry) {
+			if options.matches(entry.Member) {
+				elements = append(elements, resp.BulkString(entry.Member), resp.BulkString(formatScore(entry.Score)))
+			}
+		})
+		return cursor, elements, nil
+	})
+}
+
+func handleZscoreCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+
+	zset, err := getSortedSet(store, args[0])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if zset == nil {
+		return resp.NullBulkString{}
+	}
+
+	score, ok := zset.Score(args[1])
+	if !ok {
+		return resp.NullBulkString{}
+	}
+	return resp.BulkString(formatScore(score))
+}
+
+func handleZmscoreCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+
+	zset, err := getSortedSet(store, args[0])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	res := make(resp.Array, len(args)-1)
+	for i, member := range args[1:] {
+		res[i] = resp.NullBulkString{}
+		if zset == nil {
+			continue
+		}
+		if score, ok := zset.Score(member); ok {
+			res[i] = resp.BulkString(formatScore(score))
+		}
+	}
+	return res
+}
+
+func handleZrankCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return rankInSortedSet(call, store, false)
+}
+
+func handleZrevrankCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return rankInSortedSet(call, store, true)
+}
+
+func handleZcountCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+
+	min, err := parseScoreBound(args[1])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	max, err := parseScoreBound(args[2])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	zset, err := getSortedSet(store, args[0])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if zset == nil {
+		return resp.Integer(0)
+	}
+	return resp.Integer(zset.CountByScore(min, max))
+}
+
+func handleZlexcountCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+
+	min, err := parseLexBound(args[1])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	max, err := parseLexBound(args[2])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	zset, err := getSortedSet(store, args[0])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if zset == nil {
+		return resp.Integer(0)
+	}
+	return resp.Integer(zset.CountByLex(min, max))
+}
+
+func handleZrangeCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return rangeSortedSet(call, store, zrangeByRank, false, true)
+}
+
+func handleZrevrangeCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return rangeSortedSet(call, store, zrangeByRank, true, false)
+}
+
+func handleZrangebyscoreCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return rangeSortedSet(call, store, zrangeByScore, false, false)
+}
+
+func handleZrevrangebyscoreCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return rangeSortedSet(call, store, zrangeByScore, true, false)
+}
+
+func handleZrangebylexCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return rangeSortedSet(call, store, zrangeByLex, false, false)
+}
+
+func handleZrevrangebylexCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return rangeSortedSet(call, store, zrangeByLex, true, false)
+}
+
+func handleZrangestoreCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 5 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	destination, source := args[0], args[1]
+
+	spec, err := parseZrangeSpec(args[2:], zrangeByRank, false, true, true)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	zset, err := getSortedSet(store, source)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	result := core.NewSortedSet()
+	if zset != nil {
+		for _, entry := range spec.apply(zset) {
+			result.Add(entry.Member, entry.Score)
+		}
+	}
+
+	storeSortedSet(store, destination, result)
+	store.PropagateToReplicas(call)
+	return resp.Integer(result.Len())
+}
+
+func handleZunionCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return replySortedSetOperation(call, store, unionSortedSets, true)
+}
+
+func handleZinterCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return replySortedSetOperation(call, store, intersectSortedSets, true)
+}
+
+func handleZdiffCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return replySortedSetOperation(call, store, diffSortedSets, false)
+}
+
+func handleZunionstoreCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return storeSortedSetOperation(call, store, unionSortedSets, true)
+}
+
+func handleZinterstoreCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return storeSortedSetOperation(call, store, intersectSortedSets, true)
+}
+
+func handleZdiffstoreCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return storeSortedSetOperation(call, store, diffSortedSets, false)
+}
+
+func handleZpopminCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return popFromSortedSet(call, store, false)
+}
+
+func handleZpopmaxCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return popFromSortedSet(call, store, true)
+}
+
+func handleZmpopCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	keys, min, count, err := parseMpopArgs(call[1:], parseSortedSetSide)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	for _, key := range keys {
+		zset, err := getSortedSet(store, key)
+		if err != nil {
+			return resp.SimpleError(err.Error())
+		}
+		if zset == nil {
+			continue
+		}
+		return popMultipleFromSortedSet(store, key, zset, !min, count)
+	}
+
+	return resp.NullArray{}
+}
+
+func handleBzpopminCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return blockingPopFromSortedSet(call, conn, store, false)
+}
+
+func handleBzpopmaxCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	return blockingPopFromSortedSet(call, conn, store, true)
+}
+
+func handleBzmpopCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 5 {
+		return wrongNumberOfArguments(call)
+	}
+
+	timer, err := parseBlockingTimeout(call[1])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	keys, min, count, err := parseMpopArgs(call[2:], parseSortedSetSide)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	serve := func(key string) (resp.Object, bool) {
+		zset, err := getSortedSet(store, key)
+		if err != nil || zset == nil {
+			return nil, false
+		}
+		return popMultipleFromSortedSet(store, key, zset, !min, count), true
+	}
+
+	return blockOnKeys(conn, store, keys, "zset", timer, serve, resp.NullArray{})
+}
+
+func rankInSortedSet(call resp.Array, store *core.Store, reverse bool) resp.Object {
+	if len(call) != 3 && len(call) != 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	with_score := false
+	if len(args) == 3 {
+		if strings.ToUpper(args[2]) != "WITHSCORE" {
+			return resp.SimpleError(errSyntax.Error())
+		}
+		with_score = true
+	}
+
+	zset, err := getSortedSet(store, args[0])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if zset == nil {
+		if with_score {
+			return resp.NullArray{}
+		}
+		return resp.NullBulkString{}
+	}
+
+	rank, ok := zset.Rank(args[1], reverse)
+	if !ok {
+		if with_score {
+			return resp.NullArray{}
+		}
+		return resp.NullBulkString{}
+	}
+	if with_score {
+		score, _ := zset.Score(args[1])
+		return resp.Array{resp.Integer(rank), resp.BulkString(formatScore(score))}
+	}
+	return resp.Integer(rank)
+}
+
+// rangeSortedSet serves ZRANGE and its older variants. The unified form
+// accepts BYSCORE, BYLEX and REV in place of a dedicated command.
+func rangeSortedSet(call resp.Array, store *core.Store, by zrangeType, reverse bool, unified bool) resp.Object {
+	if len(call) < 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+
+	spec, err := parseZrangeSpec(args[1:], by, reverse, unified, false)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	zset, err := getSortedSet(store, args[0])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if zset == nil {
+		return resp.Array{}
+	}
+
+	return zentriesToArray(spec.apply(zset), spec.with_scores)
+}
+
+// parseZrangeSpec parses the arguments of a range query following the key.
+// Options that select the range type and direction are only accepted by the
+// unified ZRANGE syntax, and WITHSCORES is not accepted when storing.
+func parseZrangeSpec(args []string, by zrangeType, reverse bool, unified bool, store bool) (zrangeSpec, error) {
+	spec := zrangeSpec{by: by, reverse: reverse, count: -1}
+
+	for i := 2; i < len(args); i++ {
+		option := strings.ToUpper(args[i])
+		switch {
+		case option == "WITHSCORES" && !store:
+			spec.with_scores = true
+		case option == "LIMIT" && i+2 < len(args):
+			offset, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return spec, errNotInteger
+			}
+			count, err := strconv.Atoi(args[i+2])
+			if err != nil {
+				return spec, errNotInteger
+			}
+			spec.offset, spec.count, spec.has_limit = offset, count, true
+			i += 2
+		case option == "REV" && unified:
+			spec.reverse = true
+		case option == "BYSCORE" && unified && spec.by == zrangeByRank:
+			spec.by = zrangeByScore
+		case option == "BYLEX" && unified && spec.by == zrangeByRank:
+			spec.by = zrangeByLex
+		default:
+			return spec, errSyntax
+		}
+	}
+
+	if spec.has_limit && spec.by == zrangeByRank {
+		return spec, errors.New("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if spec.with_scores && spec.by == zrangeByLex {
+		return spec, errors.New("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+
+	// Score and lex ranges are given from max to min when reversed.
+	min, max := args[0], args[1]
+	if spec.reverse && spec.by != zrangeByRank {
+		min, max = max, min
+	}
+
+	var err error
+	switch spec.by {
+	case zrangeByRank:
+		if spec.start, err = strconv.Atoi(min); err != nil {
+			return spec, errNotInteger
+		}
+		if spec.stop, err = strconv.Atoi(max); err != nil {
+			return spec, errNotInteger
+		}
+	case zrangeByScore:
+		if spec.min_score, err = parseScoreBound(min); err != nil {
+			return spec, err
+		}
+		if spec.max_score, err = parseScoreBound(max); err != nil {
+			return spec, err
+		}
+	case zrangeByLex:
+		if spec.min_lex, err = parseLexBound(min); err != nil {
+			return spec, err
+		}
+		if spec.max_lex, err = parseLexBound(max); err != nil {
+			return spec, err
+		}
+	}
+	return spec, nil
+}
+
+func (spec zrangeSpec) apply(zset *core.SortedSet) []core.ZEntry {
+	switch spec.by {
+	case zrangeByScore:
+		return zset.RangeByScore(spec.min_score, spec.max_score, spec.reverse, spec.offset, spec.count)
+	case zrangeByLex:
+		return zset.RangeByLex(spec.min_lex, spec.max_lex, spec.reverse, spec.offset, spec.count)
+	default:
+		return zset.RangeByRank(spec.start, spec.stop, spec.reverse)
+	}
+}
+
+// parseScoreBound parses a score range item such as "1", "(1" or "-inf".
+func parseScoreBound(str string) (core.ScoreBound, error) {
+	bound := core.ScoreBound{}
+	if strings.HasPrefix(str, "(") {
+		bound.Exclusive = true
+		str = str[1:]
+	}
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil || math.IsNaN(value) {
+		return bound, errNotFloatRange
+	}
+	bound.Value = value
+	return bound, nil
+}
+
+// parseLexBound parses a lex range item such as "[a", "(a", "-" or "+".
+func parseLexBound(str string) (core.LexBound, error) {
+	switch {
+	case str == "-":
+		return core.LexBound{Infinite: -1}, nil
+	case str == "+":
+		return core.LexBound{Infinite: 1}, nil
+	case strings.HasPrefix(str, "["):
+		return core.LexBound{Value: str[1:]}, nil
+	case strings.HasPrefix(str, "("):
+		return core.LexBound{Value: str[1:], Exclusive: true}, nil
+	default:
+		return core.LexBound{}, errNotLexRange
+	}
+}
+
+// formatScore formats a score the way Redis replies with them: in plain
+// decimal notation unless the exponent is very large or small.
+func formatScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	}
+	abs := math.Abs(score)
+	if abs != 0 && (abs < 1e-5 || abs >= 1e21) {
+		return strconv.FormatFloat(score, 'g', -1, 64)
+	}
+	return formatFloat(score)
+}
+
+func zentriesToArray(entries []core.ZEntry, with_scores bool) resp.Array {
+	res := make(resp.Array, 0, len(entries))
+	for _, entry := range entries {
+		res = append(res, resp.BulkString(entry.Member))
+		if with_scores {
+			res = append(res, resp.BulkString(formatScore(entry.Score)))
+		}
+	}
+	return res
+}
+
+func popFromSortedSet(call resp.Array, store *core.Store, max bool) resp.Object {
+	if len(call) != 2 && len(call) != 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, _ := resp.ToString(call[1])
+	count := 1
+	if len(call) == 3 {
+		var ok bool
+		count, ok = resp.ToInt(call[2])
+		if !ok {
+			return resp.SimpleError(errNotInteger.Error())
+		}
+		if count < 0 {
+			return resp.SimpleError("ERR value is out of range, must be positive")
+		}
+	}
+
+	zset, err := getSortedSet(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if zset == nil {
+		return resp.Array{}
+	}
+
+	entries := popSortedSetEntries(store, key, zset, max, count)
+	if len(entries) > 0 {
+		store.PropagateToReplicas(call)
+	}
+	return zentriesToArray(entries, true)
+}
+
+func blockingPopFromSortedSet(call resp.Array, conn *core.Conn, store *core.Store, max bool) resp.Object {
+	if len(call) < 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	keys, ok := argsToStrings(call[1 : len(call)-1])
+	if !ok {
+		return resp.SimpleError("ERR expected string keys")
+	}
+	timer, err := parseBlockingTimeout(call[len(call)-1])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	pop_command := "ZPOPMIN"
+	if max {
+		pop_command = "ZPOPMAX"
+	}
+
+	serve := func(key string) (resp.Object, bool) {
+		zset, err := getSortedSet(store, key)
+		if err != nil || zset == nil {
+			return nil, false
+		}
+		entry := popSortedSetEntries(store, key, zset, max, 1)[0]
+		store.PropagateToReplicas(Generate(pop_command, key))
+		return resp.Array{resp.BulkString(key), resp.BulkString(entry.Member), resp.BulkString(formatScore(entry.Score))}, true
+	}
+
+	return blockOnKeys(conn, store, keys, "zset", timer, serve, resp.NullArray{})
+}
+
+func popMultipleFromSortedSet(store *core.Store, key string, zset *core.SortedSet, max bool, count int) resp.Object {
+	entries := popSortedSetEntries(store, key, zset, max, count)
+
+	pop_command := "ZPOPMIN"
+	if max {
+		pop_command = "ZPOPMAX"
+	}
+	store.PropagateToReplicas(Generate(pop_command, key, strconv.Itoa(len(entries))))
+
+	elements := make(resp.Array, len(entries))
+	for i, entry := range entries {
+		elements[i] = resp.Array{resp.BulkString(entry.Member), resp.BulkString(formatScore(entry.Score))}
+	}
+	return resp.Array{resp.BulkString(key), elements}
+}
+
+// popSortedSetEntries removes up to count entries with the lowest scores, or
+// the highest when max is set, deleting the key once the sorted set is empty.
+func popSortedSetEntries(store *core.Store, key string, zset *core.SortedSet, max bool, count int) []core.ZEntry {
+	if count <= 0 {
+		return nil
+	}
+
+	entries := zset.RangeByRank(0, count-1, max)
+	for _, entry := range entries {
+		zset.Remove(entry.Member)
+	}
+	if zset.Len() == 0 {
+		store.Delete(key)
+	}
+	return entries
+}
+
+func parseSortedSetSide(side string) (min bool, ok bool) {
+	switch strings.ToUpper(side) {
+	case "MIN":
+		return true, true
+	case "MAX":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+func replySortedSetOperation(call resp.Array, store *core.Store, operation sortedSetOperation, weighted bool) resp.Object {
+	if len(call) < 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+
+	op, err := parseSortedSetOperation(call, args, weighted, false)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	zsets, err := getSortedSetsForOperation(store, op.keys)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	result := operation(zsets, op.weights, op.aggregate)
+	return zentriesToArray(result.Entries(), op.with_scores)
+}
+
+func storeSortedSetOperation(call resp.Array, store *core.Store, operation sortedSetOperation, weighted bool) resp.Object {
+	if len(call) < 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	destination := args[0]
+
+	op, err := parseSortedSetOperation(call, args[1:], weighted, true)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	zsets, err := getSortedSetsForOperation(store, op.keys)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	result := operation(zsets, op.weights, op.aggregate)
+	storeSortedSet(store, destination, result)
+
+	store.PropagateToReplicas(call)
+	return resp.Integer(result.Len())
+}
+
+// parseSortedSetOperation parses the "numkeys key [key ...]" arguments of
+// the sorted set algebra commands, followed by WEIGHTS and AGGREGATE when
+// weighted, and WITHSCORES unless the result is stored.
+func parseSortedSetOperation(call resp.Array, args []string, weighted bool, store bool) (sortedSetOperationArgs, error) {
+	op := sortedSetOperationArgs{aggregate: aggregateSum}
+
+	numkeys, err := strconv.Atoi(args[0])
+	if err != nil {
+		return op, errNotInteger
+	}
+	if numkeys < 1 {
+		name, _ := GetCommandName(call)
+		return op, fmt.Errorf("ERR at least 1 input key is needed for '%s' command", strings.ToLower(name))
+	}
+	if numkeys > len(args)-1 {
+		return op, errSyntax
+	}
+	op.keys = args[1 : numkeys+1]
+
+	op.weights = make([]float64, numkeys)
+	for i := range op.weights {
+		op.weights[i] = 1
+	}
+
+	for i := numkeys + 1; i < len(args); i++ {
+		option := strings.ToUpper(args[i])
+		switch {
+		case option == "WEIGHTS" && weighted && i+numkeys < len(args):
+			for j := range op.weights {
+				weight, err := strconv.ParseFloat(args[i+1+j], 64)
+				if err != nil || math.IsNaN(weight) {
+					return op, errors.New("ERR weight value is not a float")
+				}
+				op.weights[j] = weight
+			}
+			i += numkeys
+		case option == "AGGREGATE" && weighted && i+1 < len(args):
+			switch strings.ToUpper(args[i+1]) {
+			case "SUM":
+				op.aggregate = aggregateSum
+			case "MIN":
+				op.aggregate = math.Min
+			case "MAX":
+				op.aggregate = math.Max
+			default:
+				return op, errSyntax
+			}
+			i++
+		case option == "WITHSCORES" && !store:
+			op.with_scores = true
+		default:
+			return op, errSyntax
+		}
+	}
+
+	return op, nil
+}
+
+func unionSortedSets(zsets []*core.SortedSet, weights []float64, aggregate zsetAggregate) *core.SortedSet {
+	scores := make(map[string]float64)
+	for i, zset := range zsets {
+		for _, entry := range zset.Entries() {
+			score := weightedScore(entry.Score, weights[i])
+			if current, ok := scores[entry.Member]; ok {
+				score = aggregate(current, score)
+			}
+			scores[entry.Member] = score
+		}
+	}
+	return sortedSetFromScores(scores)
+}
+
+func intersectSortedSets(zsets []*core.SortedSet, weights []float64, aggregate zsetAggregate) *core.SortedSet {
+	scores := make(map[string]float64)
+members:
+	for _, entry := range zsets[0].Entries() {
+		score := weightedScore(entry.Score, weights[0])
+		for i, zset := range zsets[1:] {
+			other, ok := zset.Score(entry.Member)
+			if !ok {
+				continue members
+			}
+			score = aggregate(score, weightedScore(other, weights[i+1]))
+		}
+		scores[entry.Member] = score
+	}
+	return sortedSetFromScores(scores)
+}
+
+func diffSortedSets(zsets []*core.SortedSet, weights []float64, aggregate zsetAggregate) *core.SortedSet {
+	result := core.NewSortedSet()
+members:
+	for _, entry := range zsets[0].Entries() {
+		for _, zset := range zsets[1:] {
+			if _, ok := zset.Score(entry.Member); ok {
+				continue members
+			}
+		}
+		result.Add(entry.Member, entry.Score)
+	}
+	return result
+}
+
+// aggregateSum adds two scores, where the sum of infinities with opposite
+// signs counts as zero.
+func aggregateSum(a float64, b float64) float64 {
+	sum := a + b
+	if math.IsNaN(sum) {
+		return 0
+	}
+	return sum
+}
+
+func weightedScore(score float64, weight float64) float64 {
+	weighted := score * weight
+	if math.IsNaN(weighted) {
+		return 0
+	}
+	return weighted
+}
+
+func sortedSetFromScores(scores map[string]float64) *core.SortedSet {
+	zset := core.NewSortedSet()
+	for member, score := range scores {
+		zset.Add(member, score)
+	}
+	return zset
+}
+
+// storeSortedSet replaces the value at key with the sorted set, deleting the
+// key instead when the sorted set is empty.
+func storeSortedSet(store *core.Store, key string, zset *core.SortedSet) {
+	store.Delete(key)
+	if zset.Len() > 0 {
+		store.Set(key, zset)
+		store.SignalKeyReady(key)
+	}
+}
+
+// getSortedSetsForOperation looks up the inputs of the sorted set algebra
+// commands. Missing keys count as empty sorted sets, and members of plain
+// sets have a score of 1.
+func getSortedSetsForOperation(store *core.Store, keys []string) ([]*core.SortedSet, error) {
+	zsets := make([]*core.SortedSet, len(keys))
+	for i, key := range keys {
+		value, ok := store.Get(key)
+		switch value := value.(type) {
+		case *core.SortedSet:
+			zsets[i] = value
+		case *core.Set:
+			zset := core.NewSortedSet()
+			for _, member := range value.Members() {
+				zset.Add(member, 1)
+			}
+			zsets[i] = zset
+		default:
+			if ok {
+				return nil, errWrongType
+			}
+			zsets[i] = core.NewSortedSet()
+		}
+	}
+	return zsets, nil
+}
+
+func getSortedSet(store *core.Store, key string) (*core.SortedSet, error) {
+	value, ok := store.Get(key)
+	if !ok {
+		return nil, nil
+	}
+	zset, ok := value.(*core.SortedSet)
+	if !ok {
+		return nil, errWrongType
+	}
+	return zset, nil
+}
@@ -57,6 +57,12 @@ func handleXaddCommand(call resp.Array, conn *core.Conn, store *core.Store) resp
 	stream.AddEntry(id, data)
 	stream.Mu.Unlock()
 	store.Set(key, stream)
+	store.SignalKeyReady(key)
+
+	// Replicas must add the entry with the same id, whatever their clock.
+	propagated := append(resp.Array{}, call...)
+	propagated[2] = resp.BulkString(id)
+	store.PropagateToReplicas(propagated)
 
 	res := resp.BulkString(id)
 	return res
@@ -171,6 +177,16 @@ func handleXreadCommand(call resp.Array, conn *core.Conn, store *core.Store) res
 		}
 
 		stream_raw, ok := store.Get(key)
+		if !ok && is_blocking {
+			// A blocking read waits for the stream to be created.
+			if id == "$" {
+				id = "0-0"
+			}
+			keys = append(keys, key)
+			streams = append(streams, nil)
+			ids = append(ids, id)
+			continue
+		}
 		if !ok {
 			return resp.SimpleError("ERR key does not exist in store")
 		}
@@ -193,7 +209,7 @@ func handleXreadCommand(call resp.Array, conn *core.Conn, store *core.Store) res
 
 	var res resp.Object
 	if is_blocking {
-		res = blockStreamsRead(keys, streams, ids, timer)
+		res = blockStreamsRead(conn, store, keys, ids, timer)
 	} else {
 		res = readFromStreams(keys, streams, ids)
 	}
@@ -201,36 +217,58 @@ func handleXreadCommand(call resp.Array, conn *core.Conn, store *core.Store) res
 	return res
 }
 
-func blockStreamsRead(keys []string, streams []*resp.Stream, ids []string, timer <-chan time.Time) resp.Object {
-	for {
-		select {
-		case <-timer:
-			return resp.NullBulkString{}
-		default:
-			reads := resp.Array{}
-			for i := 0; i < len(streams); i++ {
-				key := keys[i]
-				stream := streams[i]
-				id := ids[i]
-
-				entries := readStreamEntries(stream, id)
-
-				if len(entries) == 0 {
-					continue
-				}
-
-				stream_read := resp.Array{}
-				stream_read = append(stream_read, resp.BulkString(key))
-				stream_read = append(stream_read, entries)
-
-				reads = append(reads, stream_read)
-			}
+func blockStreamsRead(conn *core.Conn, store *core.Store, keys []string, ids []string, timer <-chan time.Time) resp.Object {
+	read := func(key string, id string) (resp.Array, bool) {
+		stream_raw, ok := store.Get(key)
+		if !ok {
+			return nil, false
+		}
+		stream, ok := stream_raw.(*resp.Stream)
+		if !ok {
+			return nil, false
+		}
 
-			if len(reads) != 0 {
-				return reads
+		entries := readStreamEntries(stream, id)
+		if len(entries) == 0 {
+			return nil, false
+		}
+
+		stream_read := resp.Array{}
+		stream_read = append(stream_read, resp.BulkString(key))
+		stream_read = append(stream_read, entries)
+		return stream_read, true
+	}
+
+	reads := resp.Array{}
+	for i := 0; i < len(keys); i++ {
+		if stream_read, ok := read(keys[i], ids[i]); ok {
+			reads = append(reads, stream_read)
+		}
+	}
+	if len(reads) != 0 {
+		return reads
+	}
+	if conn.Executing {
+		return resp.NullBulkString{}
+	}
+
+	serve := func(key string) (resp.Object, bool) {
+		for i := 0; i < len(keys); i++ {
+			if keys[i] != key {
+				continue
+			}
+			if stream_read, ok := read(key, ids[i]); ok {
+				return resp.Array{stream_read}, true
 			}
 		}
+		return nil, false
+	}
+
+	res, ok := store.BlockOnKeys(conn, keys, timer, serve)
+	if !ok {
+		return resp.NullBulkString{}
 	}
+	return res
 }
 
 func readFromStreams(keys []string, streams []*resp.Stream, ids []string) resp.Array {
@@ -322,8 +360,8 @@ func processStreamID(stream *resp.Stream, id string) (string, error) {
 	}
 
 	top_id := "0-0"
-	if len(stream.Entries) != 0 {
-		top_id = stream.Entries[len(stream.Entries)-1].Id
+	if stream.LastID != "" {
+		top_id = stream.LastID
 	}
 
 	id_split := strings.Split(id, "-")
@@ -0,0 +1,621 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// maxStringLength is the largest string value that can be built by APPEND
+// and SETRANGE, matching the default proto-max-bulk-len of Redis.
+const maxStringLength = 512 * 1024 * 1024
+
+var errStringTooLong = errors.New("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+
+func handleAppendCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	key, suffix := args[0], args[1]
+
+	value, _, err := getString(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if len(value)+len(suffix) > maxStringLength {
+		return resp.SimpleError(errStringTooLong.Error())
+	}
+
+	value += suffix
+	store.SetKeepTTL(key, resp.BulkString(value))
+	store.PropagateToReplicas(call)
+
+	return resp.Integer(len(value))
+}
+
+func handleStrlenCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 2 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, _ := resp.ToString(call[1])
+	value, _, err := getString(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	return resp.Integer(len(value))
+}
+
+func handleGetrangeCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, _ := resp.ToString(call[1])
+	start, ok := resp.ToInt(call[2])
+	if !ok {
+		return resp.SimpleError(errNotInteger.Error())
+	}
+	end, ok := resp.ToInt(call[3])
+	if !ok {
+		return resp.SimpleError(errNotInteger.Error())
+	}
+
+	value, _, err := getString(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	length := len(value)
+	if start < 0 && end < 0 && start > end {
+		return resp.BulkString("")
+	}
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= length {
+		end = length - 1
+	}
+	if length == 0 || start > end {
+		return resp.BulkString("")
+	}
+	return resp.BulkString(value[start : end+1])
+}
+
+func handleSetrangeCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 4 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	key, patch := args[0], args[2]
+	offset, err := strconv.Atoi(args[1])
+	if err != nil {
+		return resp.SimpleError(errNotInteger.Error())
+	}
+	if offset < 0 {
+		return resp.SimpleError("ERR offset is out of range")
+	}
+
+	value, exists, err := getString(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if len(patch) == 0 {
+		// Nothing to write, so missing keys are not created either.
+		return resp.Integer(len(value))
+	}
+	if offset > maxStringLength-len(patch) {
+		return resp.SimpleError(errStringTooLong.Error())
+	}
+
+	buf := []byte(value)
+	if offset+len(patch) > len(buf) {
+		buf = append(buf, make([]byte, offset+len(patch)-len(buf))...)
+	}
+	copy(buf[offset:], patch)
+
+	if exists {
+		store.SetKeepTTL(key, resp.BulkString(buf))
+	} else {
+		store.Set(key, resp.BulkString(buf))
+	}
+	store.PropagateToReplicas(call)
+
+	return resp.Integer(len(buf))
+}
+
+func handleMgetCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 2 {
+		return wrongNumberOfArguments(call)
+	}
+
+	keys, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string keys")
+	}
+
+	res := make(resp.Array, len(keys))
+	for i, key := range keys {
+		res[i] = resp.NullBulkString{}
+		if value, ok, err := getString(store, key); ok && err == nil {
+			res[i] = resp.BulkString(value)
+		}
+	}
+	return res
+}
+
+func handleMsetCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 3 || len(call)%2 != 1 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		store.Set(args[i], resp.BulkString(args[i+1]))
+	}
+	store.PropagateToReplicas(call)
+
+	return resp.SimpleString("OK")
+}
+
+func handleMsetnxCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 3 || len(call)%2 != 1 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		if _, exists := store.Get(args[i]); exists {
+			return resp.Integer(0)
+		}
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		store.Set(args[i], resp.BulkString(args[i+1]))
+	}
+	store.PropagateToReplicas(call)
+
+	return resp.Integer(1)
+}
+
+func handleGetdelCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 2 {
+		return wrongNumberOfArguments(call)
+	}
+
+	key, _ := resp.ToString(call[1])
+	value, ok, err := getString(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if !ok {
+		return resp.NullBulkString{}
+	}
+
+	store.Delete(key)
+	store.PropagateToReplicas(Generate("DEL", key))
+
+	return resp.BulkString(value)
+}
+
+func handleGetexCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 2 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	key := args[0]
+
+	var expiry int64
+	persist := false
+	if len(args) > 1 {
+		option := strings.ToUpper(args[1])
+		switch {
+		case option == "PERSIST" && len(args) == 2:
+			persist = true
+		case (option == "EX" || option == "PX" || option == "EXAT" || option == "PXAT") && len(args) == 3:
+			var err error
+			expiry, err = parseExpireTime(option, args[2], "getex")
+			if err != nil {
+				return resp.SimpleError(err.Error())
+			}
+		default:
+			return resp.SimpleError(errSyntax.Error())
+		}
+	}
+
+	value, ok, err := getString(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if !ok {
+		return resp.NullBulkString{}
+	}
+
+	switch {
+	case persist:
+		if store.Persist(key) {
+			store.PropagateToReplicas(Generate("PERSIST", key))
+		}
+	case expiry != 0 && expiry <= time.Now().UnixMilli():
+		store.Delete(key)
+		store.PropagateToReplicas(Generate("DEL", key))
+	case expiry != 0:
+		store.SetExpiry(key, expiry)
+		store.PropagateToReplicas(Generate("PEXPIREAT", key, strconv.FormatInt(expiry, 10)))
+	}
+
+	return resp.BulkString(value)
+}
+
+func handleGetsetCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	key := args[0]
+
+	old, exists, err := getString(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	store.Set(key, resp.BulkString(args[1]))
+	store.PropagateToReplicas(Generate("SET", key, args[1]))
+
+	if !exists {
+		return resp.NullBulkString{}
+	}
+	return resp.BulkString(old)
+}
+
+func handleLcsCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) < 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+
+	var get_len, get_idx, with_match_len bool
+	min_match_len := 0
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "LEN":
+			get_len = true
+		case "IDX":
+			get_idx = true
+		case "WITHMATCHLEN":
+			with_match_len = true
+		case "MINMATCHLEN":
+			if i+1 >= len(args) {
+				return resp.SimpleError(errSyntax.Error())
+			}
+			value, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return resp.SimpleError(errNotInteger.Error())
+			}
+			min_match_len = max(value, 0)
+			i++
+		default:
+			return resp.SimpleError(errSyntax.Error())
+		}
+	}
+	if get_len && get_idx {
+		return resp.SimpleError("ERR If you want both the length and indexes, please just use IDX.")
+	}
+
+	a, _, err := getString(store, args[0])
+	if err != nil {
+		return resp.SimpleError("ERR The specified keys must contain string values")
+	}
+	b, _, err := getString(store, args[1])
+	if err != nil {
+		return resp.SimpleError("ERR The specified keys must contain string values")
+	}
+	if (len(a)+1)*(len(b)+1)*4 > maxStringLength {
+		return resp.SimpleError("ERR Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len")
+	}
+
+	lcs, matches := longestCommonSubsequence(a, b)
+	switch {
+	case get_len:
+		return resp.Integer(len(lcs))
+	case !get_idx:
+		return resp.BulkString(lcs)
+	}
+
+	ranges := make(resp.Array, 0, len(matches))
+	for _, match := range matches {
+		if match.length < min_match_len {
+			continue
+		}
+		item := resp.Array{
+			resp.Array{resp.Integer(match.a_start), resp.Integer(match.a_start + match.length - 1)},
+			resp.Array{resp.Integer(match.b_start), resp.Integer(match.b_start + match.length - 1)},
+		}
+		if with_match_len {
+			item = append(item, resp.Integer(match.length))
+		}
+		ranges = append(ranges, item)
+	}
+	return resp.Array{resp.BulkString("matches"), ranges, resp.BulkString("len"), resp.Integer(len(lcs))}
+}
+
+func handleIncrCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 2 {
+		return wrongNumberOfArguments(call)
+	}
+	return incrementBy(call, store, 1)
+}
+
+func handleDecrCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 2 {
+		return wrongNumberOfArguments(call)
+	}
+	return incrementBy(call, store, -1)
+}
+
+func handleIncrbyCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	str, _ := resp.ToString(call[2])
+	increment, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return resp.SimpleError(errNotInteger.Error())
+	}
+	return incrementBy(call, store, increment)
+}
+
+func handleDecrbyCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	str, _ := resp.ToString(call[2])
+	decrement, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return resp.SimpleError(errNotInteger.Error())
+	}
+	if decrement == math.MinInt64 {
+		return resp.SimpleError("ERR decrement would overflow")
+	}
+	return incrementBy(call, store, -decrement)
+}
+
+func handleIncrbyfloatCommand(call resp.Array, conn *core.Conn, store *core.Store) resp.Object {
+	if len(call) != 3 {
+		return wrongNumberOfArguments(call)
+	}
+
+	args, ok := argsToStrings(call[1:])
+	if !ok {
+		return resp.SimpleError("ERR expected string arguments")
+	}
+	key := args[0]
+	increment, err := parseFloat(args[1])
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+
+	str, exists, err := getString(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	var current float64
+	if exists {
+		current, err = parseFloat(str)
+		if err != nil {
+			return resp.SimpleError(err.Error())
+		}
+	}
+
+	result := current + increment
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return resp.SimpleError("ERR increment would produce NaN or Infinity")
+	}
+
+	// The result is propagated as a SET so that replicas do not end up with
+	// a different value because of floating point rounding.
+	value := formatFloat(result)
+	store.SetKeepTTL(key, resp.BulkString(value))
+	store.PropagateToReplicas(Generate("SET", key, value, "KEEPTTL"))
+
+	return resp.BulkString(value)
+}
+
+// incrementBy adds increment to the integer stored at the key of the call,
+// keeping the expiry of the key.
+func incrementBy(call resp.Array, store *core.Store, increment int64) resp.Object {
+	key, _ := resp.ToString(call[1])
+
+	current, err := getInteger(store, key)
+	if err != nil {
+		return resp.SimpleError(err.Error())
+	}
+	if (increment > 0 && current > math.MaxInt64-increment) || (increment < 0 && current < math.MinInt64-increment) {
+		return resp.SimpleError("ERR increment or decrement would overflow")
+	}
+
+	current += increment
+	store.SetKeepTTL(key, core.Int(current))
+	store.PropagateToReplicas(call)
+
+	return resp.Integer(current)
+}
+
+// lcsMatch is a run of bytes common to both strings, at a_start in the first
+// and b_start in the second.
+type lcsMatch struct {
+	a_start int
+	b_start int
+	length  int
+}
+
+// longestCommonSubsequence returns the longest common subsequence of a and b
+// along with the contiguous runs it is made of, from the end of the strings
+// to their start as LCS IDX reports them.
+func longestCommonSubsequence(a string, b string) (string, []lcsMatch) {
+	width := len(b) + 1
+	table := make([]uint32, (len(a)+1)*width)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				table[i*width+j] = table[(i-1)*width+j-1] + 1
+			} else {
+				table[i*width+j] = max(table[(i-1)*width+j], table[i*width+j-1])
+			}
+		}
+	}
+
+	length := int(table[len(a)*width+len(b)])
+	lcs := make([]byte, length)
+	matches := make([]lcsMatch, 0)
+
+	i, j := len(a), len(b)
+	var current *lcsMatch
+	for i > 0 && j > 0 {
+		if a[i-1] == b[j-1] {
+			lcs[length-1] = a[i-1]
+			length--
+			i--
+			j--
+			if current != nil && current.a_start == i+1 && current.b_start == j+1 {
+				current.a_start, current.b_start = i, j
+				current.length++
+			} else {
+				if current != nil {
+					matches = append(matches, *current)
+				}
+				current = &lcsMatch{a_start: i, b_start: j, length: 1}
+			}
+			continue
+		}
+
+		if table[(i-1)*width+j] > table[i*width+j-1] {
+			i--
+		} else {
+			j--
+		}
+		if current != nil {
+			matches = append(matches, *current)
+			current = nil
+		}
+	}
+	if current != nil {
+		matches = append(matches, *current)
+	}
+
+	return string(lcs), matches
+}
+
+// parseExpireTime converts the argument of an EX, PX, EXAT or PXAT option to
+// a unix timestamp in milliseconds.
+func parseExpireTime(option string, arg string, command string) (int64, error) {
+	value, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return 0, errNotInteger
+	}
+
+	invalid := fmt.Errorf("ERR invalid expire time in '%s' command", command)
+	if value <= 0 {
+		return 0, invalid
+	}
+
+	if option == "EX" || option == "EXAT" {
+		if value > math.MaxInt64/1000 {
+			return 0, invalid
+		}
+		value *= 1000
+	}
+	if option == "EX" || option == "PX" {
+		now := time.Now().UnixMilli()
+		if value > math.MaxInt64-now {
+			return 0, invalid
+		}
+		value += now
+	}
+	return value, nil
+}
+
+// getString returns the string stored at key. ok is false when the key does
+// not exist, in which case the string is empty.
+func getString(store *core.Store, key string) (value string, ok bool, err error) {
+	obj, ok := store.Get(key)
+	if !ok {
+		return "", false, nil
+	}
+	switch value := obj.(type) {
+	case resp.BulkString:
+		return string(value), true, nil
+	case core.Int:
+		return value.String(), true, nil
+	default:
+		return "", false, errWrongType
+	}
+}
+
+// getInteger returns the integer stored at key, or 0 if the key does not
+// exist. Strings are parsed strictly, rejecting signs, spaces and leading
+// zeros that would not round trip.
+func getInteger(store *core.Store, key string) (int64, error) {
+	obj, ok := store.Get(key)
+	if !ok {
+		return 0, nil
+	}
+	switch value := obj.(type) {
+	case core.Int:
+		return int64(value), nil
+	case resp.BulkString:
+		n, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil || strconv.FormatInt(n, 10) != string(value) {
+			return 0, errNotInteger
+		}
+		return n, nil
+	default:
+		return 0, errWrongType
+	}
+}
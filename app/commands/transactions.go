@@ -19,6 +19,7 @@ func handleExecCommand(call resp.Array, conn *core.Conn, store *core.Store) resp
 		return resp.SimpleError("ERR EXEC without MULTI")
 	}
 	conn.Multi = false
+	conn.Executing = true
 	conn.Mu.Unlock()
 
 	res := resp.Array{}
@@ -28,6 +29,11 @@ func handleExecCommand(call resp.Array, conn *core.Conn, store *core.Store) resp
 		res = append(res, sub)
 	}
 
+	conn.Mu.Lock()
+	conn.Executing = false
+	conn.Queued = make([]resp.Object, 0)
+	conn.Mu.Unlock()
+
 	return res
 }
 
@@ -0,0 +1,44 @@
+package core
+
+import "github.com/codecrafters-io/redis-starter-go/app/resp"
+
+// AppendOnlyLog records the write commands applied to the store, so that
+// they can be replayed to rebuild it.
+type AppendOnlyLog interface {
+	// Append records a write command applied to database db.
+	Append(db int, call resp.Array)
+	// Rewrite starts compacting the log in the background, from a snapshot
+	// of store. The caller must hold the store lock.
+	Rewrite(store *Store) error
+	// Stats reports the size of the log and the state of its rewrites.
+	Stats() AppendOnlyLogStats
+}
+
+// AppendOnlyLogStats reports the state of an append only log.
+type AppendOnlyLogStats struct {
+	// CurrentSize is the size of the log in bytes.
+	CurrentSize int64
+	// BaseSize is the size of the log in bytes right after it was last
+	// rewritten, or opened.
+	BaseSize int64
+	// RewriteInProgress is set while the log is being rewritten.
+	RewriteInProgress bool
+	// LastRewriteError is the error of the last rewrite, or nil if it
+	// succeeded.
+	LastRewriteError error
+}
+
+// SetAppendOnlyLog makes every write command propagated from now on be
+// appended to log as well. A nil log disables logging.
+func (s *Store) SetAppendOnlyLog(log AppendOnlyLog) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.aof = log
+}
+
+// AppendOnlyLog returns the append only log, or nil if logging is disabled.
+func (s *Store) AppendOnlyLog() AppendOnlyLog {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.aof
+}
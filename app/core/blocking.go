@@ -0,0 +1,108 @@
+package core
+
+import (
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// ServeFunc tries to serve a blocked client from the given key. It reports
+// false when the key cannot satisfy the client yet.
+type ServeFunc func(key string) (resp.Object, bool)
+
+type blockedClient struct {
+	keys   []string
+	serve  ServeFunc
+	result chan resp.Object
+}
+
+// BlockOnKeys queues the client on the given keys until one of them is
+// signalled as ready and serve succeeds, the timer fires or the connection
+// is closed. A nil timer blocks forever. The caller must hold the store lock,
+// which is released while waiting and held again when BlockOnKeys returns.
+func (s *Store) BlockOnKeys(conn *Conn, keys []string, timer <-chan time.Time, serve ServeFunc) (resp.Object, bool) {
+	client := &blockedClient{
+		keys:   keys,
+		serve:  serve,
+		result: make(chan resp.Object, 1),
+	}
+	for _, key := range keys {
+		s.blocked[key] = append(s.blocked[key], client)
+	}
+
+	s.Unlock()
+	select {
+	case res := <-client.result:
+		s.Lock()
+		return res, true
+	case <-timer:
+	case <-conn.Closed:
+	}
+	s.Lock()
+
+	// The client may have been served while waiting for the lock.
+	select {
+	case res := <-client.result:
+		return res, true
+	default:
+	}
+	s.unblock(client)
+	return nil, false
+}
+
+// SignalKeyReady marks a key as possibly able to serve blocked clients.
+// Clients are served by ServeBlockedClients once the current command is done.
+func (s *Store) SignalKeyReady(key string) {
+	if len(s.blocked[key]) == 0 {
+		return
+	}
+	for _, ready := range s.ready_keys {
+		if ready == key {
+			return
+		}
+	}
+	s.ready_keys = append(s.ready_keys, key)
+}
+
+// ServeBlockedClients serves the clients blocked on the keys signalled as
+// ready in any database, longest waiting client first. Serving a client may
+// signal further keys, which are handled in the same call. The caller must
+// hold the store lock.
+func (s *Store) ServeBlockedClients() {
+	for _, db := range s.dbs {
+		db.serveReadyKeys()
+	}
+}
+
+func (s *Store) serveReadyKeys() {
+	for len(s.ready_keys) > 0 {
+		key := s.ready_keys[0]
+		s.ready_keys = s.ready_keys[1:]
+
+		for _, client := range append([]*blockedClient{}, s.blocked[key]...) {
+			res, ok := client.serve(key)
+			if !ok {
+				continue
+			}
+			s.unblock(client)
+			client.result <- res
+		}
+	}
+}
+
+func (s *Store) unblock(client *blockedClient) {
+	for _, key := range client.keys {
+		queue := s.blocked[key]
+		for i, blocked := range queue {
+			if blocked == client {
+				queue = append(queue[:i], queue[i+1:]...)
+				break
+			}
+		}
+		if len(queue) == 0 {
+			delete(s.blocked, key)
+		} else {
+			s.blocked[key] = queue
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+func TestBlockedClientsAreServedInOrder(t *testing.T) {
+	var store Store
+	store.Init()
+
+	list := NewList()
+	serve := func(key string) (resp.Object, bool) {
+		value, ok := list.PopLeft()
+		if !ok {
+			return nil, false
+		}
+		return resp.BulkString(value), true
+	}
+
+	results := make(chan string, 3)
+	for i := 0; i < 3; i++ {
+		name := string(rune('a' + i))
+		go func() {
+			store.Lock()
+			defer store.Unlock()
+			conn := &Conn{Closed: make(chan struct{})}
+			res, ok := store.BlockOnKeys(conn, []string{"queue"}, nil, serve)
+			if ok {
+				results <- name + ":" + string(res.(resp.BulkString))
+			}
+		}()
+
+		// Wait for the client to be queued before blocking the next one.
+		for {
+			store.Lock()
+			queued := len(store.blocked["queue"])
+			store.Unlock()
+			if queued == i+1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	store.Lock()
+	list.PushRight("1", "2")
+	store.SignalKeyReady("queue")
+	store.ServeBlockedClients()
+	store.Unlock()
+
+	served := make(map[string]struct{})
+	for i := 0; i < 2; i++ {
+		select {
+		case got := <-results:
+			served[got] = struct{}{}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected two clients to be served, got %v", served)
+		}
+	}
+	for _, expected := range []string{"a:1", "b:2"} {
+		if _, ok := served[expected]; !ok {
+			t.Errorf("Expected: %s to be served\nGot: %v", expected, served)
+		}
+	}
+
+	store.Lock()
+	queued := len(store.blocked["queue"])
+	store.Unlock()
+	if queued != 1 {
+		t.Errorf("Expected one client to stay blocked, got %d", queued)
+	}
+}
+
+func TestBlockedClientTimesOut(t *testing.T) {
+	var store Store
+	store.Init()
+
+	store.Lock()
+	defer store.Unlock()
+	conn := &Conn{Closed: make(chan struct{})}
+	serve := func(key string) (resp.Object, bool) {
+		return nil, false
+	}
+
+	_, ok := store.BlockOnKeys(conn, []string{"a", "b"}, time.After(10*time.Millisecond), serve)
+	if ok {
+		t.Fatalf("Expected the client to time out")
+	}
+	if len(store.blocked) != 0 {
+		t.Errorf("Expected the client to be removed from all keys, got %v", store.blocked)
+	}
+}
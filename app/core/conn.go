@@ -28,13 +28,16 @@ type Conn struct {
 	Conn             net.Conn
 	ByteChan         chan byte
 	StopChan         chan bool
+	Closed           chan struct{}
 	Ticker           *time.Ticker
 	Offset           int
 	Expected_offset  int
 	Total_propagated int
 	Multi            bool
+	Executing        bool
 	Queued           []resp.Object
 	Relation         connRelationType
+	DB               int
 	Mu               sync.Mutex
 }
 
@@ -43,13 +46,16 @@ func NewConn(conn net.Conn, relation_type connRelationType) *Conn {
 		Conn:             conn,
 		ByteChan:         make(chan byte, 1<<14),
 		StopChan:         make(chan bool),
+		Closed:           make(chan struct{}),
 		Ticker:           nil,
 		Offset:           0,
 		Expected_offset:  0,
 		Total_propagated: 0,
 		Multi:            false,
+		Executing:        false,
 		Queued:           make([]resp.Object, 0),
 		Relation:         relation_type,
+		DB:               0,
 		Mu:               sync.Mutex{},
 	}
 }
@@ -71,12 +77,13 @@ func (conn *Conn) Write(data []byte) {
 
 func (conn *Conn) Read() {
 	defer close(conn.ByteChan)
+	defer close(conn.Closed)
 
 	for {
 		buf := make([]byte, 1024)
 		n, err := conn.Conn.Read(buf)
 		if err == io.EOF {
-			continue
+			return
 		}
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to read from connection: %v\n", err)
@@ -88,3 +95,38 @@ func (conn *Conn) Read() {
 		}
 	}
 }
+
+// Reader returns a reader of the bytes received on the connection, for data
+// that is not made of RESP values, such as the snapshot a master sends.
+func (conn *Conn) Reader() io.Reader {
+	return byteChanReader(conn.ByteChan)
+}
+
+type byteChanReader <-chan byte
+
+// Read waits for a byte, then reads the bytes already received, up to
+// len(p).
+func (r byteChanReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	b, ok := <-r
+	if !ok {
+		return 0, io.EOF
+	}
+	p[0] = b
+	n := 1
+	for n < len(p) {
+		select {
+		case b, ok := <-r:
+			if !ok {
+				return n, nil
+			}
+			p[n] = b
+			n++
+		default:
+			return n, nil
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,85 @@
+package core
+
+// DefaultDatabases is the number of databases of a server unless configured
+// otherwise.
+const DefaultDatabases = 16
+
+// DB returns database index, or nil if there is no such database.
+func (s *Store) DB(index int) *Store {
+	if index < 0 || index >= len(s.dbs) {
+		return nil
+	}
+	return s.dbs[index]
+}
+
+// Databases returns the number of databases.
+func (s *Store) Databases() int {
+	return len(s.dbs)
+}
+
+// Index returns the number of the database.
+func (s *Store) Index() int {
+	return s.index
+}
+
+// SwapDB swaps the contents of two databases. Clients keep using the
+// database numbers they selected, so they see the other contents from now
+// on, and clients blocked on keys that now exist may be served.
+func (s *Store) SwapDB(a int, b int) {
+	first, second := s.dbs[a], s.dbs[b]
+	s.mu.Lock()
+	first.dict, second.dict = second.dict, first.dict
+	first.expiry, second.expiry = second.expiry, first.expiry
+	first.hash_field_expiry_keys, second.hash_field_expiry_keys = second.hash_field_expiry_keys, first.hash_field_expiry_keys
+	s.mu.Unlock()
+
+	for _, db := range []*Store{first, second} {
+		for key := range db.blocked {
+			if _, ok := db.Get(key); ok {
+				db.SignalKeyReady(key)
+			}
+		}
+	}
+}
+
+// Move moves a key along with its expiry to another database. Nothing is
+// moved if the key does not exist or already exists in the destination. It
+// reports whether the key was moved.
+func (s *Store) Move(key string, dst *Store) bool {
+	s.mu.Lock()
+	value, ok := s.lookup(key)
+	if !ok {
+		s.mu.Unlock()
+		return false
+	}
+	if _, exists := dst.lookup(key); exists {
+		s.mu.Unlock()
+		return false
+	}
+	dst.dict.Set(key, value)
+	if expiry, has_expiry := s.expiry[key]; has_expiry {
+		dst.expiry[key] = expiry
+	}
+	s.dict.Delete(key)
+	delete(s.expiry, key)
+	s.mu.Unlock()
+
+	dst.trackValue(key, value)
+	return true
+}
+
+// Flush deletes all the keys of the database. The old contents are simply
+// dropped, so they are reclaimed by the garbage collector without holding
+// up other clients.
+func (s *Store) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reset()
+}
+
+// FlushAll deletes all the keys of every database.
+func (s *Store) FlushAll() {
+	for _, db := range s.dbs {
+		db.Flush()
+	}
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+func TestDatabasesAreIsolated(t *testing.T) {
+	store := Store{}
+	store.Init()
+
+	if store.Databases() != DefaultDatabases || store.DB(DefaultDatabases) != nil {
+		t.Fatalf("Expected %d databases, got %d", DefaultDatabases, store.Databases())
+	}
+
+	other := store.DB(3)
+	store.Set("key", resp.BulkString("zero"))
+	other.Set("key", resp.BulkString("three"))
+	if value, _ := store.Get("key"); value != resp.BulkString("zero") {
+		t.Errorf("Expected: zero\nGot: %v", value)
+	}
+
+	store.SwapDB(0, 3)
+	if value, _ := store.Get("key"); value != resp.BulkString("three") {
+		t.Errorf("Expected: three\nGot: %v", value)
+	}
+
+	other.Flush()
+	if other.Len() != 0 || store.Len() != 1 {
+		t.Errorf("Expected only database 3 to be flushed, got %d and %d keys", other.Len(), store.Len())
+	}
+}
+
+func TestStoreMoveKeepsExpiry(t *testing.T) {
+	store := Store{}
+	store.Init()
+	other := store.DB(1)
+
+	expiry := time.Now().Add(time.Minute).UnixMilli()
+	store.SetWithAbsoluteExpiry("key", resp.BulkString("value"), uint64(expiry))
+	other.Set("taken", resp.BulkString("value"))
+	store.Set("taken", resp.BulkString("value"))
+
+	if !store.Move("key", other) {
+		t.Fatalf("Expected key to be moved")
+	}
+	if _, ok := store.Get("key"); ok {
+		t.Errorf("Expected key to be gone from the source database")
+	}
+	if got, _ := other.Expiry("key"); got != expiry {
+		t.Errorf("Expected: %d\nGot: %d", expiry, got)
+	}
+	if store.Move("taken", other) {
+		t.Errorf("Expected moving onto an existing key to fail")
+	}
+}
@@ -0,0 +1,178 @@
+package core
+
+import (
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"math/rand"
+)
+
+const dictMinBuckets = 4
+
+// Dict is a hash table with chained buckets, modelled on the Redis dict.
+// Unlike a Go map it can be iterated incrementally with Scan, with a cursor
+// that stays valid while the table grows or shrinks between calls.
+type Dict[V any] struct {
+	seed    maphash.Seed
+	buckets []*dictEntry[V]
+	size    int
+}
+
+type dictEntry[V any] struct {
+	key   string
+	value V
+	next  *dictEntry[V]
+}
+
+func NewDict[V any]() *Dict[V] {
+	return &Dict[V]{
+		seed:    maphash.MakeSeed(),
+		buckets: make([]*dictEntry[V], dictMinBuckets),
+	}
+}
+
+func (d *Dict[V]) Len() int {
+	return d.size
+}
+
+func (d *Dict[V]) Get(key string) (V, bool) {
+	for entry := d.buckets[d.bucket(key)]; entry != nil; entry = entry.next {
+		if entry.key == key {
+			return entry.value, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// Set stores the value of a key, reporting whether the key is new.
+func (d *Dict[V]) Set(key string, value V) bool {
+	i := d.bucket(key)
+	for entry := d.buckets[i]; entry != nil; entry = entry.next {
+		if entry.key == key {
+			entry.value = value
+			return false
+		}
+	}
+
+	d.buckets[i] = &dictEntry[V]{key: key, value: value, next: d.buckets[i]}
+	d.size++
+	if d.size > len(d.buckets) {
+		d.resize(len(d.buckets) * 2)
+	}
+	return true
+}
+
+func (d *Dict[V]) Delete(key string) bool {
+	i := d.bucket(key)
+	for link := &d.buckets[i]; *link != nil; link = &(*link).next {
+		if (*link).key == key {
+			*link = (*link).next
+			d.size--
+			if len(d.buckets) > dictMinBuckets && d.size < len(d.buckets)/8 {
+				d.resize(len(d.buckets) / 2)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Range calls fn for every entry until it returns false. The dict must not
+// be modified by fn.
+func (d *Dict[V]) Range(fn func(key string, value V) bool) {
+	for _, entry := range d.buckets {
+		for ; entry != nil; entry = entry.next {
+			if !fn(entry.key, entry.value) {
+				return
+			}
+		}
+	}
+}
+
+func (d *Dict[V]) Keys() []string {
+	keys := make([]string, 0, d.size)
+	d.Range(func(key string, _ V) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// RandomKey returns a random key, or false if the dict is empty.
+func (d *Dict[V]) RandomKey() (string, bool) {
+	if d.size == 0 {
+		return "", false
+	}
+
+	var head *dictEntry[V]
+	for head == nil {
+		head = d.buckets[rand.Intn(len(d.buckets))]
+	}
+	length := 0
+	for entry := head; entry != nil; entry = entry.next {
+		length++
+	}
+	entry := head
+	for i := rand.Intn(length); i > 0; i-- {
+		entry = entry.next
+	}
+	return entry.key, true
+}
+
+// Scan calls fn for the entries of one bucket and returns the cursor of the
+// next bucket to visit. Iteration starts and ends with a cursor of 0.
+//
+// The cursor is advanced by incrementing its bits in reverse order, as Redis
+// does, so that buckets already visited map to buckets already visited when
+// the table is resized. Every key present for the whole iteration is
+// returned at least once, and possibly more than once if the table shrinks.
+func (d *Dict[V]) Scan(cursor uint64, fn func(key string, value V)) uint64 {
+	mask := uint64(len(d.buckets) - 1)
+	for entry := d.buckets[cursor&mask]; entry != nil; entry = entry.next {
+		fn(entry.key, entry.value)
+	}
+
+	cursor |= ^mask
+	cursor = bits.Reverse64(cursor)
+	cursor++
+	return bits.Reverse64(cursor)
+}
+
+func (d *Dict[V]) bucket(key string) int {
+	return int(maphash.String(d.seed, key) & uint64(len(d.buckets)-1))
+}
+
+func (d *Dict[V]) resize(size int) {
+	old := d.buckets
+	d.buckets = make([]*dictEntry[V], size)
+	for _, entry := range old {
+		for entry != nil {
+			next := entry.next
+			i := d.bucket(entry.key)
+			entry.next = d.buckets[i]
+			d.buckets[i] = entry
+			entry = next
+		}
+	}
+}
+
+// ScanCount calls Scan from cursor until at least count entries have been
+// visited or the iteration ends, returning the cursor to continue from. At
+// most ten buckets per requested entry are visited, so a sparse table does
+// not make a single call walk all of it.
+func (d *Dict[V]) ScanCount(cursor uint64, count int, fn func(key string, value V)) uint64 {
+	visited := 0
+	// Huge counts are clamped so that the bound does not overflow.
+	max_buckets := min(count, math.MaxInt/10) * 10
+	for {
+		cursor = d.Scan(cursor, func(key string, value V) {
+			visited++
+			fn(key, value)
+		})
+		max_buckets--
+		if cursor == 0 || visited >= count || max_buckets <= 0 {
+			return cursor
+		}
+	}
+}
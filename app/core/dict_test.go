@@ -0,0 +1,101 @@
+package core
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestDictScanCoversKeysWhileResizing(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(d *Dict[int], step int)
+	}{
+		{"growing", func(d *Dict[int], step int) {
+			for i := 0; i < 50; i++ {
+				d.Set("new"+strconv.Itoa(step*50+i), 0)
+			}
+		}},
+		{"shrinking", func(d *Dict[int], step int) {
+			for i := 0; i < 50; i++ {
+				d.Delete("gone" + strconv.Itoa(step*50+i))
+			}
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := NewDict[int]()
+			for i := 0; i < 100; i++ {
+				d.Set("key"+strconv.Itoa(i), i)
+			}
+			for i := 0; i < 2000; i++ {
+				d.Set("gone"+strconv.Itoa(i), i)
+			}
+
+			seen := map[string]bool{}
+			cursor, step := uint64(0), 0
+			for {
+				cursor = d.ScanCount(cursor, 10, func(key string, _ int) {
+					seen[key] = true
+				})
+				if cursor == 0 {
+					break
+				}
+				test.change(d, step)
+				step++
+			}
+
+			for i := 0; i < 100; i++ {
+				if key := "key" + strconv.Itoa(i); !seen[key] {
+					t.Errorf("Expected %s to be returned by the scan", key)
+				}
+			}
+		})
+	}
+}
+
+func TestDictSetGetDelete(t *testing.T) {
+	d := NewDict[int]()
+	for i := 0; i < 1000; i++ {
+		if !d.Set(strconv.Itoa(i), i) {
+			t.Fatalf("Expected %d to be a new key", i)
+		}
+	}
+	if d.Set("0", 42) {
+		t.Errorf("Expected overwriting a key not to add it")
+	}
+	if value, ok := d.Get("0"); !ok || value != 42 {
+		t.Errorf("Expected: 42\nGot: %v", value)
+	}
+
+	for i := 0; i < 990; i++ {
+		d.Delete(strconv.Itoa(i))
+	}
+	if d.Len() != 10 || len(d.buckets) > 64 {
+		t.Errorf("Expected 10 keys in a shrunk table, got %d keys in %d buckets", d.Len(), len(d.buckets))
+	}
+	for i := 990; i < 1000; i++ {
+		if value, ok := d.Get(strconv.Itoa(i)); !ok || value != i {
+			t.Errorf("Expected: %d\nGot: %v", i, value)
+		}
+	}
+}
+
+func TestDictScanCountHugeCount(t *testing.T) {
+	d := NewDict[int]()
+	for i := 0; i < 1000; i++ {
+		d.Set(strconv.Itoa(i), i)
+	}
+	// Delete most keys, so that a single bucket holds too few to finish.
+	for i := 0; i < 990; i++ {
+		d.Delete(strconv.Itoa(i))
+	}
+
+	visited := 0
+	cursor := d.ScanCount(0, 1_000_000_000_000_000_000, func(key string, value int) {
+		visited++
+	})
+	if cursor != 0 || visited != 10 {
+		t.Errorf("Expected: the whole table in one call\nGot: cursor %d after %d keys", cursor, visited)
+	}
+}
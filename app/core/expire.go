@@ -0,0 +1,209 @@
+package core
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// activeExpireHz is how many times per second the active expiry cycle runs.
+const activeExpireHz = 10
+
+// The active expiry cycle samples activeExpireKeysPerLoop keys with an
+// expiry at a time, and keeps sampling while more than
+// activeExpireAcceptableStale percent of them turn out to be expired, using
+// at most activeExpireCPUPercent of the time between two cycles. These are
+// the values for an effort of 1, and grow with the active-expire-effort
+// parameter as they do in Redis.
+const (
+	activeExpireKeysPerLoop     = 20
+	activeExpireAcceptableStale = 10
+	activeExpireCPUPercent      = 25
+)
+
+// ExpiryStats reports the work done to evict expired keys.
+type ExpiryStats struct {
+	// ExpiredKeys counts the keys evicted, both lazily and actively.
+	ExpiredKeys int64
+	// StalePercent estimates the percentage of keys with an expiry that are
+	// expired but not evicted yet.
+	StalePercent float64
+	// TimeCapReached counts the cycles that stopped early because they ran
+	// out of time.
+	TimeCapReached int64
+	// CycleTime is the time spent in active expiry cycles.
+	CycleTime time.Duration
+}
+
+// RunActiveExpiry periodically evicts expired data that would otherwise only
+// be evicted lazily, the next time it is read. Replicas skip the cycle, as
+// they get the deletions of their master instead.
+func (s *Store) RunActiveExpiry(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Second / activeExpireHz)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, replica := s.GetParam("replicaof"); replica {
+				continue
+			}
+			s.Lock()
+			s.activeExpireCycle()
+			s.Unlock()
+		}
+	}
+}
+
+// ExpiryStats returns a snapshot of the expiry statistics.
+func (s *Store) ExpiryStats() ExpiryStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.expiry_stats
+}
+
+// activeExpireCycle evicts expired keys by sampling keys with an expiry in
+// every database, then the expired fields of hashes. It keeps sampling a
+// database while the sampled keys are mostly expired, so that memory held by
+// keys that are never read again stays bounded, and stops once it has used
+// up its time budget.
+func (s *Store) activeExpireCycle() {
+	keys_per_loop, acceptable_stale, budget := s.activeExpireParams()
+	start := time.Now()
+
+	for _, db := range s.dbs {
+		if !db.activeExpireDB(keys_per_loop, acceptable_stale, start, budget) ||
+			!db.expireTrackedHashFields(keys_per_loop, start, budget) {
+			s.mu.Lock()
+			s.expiry_stats.TimeCapReached++
+			s.mu.Unlock()
+			break
+		}
+	}
+
+	s.mu.Lock()
+	s.expiry_stats.CycleTime += time.Since(start)
+	s.mu.Unlock()
+}
+
+// activeExpireDB runs the sampling loop of the active expiry cycle on one
+// database. It reports false when the cycle ran out of time.
+func (s *Store) activeExpireDB(keys_per_loop int, acceptable_stale int, start time.Time, budget time.Duration) bool {
+	for {
+		sampled, expired := s.sampleExpiredKeys(keys_per_loop)
+		for _, key := range expired {
+			s.PropagateToReplicas(resp.StringsToArray([]string{"DEL", key}))
+		}
+		if sampled == 0 {
+			return true
+		}
+
+		stale := len(expired) * 100 / sampled
+		s.mu.Lock()
+		s.expiry_stats.StalePercent = float64(stale)*0.05 + s.expiry_stats.StalePercent*0.95
+		s.mu.Unlock()
+
+		if time.Since(start) > budget {
+			return false
+		}
+		if stale <= acceptable_stale {
+			return true
+		}
+	}
+}
+
+// activeExpireParams returns the number of keys to sample at a time, the
+// acceptable percentage of expired keys and the time budget of a cycle for
+// the configured active-expire-effort, which ranges from 1 to 10.
+func (s *Store) activeExpireParams() (keys_per_loop int, acceptable_stale int, budget time.Duration) {
+	effort := 1
+	if value, ok := s.GetParam("active-expire-effort"); ok {
+		if n, err := strconv.Atoi(value); err == nil && n >= 1 && n <= 10 {
+			effort = n
+		}
+	}
+	effort--
+
+	keys_per_loop = activeExpireKeysPerLoop + activeExpireKeysPerLoop/4*effort
+	acceptable_stale = activeExpireAcceptableStale - effort
+	cpu_percent := activeExpireCPUPercent + 2*effort
+	budget = time.Second * time.Duration(cpu_percent) / 100 / activeExpireHz
+	return keys_per_loop, acceptable_stale, budget
+}
+
+// sampleExpiredKeys looks at up to count keys with an expiry, starting from
+// a random position, and evicts the ones that have expired.
+func (s *Store) sampleExpiredKeys(count int) (sampled int, expired []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	for key, expiry := range s.expiry {
+		if sampled == count {
+			break
+		}
+		sampled++
+		if now > expiry {
+			s.dict.Delete(key)
+			delete(s.expiry, key)
+			expired = append(expired, key)
+		}
+	}
+	s.expiry_stats.ExpiredKeys += int64(len(expired))
+	return sampled, expired
+}
+
+// TrackHashFieldExpiry registers a hash with field expiries, so its fields
+// are expired by the active expiry cycle.
+func (s *Store) TrackHashFieldExpiry(key string) {
+	s.hash_field_expiry_keys[key] = struct{}{}
+}
+
+// ExpireHashFields deletes the expired fields of the hash stored at key and
+// propagates their deletion. It reports false when the hash ends up empty,
+// in which case the key is deleted as well.
+func (s *Store) ExpireHashFields(key string, hash *Hash) bool {
+	expired := hash.ExpireFields()
+	if len(expired) == 0 {
+		return true
+	}
+
+	s.PropagateToReplicas(resp.StringsToArray(append([]string{"HDEL", key}, expired...)))
+	if hash.Len() == 0 {
+		s.Delete(key)
+		return false
+	}
+	return true
+}
+
+// expireTrackedHashFields evicts the expired fields of the hashes with field
+// expiries, checking the time budget of the cycle every keys_per_loop
+// hashes. It reports false when the cycle ran out of time, in which case the
+// next cycle goes on with other hashes, since maps are iterated in random
+// order.
+func (s *Store) expireTrackedHashFields(keys_per_loop int, start time.Time, budget time.Duration) bool {
+	checked := 0
+	for key := range s.hash_field_expiry_keys {
+		if checked == keys_per_loop {
+			if time.Since(start) > budget {
+				return false
+			}
+			checked = 0
+		}
+		checked++
+
+		value, ok := s.Get(key)
+		hash, is_hash := value.(*Hash)
+		if !ok || !is_hash || !hash.HasFieldExpiries() {
+			delete(s.hash_field_expiry_keys, key)
+			continue
+		}
+		if !s.ExpireHashFields(key, hash) {
+			delete(s.hash_field_expiry_keys, key)
+		}
+	}
+	return true
+}
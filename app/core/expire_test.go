@@ -0,0 +1,96 @@
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+func TestActiveExpireCycle(t *testing.T) {
+	store := Store{}
+	store.Init()
+
+	past := uint64(time.Now().Add(-time.Second).UnixMilli())
+	for i := 0; i < 1000; i++ {
+		store.SetWithAbsoluteExpiry("expired:"+strconv.Itoa(i), resp.BulkString("v"), past)
+	}
+	store.Set("persistent", resp.BulkString("v"))
+
+	store.activeExpireCycle()
+
+	if store.Len() != 1 {
+		t.Errorf("Expected the expired keys to be evicted, %d keys left", store.Len())
+	}
+	if stats := store.ExpiryStats(); stats.ExpiredKeys != 1000 {
+		t.Errorf("Expected: 1000\nGot: %d", stats.ExpiredKeys)
+	}
+
+	future := uint64(time.Now().Add(time.Minute).UnixMilli())
+	for i := 0; i < 10; i++ {
+		store.SetWithAbsoluteExpiry("alive:"+strconv.Itoa(i), resp.BulkString("v"), future)
+	}
+
+	store.activeExpireCycle()
+
+	if store.Len() != 11 {
+		t.Errorf("Expected keys that have not expired to be kept, %d keys left", store.Len())
+	}
+}
+
+func TestActiveExpireCycleHashFields(t *testing.T) {
+	store := Store{}
+	store.Init()
+
+	past := time.Now().Add(-time.Second).UnixMilli()
+	for i := 0; i < 100; i++ {
+		hash := NewHash()
+		hash.Set("expired", "v")
+		hash.SetFieldExpiry("expired", past)
+		hash.Set("kept", "v")
+		key := "hash:" + strconv.Itoa(i)
+		store.Set(key, hash)
+		store.TrackHashFieldExpiry(key)
+	}
+
+	store.activeExpireCycle()
+
+	for i := 0; i < 100; i++ {
+		value, _ := store.Get("hash:" + strconv.Itoa(i))
+		if hash := value.(*Hash); hash.Len() != 1 || hash.HasFieldExpiries() {
+			t.Fatalf("Expected the expired field of hash:%d to be evicted", i)
+		}
+	}
+
+	// Without any time left, the cycle stops after a batch of hashes.
+	for i := 0; i < 100; i++ {
+		value, _ := store.Get("hash:" + strconv.Itoa(i))
+		value.(*Hash).SetFieldExpiry("kept", past)
+		store.TrackHashFieldExpiry("hash:" + strconv.Itoa(i))
+	}
+	if store.expireTrackedHashFields(10, time.Now().Add(-time.Second), 0) {
+		t.Errorf("Expected the cycle to run out of time")
+	}
+	if remaining := len(store.hash_field_expiry_keys); remaining != 90 {
+		t.Errorf("Expected: 90 hashes left to expire\nGot: %d", remaining)
+	}
+}
+
+func TestRunActiveExpiryOnReplica(t *testing.T) {
+	store := Store{}
+	store.Init()
+	store.SetParam("replicaof", "localhost:6379")
+	past := uint64(time.Now().Add(-time.Second).UnixMilli())
+	store.SetWithAbsoluteExpiry("expired", resp.BulkString("v"), past)
+
+	stop := make(chan struct{})
+	go store.RunActiveExpiry(stop)
+	time.Sleep(3 * time.Second / activeExpireHz)
+	close(stop)
+
+	// The key is left for the master to delete.
+	if store.Len() != 1 {
+		t.Errorf("Expected the replica to keep the expired key, %d keys left", store.Len())
+	}
+}
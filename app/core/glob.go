@@ -0,0 +1,107 @@
+package core
+
+// GlobMatch reports whether str matches a Redis glob-style pattern. * matches
+// any sequence of characters and ? any single character. [abc] matches one of
+// the characters in the brackets, [^abc] any character not in them, and [a-z]
+// a character in the range, given in either order. A backslash matches the
+// character after it literally.
+//
+// Matching is done on bytes, like Redis. An unterminated [ class ends at the
+// end of the pattern.
+func GlobMatch(pattern string, str string) bool {
+	p, s := 0, 0
+	// The positions to resume from after the last *, which lets it absorb
+	// one more character whenever the rest of the pattern fails to match.
+	star_p, star_s := -1, 0
+
+	for s < len(str) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				for p < len(pattern) && pattern[p] == '*' {
+					p++
+				}
+				if p == len(pattern) {
+					return true
+				}
+				star_p, star_s = p, s
+				continue
+			case '?':
+				p++
+				s++
+				continue
+			case '[':
+				if next, ok := matchClass(pattern, p+1, str[s]); ok {
+					p = next
+					s++
+					continue
+				}
+			case '\\':
+				if p+1 < len(pattern) {
+					if pattern[p+1] == str[s] {
+						p += 2
+						s++
+						continue
+					}
+					break
+				}
+				fallthrough
+			default:
+				if pattern[p] == str[s] {
+					p++
+					s++
+					continue
+				}
+			}
+		}
+
+		if star_p < 0 {
+			return false
+		}
+		star_s++
+		p, s = star_p, star_s
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchClass matches c against the character class starting at pattern[p],
+// just after its opening [. It returns the position after the class and
+// whether c is in it.
+func matchClass(pattern string, p int, c byte) (int, bool) {
+	negate := p < len(pattern) && pattern[p] == '^'
+	if negate {
+		p++
+	}
+
+	match := false
+	for p < len(pattern) && pattern[p] != ']' {
+		switch {
+		case pattern[p] == '\\' && p+1 < len(pattern):
+			p++
+			if pattern[p] == c {
+				match = true
+			}
+		case p+2 < len(pattern) && pattern[p+1] == '-' && pattern[p+2] != ']':
+			start, end := pattern[p], pattern[p+2]
+			if start > end {
+				start, end = end, start
+			}
+			if c >= start && c <= end {
+				match = true
+			}
+			p += 2
+		case pattern[p] == c:
+			match = true
+		}
+		p++
+	}
+	if p < len(pattern) {
+		p++
+	}
+
+	return p, match != negate
+}
@@ -0,0 +1,46 @@
+package core
+
+import "testing"
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		str     string
+		want    bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "hllo", true},
+		{"h*llo", "heeeello", true},
+		{"h*llo", "hello world", false},
+		{"*llo*", "hello world", true},
+		{"a*b*c", "abxbc", true},
+		{"a*b*c", "abxbd", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hallo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{"h[\\]]llo", "h]llo", true},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"h\\?llo", "h?llo", true},
+		{"key[", "key", false},
+		{"key[a", "keya", true},
+		{"abc\\", "abc\\", true},
+		{"", "", true},
+		{"", "a", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern+" "+test.str, func(t *testing.T) {
+			if got := GlobMatch(test.pattern, test.str); got != test.want {
+				t.Errorf("Expected: %v\nGot: %v", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package core
+
+import (
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// Hash maps string fields to string values. Fields may have their own
+// expiry, independent of the expiry of the key holding the hash.
+type Hash struct {
+	fields *Dict[string]
+	expiry map[string]int64
+	// next_expiry is a lower bound of the field expiries, so that hashes
+	// without expired fields can be skipped without looking at every field.
+	next_expiry int64
+}
+
+func NewHash() *Hash {
+	return &Hash{
+		fields: NewDict[string](),
+		expiry: make(map[string]int64),
+	}
+}
+
+func (h *Hash) Len() int {
+	return h.fields.Len()
+}
+
+// Copy returns a hash with the same fields and field expiries that shares
+// no memory with h.
+func (h *Hash) Copy() *Hash {
+	copied := &Hash{
+		fields:      NewDict[string](),
+		expiry:      make(map[string]int64, len(h.expiry)),
+		next_expiry: h.next_expiry,
+	}
+	h.fields.Range(func(field string, value string) bool {
+		copied.fields.Set(field, value)
+		return true
+	})
+	for field, expiry := range h.expiry {
+		copied.expiry[field] = expiry
+	}
+	return copied
+}
+
+func (h *Hash) Get(field string) (string, bool) {
+	value, ok := h.fields.Get(field)
+	if ok && h.isExpired(field, time.Now().UnixMilli()) {
+		h.Delete(field)
+		return "", false
+	}
+	return value, ok
+}
+
+// Set stores the value of a field, discarding any expiry it had. It reports
+// whether the field is new.
+func (h *Hash) Set(field string, value string) bool {
+	delete(h.expiry, field)
+	return h.SetKeepTTL(field, value)
+}
+
+// SetKeepTTL is like Set, but keeps the expiry of an existing field.
+func (h *Hash) SetKeepTTL(field string, value string) bool {
+	_, exists := h.Get(field)
+	h.fields.Set(field, value)
+	return !exists
+}
+
+func (h *Hash) Delete(field string) bool {
+	exists := h.fields.Delete(field)
+	delete(h.expiry, field)
+	return exists
+}
+
+// Fields returns the fields of the hash in no particular order.
+func (h *Hash) Fields() []string {
+	now := time.Now().UnixMilli()
+	fields := make([]string, 0, h.fields.Len())
+	h.fields.Range(func(field string, _ string) bool {
+		if !h.isExpired(field, now) {
+			fields = append(fields, field)
+		}
+		return true
+	})
+	return fields
+}
+
+// Range calls fn for every field that has not expired until fn returns
+// false.
+func (h *Hash) Range(fn func(field string, value string) bool) {
+	now := time.Now().UnixMilli()
+	h.fields.Range(func(field string, value string) bool {
+		if h.isExpired(field, now) {
+			return true
+		}
+		return fn(field, value)
+	})
+}
+
+// Scan calls fn for a batch of about count fields that have not expired,
+// starting at cursor, and returns the cursor to continue from. See Dict.Scan.
+func (h *Hash) Scan(cursor uint64, count int, fn func(field string, value string)) uint64 {
+	now := time.Now().UnixMilli()
+	return h.fields.ScanCount(cursor, count, func(field string, value string) {
+		if !h.isExpired(field, now) {
+			fn(field, value)
+		}
+	})
+}
+
+// FieldExpiry returns the absolute expiry of a field in unix milliseconds.
+func (h *Hash) FieldExpiry(field string) (int64, bool) {
+	expiry, ok := h.expiry[field]
+	return expiry, ok
+}
+
+func (h *Hash) SetFieldExpiry(field string, expiry int64) {
+	h.expiry[field] = expiry
+	if h.next_expiry == 0 || expiry < h.next_expiry {
+		h.next_expiry = expiry
+	}
+}
+
+// Persist removes the expiry of a field, reporting whether it had one.
+func (h *Hash) Persist(field string) bool {
+	_, ok := h.expiry[field]
+	delete(h.expiry, field)
+	return ok
+}
+
+func (h *Hash) HasFieldExpiries() bool {
+	return len(h.expiry) != 0
+}
+
+// ExpireFields deletes the fields whose expiry has passed and returns them.
+func (h *Hash) ExpireFields() []string {
+	now := time.Now().UnixMilli()
+	if len(h.expiry) == 0 || now <= h.next_expiry {
+		return nil
+	}
+
+	expired := make([]string, 0)
+	h.next_expiry = 0
+	for field, expiry := range h.expiry {
+		if expiry < now {
+			expired = append(expired, field)
+			continue
+		}
+		if h.next_expiry == 0 || expiry < h.next_expiry {
+			h.next_expiry = expiry
+		}
+	}
+	for _, field := range expired {
+		h.Delete(field)
+	}
+	return expired
+}
+
+func (h *Hash) Encode() []byte {
+	fields := h.Fields()
+	pairs := make([]string, 0, 2*len(fields))
+	for _, field := range fields {
+		value, _ := h.fields.Get(field)
+		pairs = append(pairs, field, value)
+	}
+	return resp.StringsToArray(pairs).Encode()
+}
+
+func (h *Hash) isExpired(field string, now int64) bool {
+	expiry, ok := h.expiry[field]
+	return ok && now > expiry
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHashExpireFields(t *testing.T) {
+	now := time.Now().UnixMilli()
+
+	hash := NewHash()
+	hash.Set("expired", "1")
+	hash.Set("alive", "2")
+	hash.Set("persistent", "3")
+	hash.SetFieldExpiry("expired", now-10)
+	hash.SetFieldExpiry("alive", now+10000)
+
+	expired := hash.ExpireFields()
+	if !equalSlices(expired, []string{"expired"}) {
+		t.Errorf("Expected: [expired]\nGot: %v", expired)
+	}
+	if hash.Len() != 2 {
+		t.Errorf("Expected 2 fields left, got %d", hash.Len())
+	}
+	if _, ok := hash.FieldExpiry("alive"); !ok {
+		t.Errorf("Expected alive to keep its expiry")
+	}
+
+	if expired := hash.ExpireFields(); len(expired) != 0 {
+		t.Errorf("Expected no more expired fields, got %v", expired)
+	}
+}
+
+func TestHashSetDiscardsExpiry(t *testing.T) {
+	hash := NewHash()
+	hash.Set("field", "1")
+	hash.SetFieldExpiry("field", time.Now().UnixMilli()+10000)
+
+	hash.SetKeepTTL("field", "2")
+	if _, ok := hash.FieldExpiry("field"); !ok {
+		t.Errorf("Expected SetKeepTTL to keep the expiry")
+	}
+
+	hash.Set("field", "3")
+	if _, ok := hash.FieldExpiry("field"); ok {
+		t.Errorf("Expected Set to discard the expiry")
+	}
+	if value, _ := hash.Get("field"); value != "3" {
+		t.Errorf("Expected: 3\nGot: %s", value)
+	}
+}
+
+func TestHashScanSkipsExpiredFields(t *testing.T) {
+	hash := NewHash()
+	for i := 0; i < 100; i++ {
+		hash.Set("field"+strconv.Itoa(i), strconv.Itoa(i))
+	}
+	hash.SetFieldExpiry("field7", time.Now().UnixMilli()-10)
+
+	seen := map[string]string{}
+	cursor := uint64(0)
+	for {
+		cursor = hash.Scan(cursor, 10, func(field string, value string) {
+			seen[field] = value
+		})
+		if cursor == 0 {
+			break
+		}
+	}
+
+	if len(seen) != 99 {
+		t.Errorf("Expected 99 fields, got %d", len(seen))
+	}
+	if _, ok := seen["field7"]; ok {
+		t.Errorf("Expected the expired field to be skipped")
+	}
+	if seen["field42"] != "42" {
+		t.Errorf("Expected: 42\nGot: %s", seen["field42"])
+	}
+}
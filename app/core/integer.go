@@ -0,0 +1,20 @@
+package core
+
+import (
+	"strconv"
+
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// Int is a string value that holds a 64-bit integer. Counters are stored
+// this way so that incrementing them does not parse and format the value
+// every time. It reads back as a bulk string like any other string value.
+type Int int64
+
+func (i Int) String() string {
+	return strconv.FormatInt(int64(i), 10)
+}
+
+func (i Int) Encode() []byte {
+	return resp.BulkString(i.String()).Encode()
+}
@@ -0,0 +1,164 @@
+package core
+
+import (
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// Len returns the number of keys, including expired keys that have not been
+// evicted yet.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dict.Len()
+}
+
+// ExpiresLen returns the number of keys with an expiry.
+func (s *Store) ExpiresLen() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.expiry)
+}
+
+// RandomKey returns a key that has not expired, or false if there are none.
+func (s *Store) RandomKey() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Expired keys are evicted as they are drawn, so this ends once a live
+	// key is found or the dict is empty.
+	for {
+		key, ok := s.dict.RandomKey()
+		if !ok {
+			return "", false
+		}
+		if _, ok := s.lookup(key); ok {
+			return key, true
+		}
+	}
+}
+
+// Range calls fn for every key that has not expired, with its value and its
+// expiry in unix milliseconds, or 0 if it has none, until fn returns false.
+// The store is locked meanwhile, so fn must not call its methods.
+func (s *Store) Range(fn func(key string, value resp.Object, expiry int64) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	s.dict.Range(func(key string, value resp.Object) bool {
+		expiry, has_expiry := s.expiry[key]
+		if has_expiry && now > expiry {
+			return true
+		}
+		return fn(key, value, expiry)
+	})
+}
+
+// Scan returns a batch of about count keys that have not expired, starting
+// at cursor, along with the cursor to pass to the next call. A returned
+// cursor of 0 ends the iteration. Every key that exists for the whole
+// iteration is returned at least once.
+func (s *Store) Scan(cursor uint64, count int) (uint64, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	cursor = s.dict.ScanCount(cursor, count, func(key string, _ resp.Object) {
+		keys = append(keys, key)
+	})
+
+	// Expired keys are evicted after the scan, since evicting them may
+	// resize the dict.
+	live := keys[:0]
+	for _, key := range keys {
+		if _, ok := s.lookup(key); ok {
+			live = append(live, key)
+		}
+	}
+	return cursor, live
+}
+
+// Rename moves the value and expiry of src to dst, replacing any value dst
+// had. It reports whether src exists.
+func (s *Store) Rename(src string, dst string) bool {
+	s.mu.Lock()
+	value, ok := s.lookup(src)
+	if !ok {
+		s.mu.Unlock()
+		return false
+	}
+	expiry, has_expiry := s.expiry[src]
+	s.dict.Delete(src)
+	delete(s.expiry, src)
+	s.dict.Set(dst, value)
+	delete(s.expiry, dst)
+	if has_expiry {
+		s.expiry[dst] = expiry
+	}
+	s.mu.Unlock()
+
+	s.trackValue(dst, value)
+	return true
+}
+
+// Copy sets dst in the database to_db to a deep copy of the value of src,
+// along with its expiry. Unless replace is set, nothing is copied if dst
+// already exists. It reports whether the value was copied.
+func (s *Store) Copy(src string, to_db *Store, dst string, replace bool) bool {
+	s.mu.Lock()
+	value, ok := s.lookup(src)
+	if !ok {
+		s.mu.Unlock()
+		return false
+	}
+	if _, exists := to_db.lookup(dst); exists && !replace {
+		s.mu.Unlock()
+		return false
+	}
+	expiry, has_expiry := s.expiry[src]
+	value = CopyValue(value)
+	to_db.dict.Set(dst, value)
+	delete(to_db.expiry, dst)
+	if has_expiry {
+		to_db.expiry[dst] = expiry
+	}
+	s.mu.Unlock()
+
+	to_db.trackValue(dst, value)
+	return true
+}
+
+// Unlink removes a key like Delete. Redis frees large values in the
+// background instead, but here the garbage collector reclaims a value once
+// nothing refers to it anymore, so dropping the key is all there is to do.
+func (s *Store) Unlink(key string) bool {
+	return s.Delete(key)
+}
+
+// CopyValue returns a deep copy of a value. Strings are immutable, so they
+// are shared rather than copied.
+func CopyValue(value resp.Object) resp.Object {
+	switch value := value.(type) {
+	case *List:
+		return value.Copy()
+	case *Hash:
+		return value.Copy()
+	case *Set:
+		return value.Copy()
+	case *SortedSet:
+		return value.Copy()
+	case *resp.Stream:
+		return value.Copy()
+	default:
+		return value
+	}
+}
+
+// trackValue registers a value that was moved or copied to key with the
+// active expiry cycle, if it needs to be.
+func (s *Store) trackValue(key string, value resp.Object) {
+	if hash, ok := value.(*Hash); ok && hash.HasFieldExpiries() {
+		s.TrackHashFieldExpiry(key)
+	}
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+func TestStoreCopyIsDeep(t *testing.T) {
+	store := Store{}
+	store.Init()
+
+	stream := &resp.Stream{}
+	stream.AddEntry("1-1", map[string]resp.Object{"a": resp.BulkString("1")})
+	store.Set("list", NewList("a", "b"))
+	store.Set("stream", stream)
+
+	store.Copy("list", &store, "list copy", false)
+	store.Copy("stream", &store, "stream copy", false)
+
+	list, _ := store.Get("list")
+	list.(*List).PushRight("c")
+	stream.AddEntry("1-2", nil)
+	stream.Entries[0].Data["a"] = resp.BulkString("2")
+
+	copied, _ := store.Get("list copy")
+	if got := copied.(*List).Range(0, -1); !equalSlices(got, []string{"a", "b"}) {
+		t.Errorf("Expected: [a b]\nGot: %v", got)
+	}
+	copied, _ = store.Get("stream copy")
+	entries := copied.(*resp.Stream).Entries
+	if len(entries) != 1 || entries[0].Data["a"] != resp.BulkString("1") {
+		t.Errorf("Expected the copied stream to keep its single entry, got %v", entries)
+	}
+
+	if store.Copy("list", &store, "list copy", false) {
+		t.Errorf("Expected copying to an existing key to fail without REPLACE")
+	}
+}
+
+func TestStoreRenameKeepsExpiry(t *testing.T) {
+	store := Store{}
+	store.Init()
+
+	expiry := time.Now().Add(time.Minute).UnixMilli()
+	store.SetWithAbsoluteExpiry("src", resp.BulkString("value"), uint64(expiry))
+	store.SetWithAbsoluteExpiry("dst", resp.BulkString("old"), uint64(expiry+1000))
+
+	if !store.Rename("src", "dst") {
+		t.Fatalf("Expected src to be renamed")
+	}
+	if _, ok := store.Get("src"); ok {
+		t.Errorf("Expected src to be gone")
+	}
+	if got, _ := store.Expiry("dst"); got != expiry {
+		t.Errorf("Expected: %d\nGot: %d", expiry, got)
+	}
+	if store.Rename("src", "dst") {
+		t.Errorf("Expected renaming a missing key to fail")
+	}
+}
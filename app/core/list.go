@@ -0,0 +1,210 @@
+package core
+
+import (
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// List is a double ended queue of strings. It is backed by a ring buffer so
+// pushes and pops on either end, as well as access by index, are O(1).
+type List struct {
+	items []string
+	head  int
+	size  int
+}
+
+func NewList(items ...string) *List {
+	list := &List{}
+	list.PushRight(items...)
+	return list
+}
+
+func (l *List) Len() int {
+	return l.size
+}
+
+// Copy returns a list with the same elements that shares no memory with l.
+func (l *List) Copy() *List {
+	return NewList(l.Range(0, -1)...)
+}
+
+func (l *List) PushLeft(values ...string) {
+	for _, value := range values {
+		if l.size == len(l.items) {
+			l.grow()
+		}
+		l.head = (l.head - 1 + len(l.items)) % len(l.items)
+		l.items[l.head] = value
+		l.size++
+	}
+}
+
+func (l *List) PushRight(values ...string) {
+	for _, value := range values {
+		if l.size == len(l.items) {
+			l.grow()
+		}
+		l.items[(l.head+l.size)%len(l.items)] = value
+		l.size++
+	}
+}
+
+func (l *List) PopLeft() (string, bool) {
+	if l.size == 0 {
+		return "", false
+	}
+	value := l.items[l.head]
+	l.items[l.head] = ""
+	l.head = (l.head + 1) % len(l.items)
+	l.size--
+	return value, true
+}
+
+func (l *List) PopRight() (string, bool) {
+	if l.size == 0 {
+		return "", false
+	}
+	tail := (l.head + l.size - 1) % len(l.items)
+	value := l.items[tail]
+	l.items[tail] = ""
+	l.size--
+	return value, true
+}
+
+// Index returns the element at the given index. Negative indexes count
+// from the tail of the list, with -1 being the last element.
+func (l *List) Index(index int) (string, bool) {
+	offset, ok := l.normalizeIndex(index)
+	if !ok {
+		return "", false
+	}
+	return l.at(offset), true
+}
+
+func (l *List) SetIndex(index int, value string) bool {
+	offset, ok := l.normalizeIndex(index)
+	if !ok {
+		return false
+	}
+	l.items[(l.head+offset)%len(l.items)] = value
+	return true
+}
+
+// Range returns the elements between start and stop, both inclusive. Out of
+// range indexes are clamped to the list bounds the same way LRANGE does.
+func (l *List) Range(start int, stop int) []string {
+	if start < 0 {
+		start += l.size
+	}
+	if stop < 0 {
+		stop += l.size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= l.size {
+		stop = l.size - 1
+	}
+	if start > stop {
+		return []string{}
+	}
+
+	ret := make([]string, stop-start+1)
+	for i := start; i <= stop; i++ {
+		ret[i-start] = l.at(i)
+	}
+	return ret
+}
+
+// Trim keeps only the elements between start and stop, both inclusive.
+func (l *List) Trim(start int, stop int) {
+	l.reset(l.Range(start, stop))
+}
+
+// Insert adds value before or after the first occurrence of pivot. It
+// reports false when the pivot is not in the list.
+func (l *List) Insert(pivot string, value string, before bool) bool {
+	items := l.Range(0, -1)
+	for i, item := range items {
+		if item != pivot {
+			continue
+		}
+		if !before {
+			i++
+		}
+		items = append(items[:i], append([]string{value}, items[i:]...)...)
+		l.reset(items)
+		return true
+	}
+	return false
+}
+
+// Remove deletes up to count occurrences of value. A positive count removes
+// from head to tail, a negative one from tail to head and zero removes all.
+func (l *List) Remove(count int, value string) int {
+	items := l.Range(0, -1)
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+
+	removed := make([]bool, len(items))
+	n := 0
+	for i := 0; i < len(items) && (limit == 0 || n < limit); i++ {
+		j := i
+		if count < 0 {
+			j = len(items) - 1 - i
+		}
+		if items[j] == value {
+			removed[j] = true
+			n++
+		}
+	}
+
+	if n == 0 {
+		return 0
+	}
+
+	kept := make([]string, 0, len(items)-n)
+	for i, item := range items {
+		if !removed[i] {
+			kept = append(kept, item)
+		}
+	}
+	l.reset(kept)
+	return n
+}
+
+func (l *List) Encode() []byte {
+	return resp.StringsToArray(l.Range(0, -1)).Encode()
+}
+
+func (l *List) normalizeIndex(index int) (int, bool) {
+	if index < 0 {
+		index += l.size
+	}
+	return index, index >= 0 && index < l.size
+}
+
+func (l *List) at(offset int) string {
+	return l.items[(l.head+offset)%len(l.items)]
+}
+
+func (l *List) grow() {
+	capacity := 2 * len(l.items)
+	if capacity == 0 {
+		capacity = 8
+	}
+	items := make([]string, capacity)
+	for i := 0; i < l.size; i++ {
+		items[i] = l.at(i)
+	}
+	l.items = items
+	l.head = 0
+}
+
+func (l *List) reset(items []string) {
+	l.items = nil
+	l.head = 0
+	l.size = 0
+	l.PushRight(items...)
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"testing"
+)
+
+func equalSlices(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestListRange(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		start int
+		stop  int
+		list  []string
+	}{
+		{name: "whole list", start: 0, stop: -1, list: []string{"a", "b", "c", "d"}},
+		{name: "negative indexes", start: -3, stop: -2, list: []string{"b", "c"}},
+		{name: "stop past the end", start: 2, stop: 100, list: []string{"c", "d"}},
+		{name: "start before the head", start: -100, stop: 0, list: []string{"a"}},
+		{name: "start after stop", start: 3, stop: 1, list: []string{}},
+		{name: "start past the end", start: 5, stop: 10, list: []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			list := NewList("c", "d")
+			list.PushLeft("b", "a")
+			if got := list.Range(test.start, test.stop); !equalSlices(got, test.list) {
+				t.Errorf("Expected: %v\nGot: %v", test.list, got)
+			}
+		})
+	}
+}
+
+func TestListRemove(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		count   int
+		removed int
+		list    []string
+	}{
+		{name: "from head", count: 2, removed: 2, list: []string{"b", "c", "a"}},
+		{name: "from tail", count: -2, removed: 2, list: []string{"a", "b", "c"}},
+		{name: "all", count: 0, removed: 3, list: []string{"b", "c"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			list := NewList("a", "b", "a", "c", "a")
+			removed := list.Remove(test.count, "a")
+			got := list.Range(0, -1)
+			if removed != test.removed || !equalSlices(got, test.list) {
+				t.Errorf("Expected: removed = %d, list: %v\nGot: removed = %d, list: %v",
+					test.removed, test.list, removed, got,
+				)
+			}
+		})
+	}
+}
+
+func TestListWrapsAround(t *testing.T) {
+	list := NewList()
+	expected := []string{}
+	for i := 0; i < 20; i++ {
+		value := string(rune('a' + i))
+		if i%2 == 0 {
+			list.PushLeft(value)
+			expected = append([]string{value}, expected...)
+		} else {
+			list.PushRight(value)
+			expected = append(expected, value)
+		}
+		if i%3 == 0 {
+			list.PopRight()
+			expected = expected[:len(expected)-1]
+		}
+	}
+
+	if got := list.Range(0, -1); !equalSlices(got, expected) {
+		t.Errorf("Expected: %v\nGot: %v", expected, got)
+	}
+	if !list.Insert(expected[2], "x", true) {
+		t.Fatalf("Expected pivot %s to be found", expected[2])
+	}
+	if got, _ := list.Index(2); got != "x" {
+		t.Errorf("Expected: x\nGot: %s", got)
+	}
+}
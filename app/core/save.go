@@ -0,0 +1,116 @@
+package core
+
+import (
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// SaveStats reports the state of snapshotting.
+type SaveStats struct {
+	// Dirty counts the writes since the last successful save.
+	Dirty int64
+	// LastSave is the time of the last successful save, or of the start of
+	// the server if there was none.
+	LastSave time.Time
+	// LastAttempt is the time the last save finished, successfully or not.
+	LastAttempt time.Time
+	// LastError is the error of the last save, or nil if it succeeded.
+	LastError error
+	// InProgress is set while a background save is running.
+	InProgress bool
+	// Scheduled is set when a background save was requested while another
+	// one was running.
+	Scheduled bool
+}
+
+// SaveStats returns a snapshot of the save statistics.
+func (s *Store) SaveStats() SaveStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save_stats
+}
+
+// markDirty counts a write towards the save points.
+func (s *Store) markDirty() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.save_stats.Dirty++
+}
+
+// ClearDirty forgets the writes made so far, such as those replayed while
+// loading the data.
+func (s *Store) ClearDirty() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.save_stats.Dirty = 0
+}
+
+// RecordSave records the outcome of a save that started when dirty writes
+// had been made. Writes made while saving stay counted as dirty.
+func (s *Store) RecordSave(dirty int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.save_stats.LastAttempt = now
+	s.save_stats.LastError = err
+	if err == nil {
+		s.save_stats.Dirty -= dirty
+		s.save_stats.LastSave = now
+	}
+}
+
+// StartBackgroundSave marks a background save as running, returning the
+// number of dirty writes it covers. It reports false if one is already
+// running.
+func (s *Store) StartBackgroundSave() (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.save_stats.InProgress {
+		return 0, false
+	}
+	s.save_stats.InProgress = true
+	s.save_stats.Scheduled = false
+	return s.save_stats.Dirty, true
+}
+
+// FinishBackgroundSave records the outcome of the running background save.
+func (s *Store) FinishBackgroundSave(dirty int64, err error) {
+	s.RecordSave(dirty, err)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.save_stats.InProgress = false
+}
+
+// ScheduleBackgroundSave requests a background save once the running one is
+// done.
+func (s *Store) ScheduleBackgroundSave() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.save_stats.Scheduled = true
+}
+
+// Snapshot returns a deep copy of every database, along with the
+// parameters, which can be saved while the store keeps changing. The caller
+// must hold the store lock, so that the copy is consistent.
+func (s *Store) Snapshot() *Store {
+	snapshot := new(Store)
+	snapshot.InitWithDatabases(len(s.dbs))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, value := range s.params {
+		snapshot.params[key] = value
+	}
+	for i, db := range s.dbs {
+		copied := snapshot.dbs[i]
+		db.dict.Range(func(key string, value resp.Object) bool {
+			copied.dict.Set(key, CopyValue(value))
+			return true
+		})
+		for key, expiry := range db.expiry {
+			copied.expiry[key] = expiry
+		}
+	}
+	return snapshot
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+func TestSnapshotIsIndependent(t *testing.T) {
+	store := Store{}
+	store.Init()
+
+	list := NewList("a", "b")
+	store.Set("list", list)
+	expiry := time.Now().Add(time.Minute).UnixMilli()
+	store.DB(2).SetWithAbsoluteExpiry("key", resp.BulkString("value"), uint64(expiry))
+
+	snapshot := store.Snapshot()
+	list.PushRight("c")
+	store.DB(2).Delete("key")
+
+	copied, _ := snapshot.Get("list")
+	if items := copied.(*List).Range(0, -1); len(items) != 2 {
+		t.Errorf("Expected: [a b]\nGot: %v", items)
+	}
+	if _, ok := snapshot.DB(2).Get("key"); !ok {
+		t.Fatalf("Expected the snapshot to keep the deleted key")
+	}
+	if got, _ := snapshot.DB(2).Expiry("key"); got != expiry {
+		t.Errorf("Expected: %d\nGot: %d", expiry, got)
+	}
+}
+
+func TestSaveStatsCountWritesDuringSave(t *testing.T) {
+	store := Store{}
+	store.Init()
+
+	store.PropagateToReplicas(resp.Array{resp.BulkString("SET"), resp.BulkString("a"), resp.BulkString("1")})
+	dirty, ok := store.StartBackgroundSave()
+	if !ok || dirty != 1 {
+		t.Fatalf("Expected a save covering 1 write, got %d, %v", dirty, ok)
+	}
+	if _, ok := store.StartBackgroundSave(); ok {
+		t.Fatalf("Expected a second save to be refused while one is running")
+	}
+
+	store.PropagateToReplicas(resp.Array{resp.BulkString("SET"), resp.BulkString("b"), resp.BulkString("2")})
+	store.FinishBackgroundSave(dirty, nil)
+	if stats := store.SaveStats(); stats.Dirty != 1 || stats.InProgress || stats.LastError != nil {
+		t.Errorf("Expected 1 dirty write left after the save\nGot: %+v", stats)
+	}
+
+	failure := errors.New("disk full")
+	store.RecordSave(1, failure)
+	if stats := store.SaveStats(); stats.Dirty != 1 || stats.LastError != failure {
+		t.Errorf("Expected a failed save to keep the dirty writes\nGot: %+v", stats)
+	}
+}
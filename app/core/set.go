@@ -0,0 +1,59 @@
+package core
+
+import (
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// Set is an unordered collection of unique strings.
+type Set struct {
+	members *Dict[struct{}]
+}
+
+func NewSet(members ...string) *Set {
+	set := &Set{
+		members: NewDict[struct{}](),
+	}
+	for _, member := range members {
+		set.Add(member)
+	}
+	return set
+}
+
+func (s *Set) Len() int {
+	return s.members.Len()
+}
+
+func (s *Set) Copy() *Set {
+	return NewSet(s.Members()...)
+}
+
+// Add inserts a member, reporting whether it was not in the set already.
+func (s *Set) Add(member string) bool {
+	return s.members.Set(member, struct{}{})
+}
+
+func (s *Set) Remove(member string) bool {
+	return s.members.Delete(member)
+}
+
+func (s *Set) Contains(member string) bool {
+	_, ok := s.members.Get(member)
+	return ok
+}
+
+// Members returns the members of the set in no particular order.
+func (s *Set) Members() []string {
+	return s.members.Keys()
+}
+
+// Scan calls fn for a batch of about count members, starting at cursor, and
+// returns the cursor to continue from. See Dict.Scan.
+func (s *Set) Scan(cursor uint64, count int, fn func(member string)) uint64 {
+	return s.members.ScanCount(cursor, count, func(member string, _ struct{}) {
+		fn(member)
+	})
+}
+
+func (s *Set) Encode() []byte {
+	return resp.StringsToArray(s.Members()).Encode()
+}
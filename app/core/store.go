@@ -9,26 +9,87 @@ import (
 	"github.com/codecrafters-io/redis-starter-go/app/resp"
 )
 
+// Store is one of the logical databases of the server. The databases share
+// the server state, such as parameters, replicas and locks, through the
+// embedded server. The Store that was initialised is database 0, and the
+// others are reached with DB.
 type Store struct {
-	dict     map[string]resp.Object
-	expiry   map[interface{}]int64
+	*server
+	index      int
+	dict       *Dict[resp.Object]
+	expiry     map[string]int64
+	blocked    map[string][]*blockedClient
+	ready_keys []string
+
+	hash_field_expiry_keys map[string]struct{}
+}
+
+// server holds the state shared by all the databases.
+type server struct {
+	dbs      []*Store
 	params   map[string]string
 	Replicas []*Conn
 	Master   *Conn
 	mu       sync.Mutex
+	exec_mu  sync.Mutex
+
+	expiry_stats ExpiryStats
+	save_stats   SaveStats
+	aof          AppendOnlyLog
+	// replication_db is the database last selected on the replication
+	// stream, or -1 when the next propagated command must select one.
+	replication_db int
 }
 
+// Init sets up the store with DefaultDatabases databases.
 func (s *Store) Init() {
-	s.dict = make(map[string]resp.Object)
-	s.expiry = make(map[interface{}]int64)
-	s.params = make(map[string]string)
-	s.Replicas = make([]*Conn, 0)
+	s.InitWithDatabases(DefaultDatabases)
 }
 
+// InitWithDatabases sets up the store with the given number of databases.
+func (s *Store) InitWithDatabases(count int) {
+	s.server = &server{
+		dbs:            make([]*Store, count),
+		params:         make(map[string]string),
+		Replicas:       make([]*Conn, 0),
+		replication_db: -1,
+		save_stats:     SaveStats{LastSave: time.Now()},
+	}
+	s.dbs[0] = s
+	for i := 1; i < count; i++ {
+		s.dbs[i] = &Store{server: s.server}
+	}
+	for i, db := range s.dbs {
+		db.index = i
+		db.blocked = make(map[string][]*blockedClient)
+		db.reset()
+	}
+}
+
+// reset empties the database. The caller must hold s.mu unless the
+// database is not in use yet.
+func (s *Store) reset() {
+	s.dict = NewDict[resp.Object]()
+	s.expiry = make(map[string]int64)
+	s.hash_field_expiry_keys = make(map[string]struct{})
+}
+
+// Set sets the value of a key, discarding any expiry it had.
 func (s *Store) Set(key string, value resp.Object) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.dict[key] = value
+	s.dict.Set(key, value)
+	delete(s.expiry, key)
+}
+
+// SetKeepTTL sets the value of a key, keeping its expiry if it has one.
+func (s *Store) SetKeepTTL(key string, value resp.Object) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.lookup(key); !ok {
+		delete(s.expiry, key)
+	}
+	s.dict.Set(key, value)
 }
 
 func (s *Store) SetWithExpiry(key string, value resp.Object, expiry uint64) {
@@ -38,23 +99,86 @@ func (s *Store) SetWithExpiry(key string, value resp.Object, expiry uint64) {
 func (s *Store) SetWithAbsoluteExpiry(key string, value resp.Object, expiry uint64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.dict[key] = value
+	s.dict.Set(key, value)
 	s.expiry[key] = int64(expiry)
 }
 
 func (s *Store) Get(key string) (resp.Object, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	value, in_dict := s.dict[key]
+	return s.lookup(key)
+}
+
+// Delete removes the key and its expiry, reporting whether the key existed.
+func (s *Store) Delete(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.lookup(key)
+	s.dict.Delete(key)
+	delete(s.expiry, key)
+	return ok
+}
+
+// Expiry returns the expiry of a key as a unix timestamp in milliseconds.
+// ok is false when the key does not exist or has no expiry.
+func (s *Store) Expiry(key string) (expiry int64, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.lookup(key); !exists {
+		return 0, false
+	}
+	expiry, ok = s.expiry[key]
+	return expiry, ok
+}
+
+// SetExpiry sets the expiry of an existing key as a unix timestamp in
+// milliseconds, reporting whether the key exists.
+func (s *Store) SetExpiry(key string, expiry int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.lookup(key); !exists {
+		return false
+	}
+	s.expiry[key] = expiry
+	return true
+}
+
+// Persist removes the expiry of a key, reporting whether it had one.
+func (s *Store) Persist(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.lookup(key); !exists {
+		return false
+	}
+	_, ok := s.expiry[key]
+	delete(s.expiry, key)
+	return ok
+}
+
+// lookup returns the value of a key, lazily evicting it if it has expired.
+// The caller must hold s.mu.
+func (s *Store) lookup(key string) (resp.Object, bool) {
+	value, in_dict := s.dict.Get(key)
 	expiry, in_expiry := s.expiry[key]
 	if in_dict && in_expiry && time.Now().UnixMilli() > expiry {
-		delete(s.dict, key)
+		s.dict.Delete(key)
 		delete(s.expiry, key)
+		s.expiry_stats.ExpiredKeys++
 		return nil, false
 	}
 	return value, in_dict
 }
 
+// Lock serializes command execution. Commands are run while holding it so
+// that a command's reads and writes are atomic with respect to other clients.
+func (s *Store) Lock() {
+	s.exec_mu.Lock()
+}
+
+func (s *Store) Unlock() {
+	s.exec_mu.Unlock()
+}
+
 func (s *Store) SetParam(key string, value string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -62,16 +186,25 @@ func (s *Store) SetParam(key string, value string) {
 }
 
 func (s *Store) GetParam(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	value, ok := s.params[key]
 	return value, ok
 }
 
-func (s *Store) GetKeys(_ string) []string {
-	keys := make([]string, len(s.dict))
-	i := 0
-	for key := range s.dict {
-		keys[i] = key
-		i++
+// GetKeys returns the keys matching a glob-style pattern that have not
+// expired, evicting expired ones.
+func (s *Store) GetKeys(pattern string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, s.dict.Len())
+	for _, key := range s.dict.Keys() {
+		if pattern != "*" && !GlobMatch(pattern, key) {
+			continue
+		}
+		if _, ok := s.lookup(key); ok {
+			keys = append(keys, key)
+		}
 	}
 	return keys
 }
@@ -81,6 +214,8 @@ func (s *Store) AddReplica(conn *Conn) {
 	defer s.mu.Unlock()
 	s.Replicas = append(s.Replicas, conn)
 	conn.Relation = ConnRelationTypeEnum.REPLICA
+	// The new replica has not seen any SELECT yet.
+	s.replication_db = -1
 }
 
 func (s *Store) TypeOfValue(key string) string {
@@ -88,17 +223,46 @@ func (s *Store) TypeOfValue(key string) string {
 	if !ok {
 		return "none"
 	}
+	return TypeName(value)
+}
+
+// TypeName returns the name of the type of a value, as reported by TYPE.
+func TypeName(value resp.Object) string {
 	switch value.(type) {
-	case resp.SimpleString, resp.BulkString:
+	case resp.SimpleString, resp.BulkString, Int:
 		return "string"
-	case resp.Stream, *resp.Stream:
+	case *resp.Stream:
 		return "stream"
+	case *List:
+		return "list"
+	case *Hash:
+		return "hash"
+	case *Set:
+		return "set"
+	case *SortedSet:
+		return "zset"
 	default:
 		return "unknown"
 	}
 }
 
+// PropagateToReplicas sends a write command to the replicas, preceded by a
+// SELECT when the command applies to another database than the previous one.
+// Every write goes through here, so it also counts writes towards the save
+// points and appends them to the append only log.
 func (store *Store) PropagateToReplicas(call resp.Array) {
+	store.markDirty()
+	if store.aof != nil {
+		store.aof.Append(store.index, call)
+	}
+	if store.replication_db != store.index {
+		store.replication_db = store.index
+		store.propagate(resp.StringsToArray([]string{"SELECT", strconv.Itoa(store.index)}))
+	}
+	store.propagate(call)
+}
+
+func (store *Store) propagate(call resp.Array) {
 	res := call.Encode()
 	for _, conn := range store.Replicas {
 		fmt.Printf("Propagating %v to replica %v\n", call, conn.Conn.LocalAddr())
@@ -0,0 +1,228 @@
+package core
+
+import (
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// SortedSet is a collection of unique members ordered by score. Members
+// with the same score are ordered lexicographically. Members are indexed by
+// a map for score lookups and by a skiplist for rank and range queries.
+type SortedSet struct {
+	scores *Dict[float64]
+	list   *skiplist
+}
+
+type ZEntry struct {
+	Member string
+	Score  float64
+}
+
+// ScoreBound is one end of a score range, such as "(1.5" or "-inf".
+type ScoreBound struct {
+	Value     float64
+	Exclusive bool
+}
+
+// LexBound is one end of a lexicographical range, such as "[a" or "+".
+// Infinite is -1 for "-", 1 for "+" and 0 for bounds with a value.
+type LexBound struct {
+	Value     string
+	Exclusive bool
+	Infinite  int
+}
+
+func NewSortedSet() *SortedSet {
+	return &SortedSet{
+		scores: NewDict[float64](),
+		list:   newSkiplist(),
+	}
+}
+
+func (z *SortedSet) Len() int {
+	return z.scores.Len()
+}
+
+func (z *SortedSet) Copy() *SortedSet {
+	copied := NewSortedSet()
+	for _, entry := range z.Entries() {
+		copied.Add(entry.Member, entry.Score)
+	}
+	return copied
+}
+
+func (z *SortedSet) Score(member string) (float64, bool) {
+	score, ok := z.scores.Get(member)
+	return score, ok
+}
+
+// Add sets the score of a member, reporting whether the member is new.
+func (z *SortedSet) Add(member string, score float64) bool {
+	current, exists := z.scores.Get(member)
+	if exists {
+		if current == score {
+			return false
+		}
+		z.list.delete(current, member)
+	}
+	z.list.insert(score, member)
+	z.scores.Set(member, score)
+	return !exists
+}
+
+func (z *SortedSet) Remove(member string) bool {
+	score, ok := z.scores.Get(member)
+	if !ok {
+		return false
+	}
+	z.list.delete(score, member)
+	z.scores.Delete(member)
+	return true
+}
+
+// Scan calls fn for a batch of about count entries in no particular order,
+// starting at cursor, and returns the cursor to continue from. See Dict.Scan.
+func (z *SortedSet) Scan(cursor uint64, count int, fn func(entry ZEntry)) uint64 {
+	return z.scores.ScanCount(cursor, count, func(member string, score float64) {
+		fn(ZEntry{Member: member, Score: score})
+	})
+}
+
+// Rank returns the 0-based position of a member, counting from the highest
+// score when reverse is set.
+func (z *SortedSet) Rank(member string, reverse bool) (int, bool) {
+	score, ok := z.scores.Get(member)
+	if !ok {
+		return 0, false
+	}
+	_, rank := z.list.seek(func(n *skiplistNode) bool {
+		return n.less(score, member)
+	})
+	if reverse {
+		rank = z.Len() - 1 - rank
+	}
+	return rank, true
+}
+
+// Entries returns all the entries, ordered from the lowest score.
+func (z *SortedSet) Entries() []ZEntry {
+	return z.entries(0, z.Len(), false, 0, -1)
+}
+
+// RangeByRank returns the entries between the start and stop ranks, both
+// inclusive. Negative ranks count from the end, and out of range ranks are
+// clamped the same way LRANGE does.
+func (z *SortedSet) RangeByRank(start int, stop int, reverse bool) []ZEntry {
+	length := z.Len()
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	if start > stop {
+		return []ZEntry{}
+	}
+
+	if reverse {
+		return z.entries(length-1-stop, length-start, true, 0, -1)
+	}
+	return z.entries(start, stop+1, false, 0, -1)
+}
+
+// RangeByScore returns the entries with a score between min and max. The
+// first offset entries are skipped, and at most count entries are returned
+// unless count is negative.
+func (z *SortedSet) RangeByScore(min ScoreBound, max ScoreBound, reverse bool, offset int, count int) []ZEntry {
+	lo, hi := z.scoreRanks(min, max)
+	return z.entries(lo, hi, reverse, offset, count)
+}
+
+func (z *SortedSet) CountByScore(min ScoreBound, max ScoreBound) int {
+	lo, hi := z.scoreRanks(min, max)
+	return hi - lo
+}
+
+// RangeByLex is like RangeByScore, for members between min and max. It is
+// only meaningful when all the members have the same score.
+func (z *SortedSet) RangeByLex(min LexBound, max LexBound, reverse bool, offset int, count int) []ZEntry {
+	lo, hi := z.lexRanks(min, max)
+	return z.entries(lo, hi, reverse, offset, count)
+}
+
+func (z *SortedSet) CountByLex(min LexBound, max LexBound) int {
+	lo, hi := z.lexRanks(min, max)
+	return hi - lo
+}
+
+func (z *SortedSet) Encode() []byte {
+	entries := z.Entries()
+	members := make([]string, len(entries))
+	for i, entry := range entries {
+		members[i] = entry.Member
+	}
+	return resp.StringsToArray(members).Encode()
+}
+
+// scoreRanks returns the ranks of the entries within the score range, as
+// the half open interval [lo, hi).
+func (z *SortedSet) scoreRanks(min ScoreBound, max ScoreBound) (int, int) {
+	_, lo := z.list.seek(func(n *skiplistNode) bool {
+		return n.score < min.Value || (min.Exclusive && n.score == min.Value)
+	})
+	_, hi := z.list.seek(func(n *skiplistNode) bool {
+		return n.score < max.Value || (!max.Exclusive && n.score == max.Value)
+	})
+	if hi < lo {
+		hi = lo
+	}
+	return lo, hi
+}
+
+func (z *SortedSet) lexRanks(min LexBound, max LexBound) (int, int) {
+	_, lo := z.list.seek(func(n *skiplistNode) bool {
+		if min.Infinite != 0 {
+			return min.Infinite > 0
+		}
+		return n.member < min.Value || (min.Exclusive && n.member == min.Value)
+	})
+	_, hi := z.list.seek(func(n *skiplistNode) bool {
+		if max.Infinite != 0 {
+			return max.Infinite > 0
+		}
+		return n.member < max.Value || (!max.Exclusive && n.member == max.Value)
+	})
+	if hi < lo {
+		hi = lo
+	}
+	return lo, hi
+}
+
+// entries returns the entries with ranks in [lo, hi), walking them from hi
+// down to lo when reverse is set.
+func (z *SortedSet) entries(lo int, hi int, reverse bool, offset int, count int) []ZEntry {
+	n := hi - lo - offset
+	if offset < 0 || n <= 0 || count == 0 {
+		return []ZEntry{}
+	}
+	if count > 0 && count < n {
+		n = count
+	}
+
+	ret := make([]ZEntry, 0, n)
+	if reverse {
+		for x := z.list.byRank(hi - 1 - offset); len(ret) < n; x = x.backward {
+			ret = append(ret, ZEntry{Member: x.member, Score: x.score})
+		}
+	} else {
+		for x := z.list.byRank(lo + offset); len(ret) < n; x = x.levels[0].forward {
+			ret = append(ret, ZEntry{Member: x.member, Score: x.score})
+		}
+	}
+	return ret
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func members(entries []ZEntry) []string {
+	ret := make([]string, len(entries))
+	for i, entry := range entries {
+		ret[i] = entry.Member
+	}
+	return ret
+}
+
+func TestSortedSetRank(t *testing.T) {
+	zset := NewSortedSet()
+	for i := 0; i < 1000; i++ {
+		zset.Add(fmt.Sprintf("m%04d", i), float64(i/2))
+	}
+	zset.Add("m0000", 1000)
+
+	if rank, _ := zset.Rank("m0000", false); rank != 999 {
+		t.Errorf("Expected: 999\nGot: %d", rank)
+	}
+	if rank, _ := zset.Rank("m0001", false); rank != 0 {
+		t.Errorf("Expected: 0\nGot: %d", rank)
+	}
+	if rank, _ := zset.Rank("m0500", true); rank != 500 {
+		t.Errorf("Expected: 500\nGot: %d", rank)
+	}
+
+	for i := 1; i < 1000; i += 2 {
+		zset.Remove(fmt.Sprintf("m%04d", i))
+	}
+	if rank, _ := zset.Rank("m0998", false); rank != 498 {
+		t.Errorf("Expected: 498\nGot: %d", rank)
+	}
+	if zset.Len() != 500 {
+		t.Errorf("Expected: 500\nGot: %d", zset.Len())
+	}
+}
+
+func TestSortedSetRanges(t *testing.T) {
+	zset := NewSortedSet()
+	zset.Add("a", 1)
+	zset.Add("b", 2)
+	zset.Add("c", 2)
+	zset.Add("d", 3)
+	zset.Add("e", math.Inf(1))
+
+	inf := math.Inf(1)
+	tests := []struct {
+		name     string
+		entries  []ZEntry
+		expected []string
+	}{
+		{"rank", zset.RangeByRank(1, -2, false), []string{"b", "c", "d"}},
+		{"rank reversed", zset.RangeByRank(0, 1, true), []string{"e", "d"}},
+		{"rank out of range", zset.RangeByRank(5, 10, false), []string{}},
+		{"score", zset.RangeByScore(ScoreBound{Value: 2}, ScoreBound{Value: 3}, false, 0, -1), []string{"b", "c", "d"}},
+		{"score exclusive", zset.RangeByScore(ScoreBound{Value: 1, Exclusive: true}, ScoreBound{Value: 3, Exclusive: true}, false, 0, -1), []string{"b", "c"}},
+		{"score infinite", zset.RangeByScore(ScoreBound{Value: 3}, ScoreBound{Value: inf}, false, 0, -1), []string{"d", "e"}},
+		{"score reversed", zset.RangeByScore(ScoreBound{Value: -inf}, ScoreBound{Value: 2}, true, 0, -1), []string{"c", "b", "a"}},
+		{"score limit", zset.RangeByScore(ScoreBound{Value: -inf}, ScoreBound{Value: inf}, false, 1, 2), []string{"b", "c"}},
+		{"score limit reversed", zset.RangeByScore(ScoreBound{Value: -inf}, ScoreBound{Value: inf}, true, 1, 2), []string{"d", "c"}},
+		{"score empty", zset.RangeByScore(ScoreBound{Value: 3}, ScoreBound{Value: 2}, false, 0, -1), []string{}},
+		{"lex", zset.RangeByLex(LexBound{Value: "b"}, LexBound{Value: "c", Exclusive: true}, false, 0, -1), []string{"b"}},
+		{"lex infinite", zset.RangeByLex(LexBound{Infinite: -1}, LexBound{Infinite: 1}, true, 0, 2), []string{"e", "d"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := members(test.entries)
+			if !equalSlices(got, test.expected) {
+				t.Errorf("Expected: %v\nGot: %v", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package rdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+var (
+	// ErrTruncated is returned when an RDB file ends before its EOF opcode
+	// and checksum.
+	ErrTruncated = errors.New("unexpected end of the RDB file")
+	// ErrChecksum is returned when the checksum ending an RDB file does not
+	// match its contents.
+	ErrChecksum = errors.New("wrong RDB checksum")
+	// ErrUnsupportedVersion is returned for RDB files written in a later
+	// version of the format than the one supported.
+	ErrUnsupportedVersion = errors.New("unsupported RDB version")
+)
+
+// FormatError reports an RDB file that cannot be loaded, along with the
+// offset at which the problem was found and the key being read, if any. Err
+// is ErrTruncated, ErrChecksum, ErrUnsupportedVersion, or describes how the
+// data is malformed.
+type FormatError struct {
+	Offset uint64
+	Key    string
+	Err    error
+}
+
+func (e *FormatError) Error() string {
+	if e.Key != "" {
+		return fmt.Sprintf("invalid RDB file at offset %d, in key %q: %v", e.Offset, e.Key, e.Err)
+	}
+	return fmt.Sprintf("invalid RDB file at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *FormatError) Unwrap() error {
+	return e.Err
+}
+
+// maxStringLength is the longest string accepted, Redis' proto-max-bulk-len,
+// as a guard against allocating huge buffers for a corrupted length.
+const maxStringLength = 512 << 20
+
+// readChunkSize is how much of a long string is read at a time, so that a
+// corrupted length fails at the end of the data instead of allocating a
+// huge buffer up front.
+const readChunkSize = 64 << 10
+
+// decoder reads RDB data from a reader while computing its checksum. Like
+// the encoder, it keeps the first error, after which reads return zero
+// values, so errors only have to be checked where a bogus value would do
+// harm: before using a decoded value and in loops over a decoded count.
+type decoder struct {
+	r      *bufio.Reader
+	offset uint64
+	crc    uint64
+	err    error
+	// err_offset is the offset at which err happened.
+	err_offset uint64
+	// read_err is set when err is an error of the reader rather than a
+	// problem with the data.
+	read_err bool
+	// scratch holds the data of fixed size reads.
+	scratch [16]byte
+}
+
+func newDecoder(r io.Reader) *decoder {
+	return &decoder{r: bufio.NewReaderSize(r, readChunkSize)}
+}
+
+// fail records err, unless an error happened already.
+func (d *decoder) fail(err error) {
+	if err != nil && d.err == nil {
+		d.err = err
+		d.err_offset = d.offset
+	}
+}
+
+// formatError returns the error that stopped the decoder, as a
+// *FormatError unless the reader failed, or nil if there was none.
+func (d *decoder) formatError(key string) error {
+	if d.err == nil || d.read_err {
+		return d.err
+	}
+	return &FormatError{Offset: d.err_offset, Key: key, Err: d.err}
+}
+
+// readFull fills data from the reader.
+func (d *decoder) readFull(data []byte) bool {
+	n, err := io.ReadFull(d.r, data)
+	d.offset += uint64(n)
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		d.fail(ErrTruncated)
+	case err != nil:
+		d.fail(fmt.Errorf("unable to read RDB data: %w", err))
+		d.read_err = true
+	}
+	if err != nil {
+		return false
+	}
+	d.crc = updateChecksum(d.crc, data)
+	return true
+}
+
+// read returns the next n bytes, or nil if there are not as many left.
+func (d *decoder) read(n uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	data := make([]byte, 0, min(n, readChunkSize))
+	for uint64(len(data)) < n {
+		start := len(data)
+		data = append(data, make([]byte, min(n-uint64(start), readChunkSize))...)
+		if !d.readFull(data[start:]) {
+			return nil
+		}
+	}
+	return data
+}
+
+// readFixed reads n bytes, at most 16, or returns n zero bytes after an
+// error, so that they can be decoded unconditionally. The bytes are only
+// valid until the next read.
+func (d *decoder) readFixed(n int) []byte {
+	data := d.scratch[:n]
+	if d.err != nil || !d.readFull(data) {
+		clear(data)
+	}
+	return data
+}
+
+func (d *decoder) readByte() byte {
+	return d.readFixed(1)[0]
+}
+
+func (d *decoder) readMillis() int64 {
+	return int64(binary.LittleEndian.Uint64(d.readFixed(8)))
+}
+
+func (d *decoder) readDouble() float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(d.readFixed(8)))
+}
+
+// readEncodedSize reads the size prefixing a string or a length. Strings
+// holding integers, or compressed with LZF, are marked by a special content
+// type instead, with the size of the integer.
+func (d *decoder) readEncodedSize() (size uint64, content_type contentType) {
+	first := d.readByte()
+	switch first >> 6 {
+	case 0b00:
+		return uint64(first & 0b00111111), NORMAL
+	case 0b01:
+		return uint64(first&0b00111111)<<8 | uint64(d.readByte()), NORMAL
+	case 0b10:
+		switch first {
+		case 0x80:
+			return uint64(binary.BigEndian.Uint32(d.readFixed(4))), NORMAL
+		case 0x81:
+			return binary.BigEndian.Uint64(d.readFixed(8)), NORMAL
+		}
+	case 0b11:
+		switch first & 0b00111111 {
+		case 0:
+			return 1, UINT8
+		case 1:
+			return 2, UINT16
+		case 2:
+			return 4, UINT32
+		case 3:
+			// The compressed and uncompressed lengths follow.
+			return 0, LZF
+		}
+	}
+	d.fail(fmt.Errorf("unknown size encoding 0x%02x", first))
+	return 0, UNSUPPORTED
+}
+
+// readLength reads a length, which may take up to 64 bits.
+func (d *decoder) readLength() uint64 {
+	size, content_type := d.readEncodedSize()
+	if content_type != NORMAL {
+		d.fail(errors.New("expected a length, got an encoded string"))
+		return 0
+	}
+	return size
+}
+
+// readLengthEncodedInt reads a length, or an integer encoded like a string.
+func (d *decoder) readLengthEncodedInt() uint64 {
+	size, content_type := d.readEncodedSize()
+	switch content_type {
+	case NORMAL:
+		return size
+	case UINT8:
+		return uint64(d.readByte())
+	case UINT16:
+		return uint64(binary.LittleEndian.Uint16(d.readFixed(2)))
+	case UINT32:
+		return uint64(binary.LittleEndian.Uint32(d.readFixed(4)))
+	}
+	d.fail(errors.New("expected an integer, got a compressed string"))
+	return 0
+}
+
+// readString reads a string, which may be stored as an integer or
+// compressed with LZF.
+func (d *decoder) readString() string {
+	size, content_type := d.readEncodedSize()
+	switch content_type {
+	case NORMAL:
+		if size > maxStringLength {
+			d.fail(fmt.Errorf("string of %d bytes exceeds the maximum length", size))
+			return ""
+		}
+		return string(d.read(size))
+	case UINT8:
+		return strconv.Itoa(int(int8(d.readByte())))
+	case UINT16:
+		return strconv.Itoa(int(int16(binary.LittleEndian.Uint16(d.readFixed(2)))))
+	case UINT32:
+		return strconv.Itoa(int(int32(binary.LittleEndian.Uint32(d.readFixed(4)))))
+	case LZF:
+		compressed_length := d.readLength()
+		length := d.readLength()
+		if d.err == nil && (compressed_length > maxStringLength || length > maxStringLength) {
+			d.fail(fmt.Errorf("compressed string of %d bytes exceeds the maximum length", length))
+		}
+		compressed := d.read(compressed_length)
+		if d.err != nil {
+			return ""
+		}
+		decompressed, err := lzfDecompress(compressed, int(length))
+		d.fail(err)
+		return string(decompressed)
+	}
+	return ""
+}
+
+// readStringDouble reads a double stored as its length and its decimal
+// representation, with special lengths standing for NaN and infinities.
+func (d *decoder) readStringDouble() float64 {
+	length := d.readByte()
+	switch length {
+	case 253:
+		return math.NaN()
+	case 254:
+		return math.Inf(1)
+	case 255:
+		return math.Inf(-1)
+	}
+	str := d.read(uint64(length))
+	if d.err != nil {
+		return 0
+	}
+	value, err := strconv.ParseFloat(string(str), 64)
+	if err != nil {
+		d.fail(fmt.Errorf("invalid double %q", str))
+	}
+	return value
+}
+
+// readStreamID reads a stream id stored as 16 big endian bytes, as in the
+// keys of stream nodes and in pending entry lists.
+func (d *decoder) readStreamID() streamID {
+	data := d.readFixed(16)
+	return streamID{ms: binary.BigEndian.Uint64(data), seq: binary.BigEndian.Uint64(data[8:])}
+}
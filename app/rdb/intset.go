@@ -0,0 +1,38 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+)
+
+var errMalformedIntset = errors.New("malformed intset")
+
+// decodeIntset returns the members of an intset, a sorted array of integers
+// all stored on 2, 4 or 8 bytes, as decimal strings.
+func decodeIntset(data []byte) ([]string, error) {
+	if len(data) < 8 {
+		return nil, errMalformedIntset
+	}
+	width := int(binary.LittleEndian.Uint32(data))
+	count := int(binary.LittleEndian.Uint32(data[4:]))
+	if (width != 2 && width != 4 && width != 8) || count < 0 || len(data) != 8+width*count {
+		return nil, errMalformedIntset
+	}
+
+	members := make([]string, count)
+	for i := range members {
+		item := data[8+width*i:]
+		var value int64
+		switch width {
+		case 2:
+			value = int64(int16(binary.LittleEndian.Uint16(item)))
+		case 4:
+			value = int64(int32(binary.LittleEndian.Uint32(item)))
+		case 8:
+			value = int64(binary.LittleEndian.Uint64(item))
+		}
+		members[i] = strconv.FormatInt(value, 10)
+	}
+	return members, nil
+}
@@ -0,0 +1,204 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+)
+
+// A listpack is a serialized list of strings and integers, used by Redis for
+// small collections and for the nodes of streams. It starts with its total
+// size in bytes and its number of elements, and ends with 0xFF. Each element
+// is its encoding and data followed by the length of both, so the listpack
+// can be walked backwards.
+
+const listpackEnd = 0xFF
+
+var errMalformedListpack = errors.New("malformed listpack")
+
+// encodeListpack serializes elements into a listpack. Elements that are
+// integers in canonical form are stored as integers, like Redis does.
+func encodeListpack(elements []string) []byte {
+	data := make([]byte, 6)
+	for _, element := range elements {
+		start := len(data)
+		data = appendListpackElement(data, element)
+		data = appendListpackBacklen(data, len(data)-start)
+	}
+	data = append(data, listpackEnd)
+
+	binary.LittleEndian.PutUint32(data, uint32(len(data)))
+	count := len(elements)
+	if count > 0xFFFF {
+		// The count is unknown and has to be found by walking the listpack.
+		count = 0xFFFF
+	}
+	binary.LittleEndian.PutUint16(data[4:], uint16(count))
+	return data
+}
+
+func appendListpackElement(data []byte, element string) []byte {
+	if value, ok := canonicalInt(element); ok {
+		switch {
+		case value >= 0 && value <= 127:
+			return append(data, byte(value))
+		case value >= -4096 && value <= 4095:
+			unsigned := uint16(value) & 0x1FFF
+			return append(data, 0xC0|byte(unsigned>>8), byte(unsigned))
+		case value >= -32768 && value <= 32767:
+			return binary.LittleEndian.AppendUint16(append(data, 0xF1), uint16(value))
+		case value >= -8388608 && value <= 8388607:
+			unsigned := uint32(value)
+			return append(data, 0xF2, byte(unsigned), byte(unsigned>>8), byte(unsigned>>16))
+		case value >= -2147483648 && value <= 2147483647:
+			return binary.LittleEndian.AppendUint32(append(data, 0xF3), uint32(value))
+		default:
+			return binary.LittleEndian.AppendUint64(append(data, 0xF4), uint64(value))
+		}
+	}
+
+	size := len(element)
+	switch {
+	case size < 64:
+		data = append(data, 0x80|byte(size))
+	case size < 4096:
+		data = append(data, 0xE0|byte(size>>8), byte(size))
+	default:
+		data = binary.LittleEndian.AppendUint32(append(data, 0xF0), uint32(size))
+	}
+	return append(data, element...)
+}
+
+// appendListpackBacklen appends the length of an element, 7 bits per byte
+// with the most significant bits first. Every byte but the first has its
+// high bit set, which marks where the length starts when read backwards.
+func appendListpackBacklen(data []byte, length int) []byte {
+	size := listpackBacklenSize(length)
+	for i := size - 1; i >= 0; i-- {
+		b := byte(length>>(7*i)) & 127
+		if i != size-1 {
+			b |= 128
+		}
+		data = append(data, b)
+	}
+	return data
+}
+
+// decodeListpack returns the elements of a listpack, with integers formatted
+// as decimal strings.
+func decodeListpack(data []byte) ([]string, error) {
+	if len(data) < 7 || int(binary.LittleEndian.Uint32(data)) != len(data) {
+		return nil, errMalformedListpack
+	}
+
+	elements := []string{}
+	current := 6
+	for current < len(data) && data[current] != listpackEnd {
+		start := current
+		element, n, err := decodeListpackElement(data[current:])
+		if err != nil {
+			return nil, err
+		}
+		current += n + listpackBacklenSize(current+n-start)
+		elements = append(elements, element)
+	}
+	if current != len(data)-1 {
+		return nil, errMalformedListpack
+	}
+	return elements, nil
+}
+
+// decodeListpackElement decodes the element at the start of data, returning
+// it along with the size of its encoding and data.
+func decodeListpackElement(data []byte) (string, int, error) {
+	need := func(n int) bool { return len(data) >= n }
+	encoding := data[0]
+	switch {
+	case encoding&0x80 == 0:
+		return strconv.Itoa(int(encoding)), 1, nil
+	case encoding&0xC0 == 0x80:
+		size := int(encoding & 0x3F)
+		if !need(1 + size) {
+			return "", 0, errMalformedListpack
+		}
+		return string(data[1 : 1+size]), 1 + size, nil
+	case encoding&0xE0 == 0xC0:
+		if !need(2) {
+			return "", 0, errMalformedListpack
+		}
+		unsigned := uint16(encoding&0x1F)<<8 | uint16(data[1])
+		// Sign-extend the 13 bit integer.
+		value := int64(int16(unsigned<<3) >> 3)
+		return strconv.FormatInt(value, 10), 2, nil
+	case encoding&0xF0 == 0xE0:
+		if !need(2) {
+			return "", 0, errMalformedListpack
+		}
+		size := int(encoding&0x0F)<<8 | int(data[1])
+		if !need(2 + size) {
+			return "", 0, errMalformedListpack
+		}
+		return string(data[2 : 2+size]), 2 + size, nil
+	}
+
+	switch encoding {
+	case 0xF0:
+		if !need(5) {
+			return "", 0, errMalformedListpack
+		}
+		size := int(binary.LittleEndian.Uint32(data[1:]))
+		if size < 0 || !need(5+size) {
+			return "", 0, errMalformedListpack
+		}
+		return string(data[5 : 5+size]), 5 + size, nil
+	case 0xF1:
+		if !need(3) {
+			return "", 0, errMalformedListpack
+		}
+		return strconv.FormatInt(int64(int16(binary.LittleEndian.Uint16(data[1:]))), 10), 3, nil
+	case 0xF2:
+		if !need(4) {
+			return "", 0, errMalformedListpack
+		}
+		unsigned := uint32(data[1]) | uint32(data[2])<<8 | uint32(data[3])<<16
+		return strconv.FormatInt(int64(int32(unsigned<<8)>>8), 10), 4, nil
+	case 0xF3:
+		if !need(5) {
+			return "", 0, errMalformedListpack
+		}
+		return strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(data[1:]))), 10), 5, nil
+	case 0xF4:
+		if !need(9) {
+			return "", 0, errMalformedListpack
+		}
+		return strconv.FormatInt(int64(binary.LittleEndian.Uint64(data[1:])), 10), 9, nil
+	}
+	return "", 0, errMalformedListpack
+}
+
+// listpackBacklenSize returns the size of the backlen of an element whose
+// encoding and data take length bytes.
+func listpackBacklenSize(length int) int {
+	switch {
+	case length <= 127:
+		return 1
+	case length < 16383:
+		return 2
+	case length < 2097151:
+		return 3
+	case length < 268435455:
+		return 4
+	default:
+		return 5
+	}
+}
+
+// canonicalInt parses str as a 64 bit integer, unless it is not in the form
+// the integer would be formatted in, such as "007" or "+1".
+func canonicalInt(str string) (int64, bool) {
+	value, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || strconv.FormatInt(value, 10) != str {
+		return 0, false
+	}
+	return value, true
+}
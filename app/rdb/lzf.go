@@ -0,0 +1,61 @@
+package rdb
+
+import "errors"
+
+var errMalformedLZF = errors.New("malformed LZF data")
+
+// lzfDecompress decompresses data compressed with LZF, as Redis does for
+// long strings, into length bytes. The compressed data is a sequence of
+// literal runs, whose control byte holds the run length minus one, and back
+// references, whose control byte holds the length minus two in its top
+// three bits and the high bits of the offset in the others.
+func lzfDecompress(data []byte, length int) ([]byte, error) {
+	// A back reference takes 3 bytes for at most 264 bytes, so a longer
+	// length can only come from corrupted data.
+	if length < 0 || length > 88*len(data) {
+		return nil, errMalformedLZF
+	}
+	out := make([]byte, 0, length)
+	for i := 0; i < len(data); {
+		ctrl := int(data[i])
+		i++
+
+		if ctrl < 32 {
+			run := ctrl + 1
+			if i+run > len(data) || len(out)+run > length {
+				return nil, errMalformedLZF
+			}
+			out = append(out, data[i:i+run]...)
+			i += run
+			continue
+		}
+
+		ref_length := ctrl >> 5
+		if ref_length == 7 {
+			if i >= len(data) {
+				return nil, errMalformedLZF
+			}
+			ref_length += int(data[i])
+			i++
+		}
+		ref_length += 2
+		if i >= len(data) {
+			return nil, errMalformedLZF
+		}
+		ref := len(out) - (ctrl&0x1F)<<8 - int(data[i]) - 1
+		i++
+		if ref < 0 || len(out)+ref_length > length {
+			return nil, errMalformedLZF
+		}
+		// The reference may overlap the bytes being written, so they are
+		// copied one at a time.
+		for j := 0; j < ref_length; j++ {
+			out = append(out, out[ref+j])
+		}
+	}
+
+	if len(out) != length {
+		return nil, errMalformedLZF
+	}
+	return out, nil
+}
@@ -2,10 +2,12 @@ package rdb
 
 import (
 	"encoding/binary"
-	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"strconv"
 
 	"github.com/codecrafters-io/redis-starter-go/app/core"
 	"github.com/codecrafters-io/redis-starter-go/app/resp"
@@ -18,6 +20,7 @@ const (
 	UINT8
 	UINT16
 	UINT32
+	LZF
 	UNSUPPORTED
 )
 
@@ -28,6 +31,11 @@ var opCodes = struct {
 	EXPIRETIMEMS byte
 	RESIZEDB     byte
 	AUX          byte
+	FREQ         byte
+	IDLE         byte
+	MODULE_AUX   byte
+	FUNCTION2    byte
+	SLOT_INFO    byte
 }{
 	EOF:          0xFF,
 	SELECTDB:     0xFE,
@@ -35,265 +43,781 @@ var opCodes = struct {
 	EXPIRETIMEMS: 0xFC,
 	RESIZEDB:     0xFB,
 	AUX:          0xFA,
+	FREQ:         0xF9,
+	IDLE:         0xF8,
+	MODULE_AUX:   0xF7,
+	FUNCTION2:    0xF5,
+	SLOT_INFO:    0xF4,
 }
 
 var rdbValueTypes = struct {
-	STRING             byte
-	LIST               byte
-	SET                byte
-	SORTED_SET         byte
-	HASH               byte
-	ZIPMAP             byte
-	ZIPLIST            byte
-	INTSET             byte
-	SORTED_SET_ZIPLIST byte
-	HASHMAP_ZIPLIST    byte
-	LIST_QUICKLIST     byte
+	STRING                  byte
+	LIST                    byte
+	SET                     byte
+	SORTED_SET              byte
+	HASH                    byte
+	SORTED_SET_2            byte
+	MODULE_PRE_GA           byte
+	MODULE_2                byte
+	ZIPMAP                  byte
+	ZIPLIST                 byte
+	INTSET                  byte
+	SORTED_SET_ZIPLIST      byte
+	HASHMAP_ZIPLIST         byte
+	LIST_QUICKLIST          byte
+	STREAM_LISTPACKS        byte
+	HASH_LISTPACK           byte
+	SORTED_SET_LISTPACK     byte
+	LIST_QUICKLIST_2        byte
+	STREAM_LISTPACKS_2      byte
+	SET_LISTPACK            byte
+	STREAM_LISTPACKS_3      byte
+	HASH_METADATA_PRE_GA    byte
+	HASH_LISTPACK_EX_PRE_GA byte
+	HASH_METADATA           byte
+	HASH_LISTPACK_EX        byte
 }{
-	STRING:             0,
-	LIST:               1,
-	SET:                2,
-	SORTED_SET:         3,
-	HASH:               4,
-	ZIPMAP:             9,
-	ZIPLIST:            10,
-	INTSET:             11,
-	SORTED_SET_ZIPLIST: 12,
-	HASHMAP_ZIPLIST:    13,
-	LIST_QUICKLIST:     14,
+	STRING:                  0,
+	LIST:                    1,
+	SET:                     2,
+	SORTED_SET:              3,
+	HASH:                    4,
+	SORTED_SET_2:            5,
+	MODULE_PRE_GA:           6,
+	MODULE_2:                7,
+	ZIPMAP:                  9,
+	ZIPLIST:                 10,
+	INTSET:                  11,
+	SORTED_SET_ZIPLIST:      12,
+	HASHMAP_ZIPLIST:         13,
+	LIST_QUICKLIST:          14,
+	STREAM_LISTPACKS:        15,
+	HASH_LISTPACK:           16,
+	SORTED_SET_LISTPACK:     17,
+	LIST_QUICKLIST_2:        18,
+	STREAM_LISTPACKS_2:      19,
+	SET_LISTPACK:            20,
+	STREAM_LISTPACKS_3:      21,
+	HASH_METADATA_PRE_GA:    22,
+	HASH_LISTPACK_EX_PRE_GA: 23,
+	HASH_METADATA:           24,
+	HASH_LISTPACK_EX:        25,
 }
 
-func ReadFile(filename string) (*core.Store, error) {
-	var store core.Store
-	var current uint64 = 9
-	store.Init()
+// quicklistContainer is how the nodes of a quicklist in version 2 are
+// stored, either as a single plain element or as a listpack of elements.
+var quicklistContainer = struct {
+	PLAIN  uint64
+	PACKED uint64
+}{
+	PLAIN:  1,
+	PACKED: 2,
+}
 
-	data, err := os.ReadFile(filename)
+// ReadFile loads an RDB file into the databases of store, like Read. A
+// missing file leaves the store empty.
+func ReadFile(filename string, store *core.Store) error {
+	file, err := os.Open(filename)
 	if errors.Is(err, os.ErrNotExist) {
-		return &store, nil
+		return nil
 	}
 	if err != nil {
-		return &store, fmt.Errorf("unable to read RDB file: %w", err)
+		return fmt.Errorf("unable to read RDB file: %w", err)
 	}
+	defer file.Close()
+	return Read(file, store)
+}
+
+// Read loads RDB data from r into the databases of store, decoding it as it
+// is read, so that only the current value is held in memory on top of the
+// loaded data. Keys are loaded into the database selected by the SELECTDB
+// opcode preceding them. Data that cannot be loaded is reported with a
+// *FormatError, in which case the keys read before the problem are loaded.
+// Function libraries and the auxiliary data of modules are skipped
+// silently; a Scanner reports them.
+func Read(r io.Reader, store *core.Store) error {
+	return load(newDecoder(r), store)
+}
+
+// ChecksumEnabled reports whether the rdbchecksum parameter of store asks
+// for RDB files to end with a checksum, and for the checksum of the files
+// loaded to be verified.
+func ChecksumEnabled(store *core.Store) bool {
+	value, _ := store.GetParam("rdbchecksum")
+	return value != "no"
+}
 
-	header := string(data[:9])
-	if header[:5] != "REDIS" {
-		return &store, fmt.Errorf("unknown header in RDB file")
+// Entry is a key read by a Scanner, along with its value and expiry.
+type Entry struct {
+	// DB is the index of the database holding the key.
+	DB    int
+	Key   string
+	Value resp.Object
+	// Expiry is the unix time in milliseconds at which the key expires,
+	// when HasExpiry is set.
+	Expiry    int64
+	HasExpiry bool
+	// Size is the number of bytes taken by the key, its value and its
+	// expiry in the data.
+	Size uint64
+}
+
+// Scanner reads RDB data without loading it into a store, for tools that
+// inspect RDB files. Like Read, it decodes the data as it is read.
+type Scanner struct {
+	// VerifyChecksum makes Scan verify the checksum ending the data.
+	VerifyChecksum bool
+	// Aux, if set, is called with each auxiliary field of the data, such as
+	// the version of the server that wrote it.
+	Aux func(key string, value string)
+	// Skipped, if set, is called with a description of each part of the
+	// data that is skipped because it is not supported, such as function
+	// libraries and the auxiliary data of modules.
+	Skipped func(reason string)
+	// Key is called with each key, in the order they are stored. An error
+	// returned by Key stops the scan and is returned by Scan as is.
+	Key func(entry Entry) error
+	// Version is the version of the format of the data, set once its header
+	// is read.
+	Version int
+}
+
+// Scan reads the RDB data in r, calling the callbacks of s. Data that
+// cannot be read is reported with a *FormatError, after the keys read
+// before the problem were passed to Key.
+func (s *Scanner) Scan(r io.Reader) error {
+	return newDecoder(r).scan(s, 0)
+}
+
+// load reads RDB data from d into store.
+func load(d *decoder, store *core.Store) error {
+	s := &Scanner{
+		VerifyChecksum: ChecksumEnabled(store),
+		Aux:            store.SetParam,
+		Key: func(entry Entry) error {
+			db := store.DB(entry.DB)
+			if entry.HasExpiry {
+				db.SetWithAbsoluteExpiry(entry.Key, entry.Value, uint64(entry.Expiry))
+			} else {
+				db.Set(entry.Key, entry.Value)
+			}
+			trackValue(db, entry.Key, entry.Value)
+			return nil
+		},
 	}
+	return d.scan(s, store.Databases())
+}
+
+// scan reads RDB data from d, calling the callbacks of s. SELECTDB opcodes
+// selecting a database past databases are rejected, unless it is 0.
+func (d *decoder) scan(s *Scanner, databases int) error {
+	s.Version = d.readHeader()
+	db := 0
 
-	for {
-		section := data[current]
-		current++
+	for d.err == nil {
+		start := d.offset
+		section := d.readByte()
 		switch section {
 		case opCodes.AUX:
-			n, key := readEncodedString(data[current:])
-			current += n
-			n, value := readEncodedString(data[current:])
-			current += n
-			store.SetParam(key, value)
+			key := d.readString()
+			value := d.readString()
+			if d.err == nil && s.Aux != nil {
+				s.Aux(key, value)
+			}
 
 		case opCodes.RESIZEDB:
-			n, kv_size := readLengthEncodedInt(data[current:])
-			current += uint64(n)
-			n, expiry_size := readLengthEncodedInt(data[current:])
-			current += uint64(n)
-			fmt.Printf("keyspace size: %d, expiry space size: %d\n", kv_size, expiry_size)
-
-		case opCodes.EXPIRETIMEMS:
-			expiry := binary.LittleEndian.Uint64(data[current:])
-			current += 8
-			n, key, value := readKeyValue(data[current:])
-			current += n
-			store.SetWithAbsoluteExpiry(key, value, expiry)
-
-		case opCodes.EXPIRETIME:
-			expiry := uint64(binary.LittleEndian.Uint32(data[current:]))
-			current += 4
-			n, key, value := readKeyValue(data[current:])
-			current += n
-			store.SetWithAbsoluteExpiry(key, value, expiry*1000)
+			// The sizes of the keyspace and of the expiry space, which are
+			// only hints.
+			d.readLengthEncodedInt()
+			d.readLengthEncodedInt()
+
+		case opCodes.EXPIRETIMEMS, opCodes.EXPIRETIME:
+			var expiry int64
+			if section == opCodes.EXPIRETIMEMS {
+				expiry = d.readMillis()
+			} else {
+				expiry = int64(binary.LittleEndian.Uint32(d.readFixed(4))) * 1000
+			}
+			key, value := d.readKeyValue(d.readByte())
+			if d.err != nil {
+				return d.formatError(key)
+			}
+			entry := Entry{DB: db, Key: key, Value: value, Expiry: expiry, HasExpiry: true, Size: d.offset - start}
+			if err := s.Key(entry); err != nil {
+				return err
+			}
 
 		case opCodes.SELECTDB:
-			n, size, content_type := readEncodedSize(data[current:])
-			current += uint64(n)
-			fmt.Printf("size: %d, content_type: %d\n", size, content_type)
+			index := d.readLengthEncodedInt()
+			if d.err != nil {
+				break
+			}
+			if databases > 0 && index >= uint64(databases) {
+				d.fail(fmt.Errorf("RDB file selects database %d, but there are only %d databases", index, databases))
+				break
+			}
+			if index > math.MaxInt32 {
+				d.fail(fmt.Errorf("invalid database index %d", index))
+				break
+			}
+			db = int(index)
 
 		case opCodes.EOF:
-			checksum := data[current : current+8]
-			fmt.Println(checksum)
-			return &store, nil
-
-		case rdbValueTypes.LIST, rdbValueTypes.SET, rdbValueTypes.STRING:
-			current -= 1
-			n, key, value := readKeyValue(data[current:])
-			current += n
-			store.Set(key, value)
+			if s.Version < 5 {
+				// Files only end with a checksum since version 5.
+				return nil
+			}
+			expected := d.crc
+			checksum := binary.LittleEndian.Uint64(d.readFixed(8))
+			// A checksum of 0 means the file was written without one.
+			if d.err == nil && s.VerifyChecksum && checksum != 0 && checksum != expected {
+				d.fail(fmt.Errorf("%w, expected %016x, got %016x", ErrChecksum, expected, checksum))
+			}
+			return d.formatError("")
+
+		case opCodes.IDLE:
+			// The idle time and access frequency of the next key only matter
+			// to eviction, which is not supported.
+			d.readLength()
+
+		case opCodes.FREQ:
+			d.readByte()
+
+		case opCodes.MODULE_AUX:
+			name := d.skipModuleAux()
+			if d.err == nil && s.Skipped != nil {
+				s.Skipped(fmt.Sprintf("the auxiliary data of module %s, modules are not supported", name))
+			}
+
+		case opCodes.FUNCTION2:
+			d.readString()
+			if d.err == nil && s.Skipped != nil {
+				s.Skipped("a function library, functions are not supported")
+			}
+
+		case opCodes.SLOT_INFO:
+			// The slot number, its key count and its expiring key count.
+			for i := 0; i < 3; i++ {
+				d.readLength()
+			}
 
 		default:
-			return &store, errors.New("malformed RDB file")
+			key, value := d.readKeyValue(section)
+			if d.err != nil {
+				return d.formatError(key)
+			}
+			if err := s.Key(Entry{DB: db, Key: key, Value: value, Size: d.offset - start}); err != nil {
+				return err
+			}
 		}
 	}
+	return d.formatError("")
+}
+
+// readHeader reads the magic string and the version starting RDB files,
+// returning the version.
+func (d *decoder) readHeader() int {
+	header := d.read(9)
+	if d.err != nil {
+		return 0
+	}
+	if string(header[:5]) != "REDIS" {
+		d.fail(errors.New("unknown header"))
+		return 0
+	}
+	version, err := strconv.Atoi(string(header[5:]))
+	if err != nil || version < 1 {
+		d.fail(fmt.Errorf("invalid version %q", header[5:]))
+		return 0
+	}
+	if version > rdbVersion {
+		d.fail(fmt.Errorf("%w %d, the latest supported version is %d", ErrUnsupportedVersion, version, rdbVersion))
+		return 0
+	}
+	return version
 }
 
-func readEncodedString(data []byte) (bytes_read uint64, str string) {
-	bytes_read = 0
-	str = ""
-
-	n, size, content_type := readEncodedSize(data)
-	bytes_read += uint64(n)
-	switch content_type {
-	case NORMAL:
-		str = string(data[bytes_read : bytes_read+uint64(size)])
-	case UINT8, UINT16, UINT32:
-		_, integer := readLengthEncodedInt(data)
-		str = fmt.Sprintf("%d", integer)
+// isValueType reports whether data_type is the type of a key value pair
+// rather than an opcode.
+func isValueType(data_type byte) bool {
+	return data_type <= rdbValueTypes.HASH_LISTPACK_EX && data_type != 8
+}
+
+// readKeyValue reads a key and its value of type data_type. The key is
+// returned even if the value cannot be read, to report where the problem is.
+func (d *decoder) readKeyValue(data_type byte) (key string, value resp.Object) {
+	if d.err == nil && !isValueType(data_type) {
+		d.fail(fmt.Errorf("unknown opcode or value type %d", data_type))
+		return "", nil
+	}
+	key = d.readString()
+
+	switch data_type {
+	case rdbValueTypes.STRING:
+		value = resp.BulkString(d.readString())
+	case rdbValueTypes.LIST:
+		value = core.NewList(d.readRDBList()...)
+	case rdbValueTypes.SET:
+		set := d.readRDBSet()
+		members := make([]string, 0, len(set))
+		for member := range set {
+			str, _ := resp.ToString(member)
+			members = append(members, str)
+		}
+		value = core.NewSet(members...)
+	case rdbValueTypes.HASH:
+		value = d.readRDBHash()
+	case rdbValueTypes.HASH_METADATA, rdbValueTypes.HASH_METADATA_PRE_GA:
+		value = d.readRDBHashMetadata(data_type == rdbValueTypes.HASH_METADATA_PRE_GA)
+	case rdbValueTypes.SORTED_SET, rdbValueTypes.SORTED_SET_2:
+		value = d.readRDBSortedSet(data_type == rdbValueTypes.SORTED_SET_2)
+	case rdbValueTypes.STREAM_LISTPACKS, rdbValueTypes.STREAM_LISTPACKS_2, rdbValueTypes.STREAM_LISTPACKS_3:
+		value = d.readRDBStream(data_type)
+	case rdbValueTypes.LIST_QUICKLIST, rdbValueTypes.LIST_QUICKLIST_2:
+		value = core.NewList(d.readRDBQuicklist(data_type == rdbValueTypes.LIST_QUICKLIST_2)...)
+	case rdbValueTypes.MODULE_PRE_GA, rdbValueTypes.MODULE_2:
+		d.fail(errors.New("module values are not supported"))
 	default:
-		fmt.Fprintf(os.Stderr, "unsupported string type")
-		os.Exit(1)
+		if data_type == rdbValueTypes.HASH_LISTPACK_EX {
+			// The earliest field expiry, which the fields themselves imply.
+			d.readMillis()
+		}
+		encoded := d.readString()
+		if d.err == nil {
+			var err error
+			value, err = decodeCompactValue(data_type, []byte(encoded))
+			d.fail(err)
+		}
+	}
+
+	if d.err != nil {
+		return key, nil
 	}
-	bytes_read += uint64(size)
+	return key, value
+}
 
-	return
+func (d *decoder) readRDBList() []string {
+	size := d.readLength()
+	// The elements are appended as they are read, so that a corrupted size
+	// does not allocate a huge list.
+	list := []string{}
+	for i := uint64(0); i < size && d.err == nil; i++ {
+		list = append(list, d.readString())
+	}
+	return list
 }
 
-func readEncodedSize(data []byte) (bytes_read uint8, size uint32, content_type contentType) {
-	bytes_read = 0
-	size = 0
-	content_type = NORMAL
-
-	bits := (0b11000000 & data[0]) >> 6
-	switch bits {
-	case 0b00:
-		bytes_read = 1
-		size = 0b00111111 & uint32(data[0])
-		content_type = NORMAL
-	case 0b01:
-		bytes_read = 2
-		size = (0b00111111 & uint32(data[0])) << 8
-		size += uint32(data[1])
-		content_type = NORMAL
-	case 0b10:
-		bytes_read = 5
-		size = binary.BigEndian.Uint32(data[1:])
-		content_type = NORMAL
-	case 0b11:
-		bits := 0b00111111 & data[0]
-		switch bits {
-		case 0:
-			bytes_read = 1
-			size = 1
-			content_type = contentType(UINT8)
-		case 1:
-			bytes_read = 1
-			size = 2
-			content_type = UINT16
-		case 2:
-			bytes_read = 1
-			size = 4
-			content_type = UINT32
+// readRDBQuicklist reads a list stored as nodes holding several elements:
+// ziplists in version 1, and either listpacks or single plain elements in
+// version 2.
+func (d *decoder) readRDBQuicklist(version_2 bool) []string {
+	nodes := d.readLength()
+	list := []string{}
+	for i := uint64(0); i < nodes && d.err == nil; i++ {
+		container := quicklistContainer.PACKED
+		if version_2 {
+			container = d.readLength()
+		}
+		node := d.readString()
+		if d.err != nil {
+			break
+		}
+
+		var elements []string
+		var err error
+		switch {
+		case container == quicklistContainer.PLAIN:
+			elements = []string{node}
+		case container != quicklistContainer.PACKED:
+			err = fmt.Errorf("unknown quicklist container %d", container)
+		case version_2:
+			elements, err = decodeListpack([]byte(node))
 		default:
-			bytes_read = 0
-			size = 0
-			content_type = UNSUPPORTED
+			elements, err = decodeZiplist([]byte(node))
 		}
+		d.fail(err)
+		list = append(list, elements...)
 	}
-	return
+	return list
 }
 
-func readLengthEncodedInt(data []byte) (bytes_read uint8, integer uint32) {
-	bytes_read = 0
-	integer = 0
-
-	bytes_read, size, content_type := readEncodedSize(data)
-
-	switch content_type {
-	case NORMAL:
-		integer = size
-		return
-	case UINT8:
-		integer = uint32(data[bytes_read])
-	case UINT16:
-		integer = uint32(binary.LittleEndian.Uint16(data[bytes_read : bytes_read+uint8(size)]))
-	case UINT32:
-		integer = binary.LittleEndian.Uint32(data[bytes_read : bytes_read+uint8(size)])
+// decodeCompactValue decodes a value stored as a single string in a compact
+// encoding: a ziplist or listpack of elements, of field and value or member
+// and score pairs, or of field, value and expiry triplets, an intset, or a
+// zipmap.
+func decodeCompactValue(data_type byte, encoded []byte) (resp.Object, error) {
+	var elements []string
+	var err error
+	switch data_type {
+	case rdbValueTypes.ZIPMAP:
+		elements, err = decodeZipmap(encoded)
+	case rdbValueTypes.INTSET:
+		elements, err = decodeIntset(encoded)
+	case rdbValueTypes.ZIPLIST, rdbValueTypes.SORTED_SET_ZIPLIST, rdbValueTypes.HASHMAP_ZIPLIST:
+		elements, err = decodeZiplist(encoded)
 	default:
-		fmt.Fprintf(os.Stderr, "unsupported size encoding")
-		os.Exit(1)
+		elements, err = decodeListpack(encoded)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch data_type {
+	case rdbValueTypes.ZIPLIST:
+		return core.NewList(elements...), nil
+	case rdbValueTypes.INTSET, rdbValueTypes.SET_LISTPACK:
+		return core.NewSet(elements...), nil
 	}
 
-	bytes_read += uint8(size)
+	if data_type == rdbValueTypes.HASH_LISTPACK_EX_PRE_GA || data_type == rdbValueTypes.HASH_LISTPACK_EX {
+		if len(elements)%3 != 0 {
+			return nil, errors.New("incomplete field, value and expiry triplet")
+		}
+		hash := core.NewHash()
+		for i := 0; i < len(elements); i += 3 {
+			hash.Set(elements[i], elements[i+1])
+			// The expiry is a unix time in milliseconds, or 0 for none.
+			expiry, err := strconv.ParseInt(elements[i+2], 10, 64)
+			if err != nil || expiry < 0 {
+				return nil, fmt.Errorf("invalid field expiry %q", elements[i+2])
+			}
+			if expiry != 0 {
+				hash.SetFieldExpiry(elements[i], expiry)
+			}
+		}
+		return hash, nil
+	}
 
-	return
+	if len(elements)%2 != 0 {
+		return nil, errors.New("odd number of elements")
+	}
+	switch data_type {
+	case rdbValueTypes.ZIPMAP, rdbValueTypes.HASHMAP_ZIPLIST, rdbValueTypes.HASH_LISTPACK:
+		hash := core.NewHash()
+		for i := 0; i < len(elements); i += 2 {
+			hash.Set(elements[i], elements[i+1])
+		}
+		return hash, nil
+	}
+	sorted_set := core.NewSortedSet()
+	for i := 0; i < len(elements); i += 2 {
+		score, err := strconv.ParseFloat(elements[i+1], 64)
+		if err != nil || math.IsNaN(score) {
+			return nil, fmt.Errorf("invalid score %q", elements[i+1])
+		}
+		sorted_set.Add(elements[i], score)
+	}
+	return sorted_set, nil
 }
 
-func readKeyValue(data []byte) (bytes_read uint64, key string, value resp.Object) {
+func (d *decoder) readRDBSet() map[resp.Object]struct{} {
+	list := d.readRDBList()
+	set := make(map[resp.Object]struct{})
+	for _, str := range list {
+		set[resp.BulkString(str)] = struct{}{}
+	}
+	return set
+}
 
-	data_type := data[0]
+func (d *decoder) readRDBHash() *core.Hash {
+	size := d.readLength()
+	hash := core.NewHash()
+	for i := uint64(0); i < size && d.err == nil; i++ {
+		field := d.readString()
+		value := d.readString()
+		hash.Set(field, value)
+	}
+	return hash
+}
 
-	bytes_read = 1
+// readRDBHashMetadata reads a hash with field expiries. The expiry of each
+// field is stored relative to the earliest one, plus one, with 0 standing
+// for fields without an expiry. Files written by release candidates store
+// the absolute expiry of each field instead.
+func (d *decoder) readRDBHashMetadata(pre_ga bool) *core.Hash {
+	var min_expiry int64
+	if !pre_ga {
+		min_expiry = d.readMillis()
+	}
+	size := d.readLength()
+
+	hash := core.NewHash()
+	for i := uint64(0); i < size && d.err == nil; i++ {
+		var expiry int64
+		if pre_ga {
+			expiry = d.readMillis()
+		} else if ttl := d.readLength(); ttl != 0 {
+			expiry = min_expiry + int64(ttl) - 1
+		}
+		field := d.readString()
+		value := d.readString()
+		hash.Set(field, value)
+		if expiry != 0 {
+			hash.SetFieldExpiry(field, expiry)
+		}
+	}
+	return hash
+}
 
-	n, key := readEncodedString(data[bytes_read:])
-	bytes_read += n
+// readRDBSortedSet reads a sorted set, with binary scores or, in the older
+// format, with scores as strings.
+func (d *decoder) readRDBSortedSet(binary_scores bool) *core.SortedSet {
+	size := d.readLength()
+	sorted_set := core.NewSortedSet()
+	for i := uint64(0); i < size && d.err == nil; i++ {
+		member := d.readString()
+		var score float64
+		if binary_scores {
+			score = d.readDouble()
+		} else {
+			score = d.readStringDouble()
+		}
+		if math.IsNaN(score) {
+			d.fail(fmt.Errorf("invalid score for member %q", member))
+		}
+		if d.err == nil {
+			sorted_set.Add(member, score)
+		}
+	}
+	return sorted_set
+}
 
-	switch data_type {
-	case rdbValueTypes.STRING:
-		n, str := readEncodedString(data[bytes_read:])
-		value = resp.BulkString(str)
-		bytes_read += n
-	case rdbValueTypes.LIST:
-		n, list := readRDBList(data[bytes_read:])
-		value = resp.StringsToArray(list)
-		bytes_read += n
-	case rdbValueTypes.SET:
-		n, set := readRDBSet(data[bytes_read:])
-		value = resp.Set(set)
-		bytes_read += n
-	default:
-		fmt.Fprintf(os.Stderr, "unsupported key/value type\n")
-		os.Exit(1)
+// readRDBStream reads a stream stored as listpack nodes, followed by its
+// metadata and its consumer groups. Each version of the format adds fields
+// to the metadata, the groups and the consumers.
+func (d *decoder) readRDBStream(data_type byte) *resp.Stream {
+	read_id := func() streamID {
+		return streamID{ms: d.readLength(), seq: d.readLength()}
+	}
+
+	stream := &resp.Stream{}
+	nodes := d.readLength()
+	for i := uint64(0); i < nodes && d.err == nil; i++ {
+		master_key := d.readString()
+		listpack := d.readString()
+		if d.err != nil {
+			break
+		}
+		if len(master_key) != 16 {
+			d.fail(errors.New("invalid stream node key"))
+			break
+		}
+		master_id := streamID{
+			ms:  binary.BigEndian.Uint64([]byte(master_key)),
+			seq: binary.BigEndian.Uint64([]byte(master_key[8:])),
+		}
+		elements, err := decodeListpack([]byte(listpack))
+		if err == nil {
+			err = readStreamNode(stream, master_id, elements)
+		}
+		d.fail(err)
 	}
 
-	return
+	// The length is implied by the entries.
+	d.readLength()
+	stream.LastID = read_id().String()
+	if data_type != rdbValueTypes.STREAM_LISTPACKS {
+		// The first id is implied by the entries.
+		read_id()
+		if max_deleted := read_id(); max_deleted != (streamID{}) {
+			stream.MaxDeletedID = max_deleted.String()
+		}
+		stream.EntriesAdded = d.readLength()
+	}
+
+	groups := d.readLength()
+	for i := uint64(0); i < groups && d.err == nil; i++ {
+		group := &resp.StreamGroup{Name: d.readString(), LastID: read_id().String(), EntriesRead: -1}
+		if data_type != rdbValueTypes.STREAM_LISTPACKS {
+			group.EntriesRead = int64(d.readLength())
+		}
+
+		pending := d.readLength()
+		owned := map[string]int{}
+		for j := uint64(0); j < pending && d.err == nil; j++ {
+			id := d.readStreamID().String()
+			owned[id] = len(group.Pending)
+			group.Pending = append(group.Pending, resp.StreamPendingEntry{
+				Id:            id,
+				DeliveryTime:  d.readMillis(),
+				DeliveryCount: d.readLength(),
+			})
+		}
+
+		consumers := d.readLength()
+		for j := uint64(0); j < consumers && d.err == nil; j++ {
+			consumer := resp.StreamConsumer{Name: d.readString(), SeenTime: d.readMillis()}
+			consumer.ActiveTime = consumer.SeenTime
+			if data_type == rdbValueTypes.STREAM_LISTPACKS_3 {
+				consumer.ActiveTime = d.readMillis()
+			}
+			group.Consumers = append(group.Consumers, consumer)
+
+			// The ids of the pending entries delivered to the consumer, which
+			// the group lists with their delivery details.
+			consumer_pending := d.readLength()
+			for k := uint64(0); k < consumer_pending && d.err == nil; k++ {
+				id := d.readStreamID().String()
+				if d.err != nil {
+					break
+				}
+				index, ok := owned[id]
+				if !ok || group.Pending[index].Consumer != "" {
+					d.fail(fmt.Errorf("consumer %q of group %q has an unknown pending entry %s", consumer.Name, group.Name, id))
+					break
+				}
+				group.Pending[index].Consumer = consumer.Name
+			}
+		}
+		for _, entry := range group.Pending {
+			if d.err == nil && entry.Consumer == "" {
+				d.fail(fmt.Errorf("pending entry %s of group %q has no consumer", entry.Id, group.Name))
+			}
+		}
+		stream.Groups = append(stream.Groups, group)
+	}
+	return stream
 }
 
-func readRDBList(data []byte) (bytes_read uint64, list []string) {
-	bytes_read = 0
+// readStreamNode adds the entries of a stream node to stream. The node
+// starts with a master entry holding the entry count, the deleted entry
+// count and the master fields, which entries flagged with the same fields
+// omit.
+func readStreamNode(stream *resp.Stream, master_id streamID, elements []string) error {
+	malformed := errors.New("malformed stream node")
+	next := func() (string, bool) {
+		if len(elements) == 0 {
+			return "", false
+		}
+		element := elements[0]
+		elements = elements[1:]
+		return element, true
+	}
+	next_int := func() (int64, bool) {
+		element, ok := next()
+		if !ok {
+			return 0, false
+		}
+		value, err := strconv.ParseInt(element, 10, 64)
+		return value, err == nil
+	}
 
-	n, size := readLengthEncodedInt(data)
-	bytes_read += uint64(n)
-	list = make([]string, size)
-	var i uint32
-	for i = 0; i < size; i++ {
-		n, str := readEncodedString(data[bytes_read:])
-		bytes_read += n
-		list[i] = str
+	count, ok_count := next_int()
+	deleted, ok_deleted := next_int()
+	field_count, ok_fields := next_int()
+	if !ok_count || !ok_deleted || !ok_fields || field_count < 0 || int(field_count) > len(elements) {
+		return malformed
+	}
+	master_fields := elements[:field_count]
+	elements = elements[field_count:]
+	if terminator, ok := next(); !ok || terminator != "0" {
+		return malformed
 	}
 
-	return
+	for i := int64(0); i < count+deleted; i++ {
+		flags, ok_flags := next_int()
+		ms_diff, ok_ms := next_int()
+		seq_diff, ok_seq := next_int()
+		if !ok_flags || !ok_ms || !ok_seq {
+			return malformed
+		}
+
+		fields := master_fields
+		var values []string
+		if flags&streamItemFlagSameFields != 0 {
+			if len(elements) < len(fields) {
+				return malformed
+			}
+			values = elements[:len(fields)]
+			elements = elements[len(fields):]
+		} else {
+			pairs, ok := next_int()
+			if !ok || pairs < 0 || pairs > int64(len(elements)/2) {
+				return malformed
+			}
+			fields = make([]string, pairs)
+			values = make([]string, pairs)
+			for j := range fields {
+				fields[j], values[j] = elements[2*j], elements[2*j+1]
+			}
+			elements = elements[2*pairs:]
+		}
+		if _, ok := next(); !ok {
+			return malformed
+		}
+
+		if flags&streamItemFlagDeleted != 0 {
+			continue
+		}
+		id := streamID{
+			ms:  master_id.ms + uint64(ms_diff),
+			seq: master_id.seq + uint64(seq_diff),
+		}
+		data := make(map[string]resp.Object, len(fields))
+		for j, field := range fields {
+			data[field] = resp.BulkString(values[j])
+		}
+		stream.AddEntry(id.String(), data)
+	}
+	return nil
 }
 
-func readRDBSet(data []byte) (bytes_read uint64, set map[resp.Object]struct{}) {
-	bytes_read, list := readRDBList(data)
-	set = make(map[resp.Object]struct{})
-	for _, str := range list {
-		set[resp.BulkString(str)] = struct{}{}
+// moduleNameCharset is the charset of the names of modules, which are
+// encoded in their ids along with their encoding version.
+const moduleNameCharset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+// The opcodes of the values serialized by modules.
+const (
+	moduleOpcodeEOF    = 0
+	moduleOpcodeSInt   = 1
+	moduleOpcodeUInt   = 2
+	moduleOpcodeFloat  = 3
+	moduleOpcodeDouble = 4
+	moduleOpcodeString = 5
+)
+
+// skipModuleAux skips the auxiliary data of a module, which only the module
+// itself can interpret, and returns the name of the module.
+func (d *decoder) skipModuleAux() string {
+	module_id := d.readLength()
+	name := make([]byte, 9)
+	for i := range name {
+		name[i] = moduleNameCharset[(module_id>>(64-6*(i+1)))&63]
 	}
-	return
+	if d.err != nil {
+		return ""
+	}
+
+	// When the data is loaded, relative to the keys, as an unsigned integer.
+	if opcode := d.readLength(); opcode != moduleOpcodeUInt {
+		d.fail(fmt.Errorf("invalid auxiliary data of module %s", name))
+		return string(name)
+	}
+	d.readLength()
+
+	for d.err == nil {
+		switch d.readLength() {
+		case moduleOpcodeEOF:
+			return string(name)
+		case moduleOpcodeSInt, moduleOpcodeUInt:
+			d.readLength()
+		case moduleOpcodeFloat:
+			d.readFixed(4)
+		case moduleOpcodeDouble:
+			d.readFixed(8)
+		case moduleOpcodeString:
+			d.readString()
+		default:
+			d.fail(fmt.Errorf("invalid auxiliary data of module %s", name))
+		}
+	}
+	return string(name)
 }
 
-func GenerateFile(_ *core.Store) []byte {
-	hex_str := "524544495330303131fa0972656469732d76657205372e322e30fa0a72656469732d62697473c040fa056374696d65c26d08bc65fa08757365642d6d656dc2b0c41000fa08616f662d62617365c000fff06e3bfec0ff5aa2"
-	data, _ := hex.DecodeString(hex_str)
-	return data
-	// data := make([]byte, 0)
-	// data = append(data, "REDIS0006"...)
-	// data = append(data, 0xFE, 00)
-	// data = append(data, 0xFB, 0x00, 0x00)
-
-	// var poly uint64 = 0xad93d23594c935a9
-	// table := crc64.MakeTable(poly)
-	// checksum := crc64.New(table).Sum(data)
-	// data = append(data, 0xFF)
-	// data = append(data, checksum...)
-	// return data
+// trackValue registers a loaded hash with field expiries with the active
+// expiry cycle.
+func trackValue(db *core.Store, key string, value resp.Object) {
+	if hash, ok := value.(*core.Hash); ok && hash.HasFieldExpiries() {
+		db.TrackHashFieldExpiry(key)
+	}
 }
@@ -1,8 +1,16 @@
 package rdb
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strings"
 	"testing"
+	"testing/iotest"
 
+	"github.com/codecrafters-io/redis-starter-go/app/core"
 	"github.com/codecrafters-io/redis-starter-go/app/resp"
 )
 
@@ -11,8 +19,8 @@ func TestReadEncodedSize(t *testing.T) {
 	tests := []struct {
 		name         string
 		bytes        []byte
-		bytes_read   uint8
-		size         uint32
+		bytes_read   uint64
+		size         uint64
 		content_type contentType
 	}{
 		{name: "0b00", bytes: []byte{0x0A}, bytes_read: 1, size: 10, content_type: NORMAL},
@@ -25,7 +33,9 @@ func TestReadEncodedSize(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			bytes_read, size, content_type := readEncodedSize(test.bytes)
+			d := newDecoder(bytes.NewReader(test.bytes))
+			size, content_type := d.readEncodedSize()
+			bytes_read := d.offset
 			if bytes_read != test.bytes_read || size != test.size || content_type != test.content_type {
 				t.Errorf("Expected: bytes_read = %d, size = %d, content_type: %d\nGot: bytes_read: %d, size = %d, content_type: %d",
 					test.bytes_read, test.size, test.content_type, bytes_read, size, content_type,
@@ -40,8 +50,8 @@ func TestReadLengthEncodedInt(t *testing.T) {
 	tests := []struct {
 		name       string
 		bytes      []byte
-		bytes_read uint8
-		integer    uint32
+		bytes_read uint64
+		integer    uint64
 	}{
 		{name: "normal type #1", bytes: []byte{0x03}, bytes_read: 1, integer: 3},
 		{name: "normal type #2", bytes: []byte{0x02}, bytes_read: 1, integer: 2},
@@ -52,7 +62,9 @@ func TestReadLengthEncodedInt(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			bytes_read, integer := readLengthEncodedInt(test.bytes)
+			d := newDecoder(bytes.NewReader(test.bytes))
+			integer := d.readLengthEncodedInt()
+			bytes_read := d.offset
 			if bytes_read != test.bytes_read || integer != test.integer {
 				t.Errorf("Expected: bytes_read = %d, integer: %d\nGot: bytes_read: %d, integer: %d",
 					test.bytes_read, test.integer, bytes_read, integer,
@@ -81,11 +93,14 @@ func TestReadEncodedString(t *testing.T) {
 		{name: "uint8", bytes: []byte{0xC0, 0x7B}, bytes_read: 2, str: "123"},
 		{name: "uint16", bytes: []byte{0xC1, 0x39, 0x30}, bytes_read: 3, str: "12345"},
 		{name: "uint32", bytes: []byte{0xC2, 0x87, 0xD6, 0x12, 0x00}, bytes_read: 5, str: "1234567"},
+		{name: "lzf", bytes: []byte{0xC3, 0x05, 0x14, 0x00, 0x61, 0xE0, 0x0A, 0x00}, bytes_read: 8, str: strings.Repeat("a", 20)},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			bytes_read, str := readEncodedString(test.bytes)
+			d := newDecoder(bytes.NewReader(test.bytes))
+			str := d.readString()
+			bytes_read := d.offset
 			if bytes_read != test.bytes_read || str != test.str {
 				t.Errorf("Expected: bytes_read = %d, str: %s\nGot: bytes_read: %d, str: %s",
 					test.bytes_read, test.str, bytes_read, str,
@@ -109,7 +124,9 @@ func TestReadRDBList(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			bytes_read, list := readRDBList(test.bytes)
+			d := newDecoder(bytes.NewReader(test.bytes))
+			list := d.readRDBList()
+			bytes_read := d.offset
 			if bytes_read != test.bytes_read || !equalSlices(list, test.list) {
 				t.Errorf("Expected: bytes_read = %d, list: %v\nGot: bytes_read: %d, list: %v",
 					test.bytes_read, test.list, bytes_read, list,
@@ -133,7 +150,9 @@ func TestReadRDBSet(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			bytes_read, set := readRDBSet(test.bytes)
+			d := newDecoder(bytes.NewReader(test.bytes))
+			set := d.readRDBSet()
+			bytes_read := d.offset
 			if bytes_read != test.bytes_read || !equalSets(set, test.set) {
 				t.Errorf("Expected: bytes_read = %d, set: %v\nGot: bytes_read: %d, set: %v",
 					test.bytes_read, test.set, bytes_read, set,
@@ -142,3 +161,546 @@ func TestReadRDBSet(t *testing.T) {
 		})
 	}
 }
+
+func TestLZFDecompress(t *testing.T) {
+	tests := []struct {
+		name       string
+		compressed []byte
+		expected   string
+		valid      bool
+	}{
+		{name: "literal", compressed: []byte{0x02, 'a', 'b', 'c'}, expected: "abc", valid: true},
+		{name: "back reference", compressed: []byte{0x02, 'a', 'b', 'c', 0x80, 0x02}, expected: "abcabcabc", valid: true},
+		{name: "overlapping reference", compressed: []byte{0x00, 'a', 0xE0, 0x0A, 0x00}, expected: strings.Repeat("a", 20), valid: true},
+		{name: "reference before start", compressed: []byte{0x00, 'a', 0x20, 0x05}, valid: false},
+		{name: "truncated literal", compressed: []byte{0x05, 'a'}, valid: false},
+		{name: "wrong length", compressed: []byte{0x01, 'a', 'b'}, expected: "abc", valid: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			length := len(test.expected)
+			if length == 0 {
+				length = 10
+			}
+			got, err := lzfDecompress(test.compressed, length)
+			if (err == nil) != test.valid || (test.valid && string(got) != test.expected) {
+				t.Errorf("Expected: %q, valid: %v\nGot: %q, %v", test.expected, test.valid, got, err)
+			}
+		})
+	}
+}
+
+// ziplist wraps ziplist entries, each starting with the length of the
+// previous one, into a ziplist.
+func ziplist(count int, entries ...byte) []byte {
+	data := make([]byte, 10, 11+len(entries))
+	data = append(append(data, entries...), ziplistEnd)
+	binary.LittleEndian.PutUint32(data, uint32(len(data)))
+	binary.LittleEndian.PutUint16(data[8:], uint16(count))
+	return data
+}
+
+func TestDecodeZiplist(t *testing.T) {
+	data := ziplist(7,
+		0x00, 0x01, 'a',
+		0x03, 0xF6,
+		0x02, 0xFE, 0xFE,
+		0x03, 0xC0, 0x2C, 0x01,
+		0x04, 0xF0, 0x70, 0x11, 0x01,
+		0x05, 0xD0, 0x60, 0x79, 0xFE, 0xFF,
+		0x06, 0xE0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00,
+	)
+	expected := []string{"a", "5", "-2", "300", "70000", "-100000", "1099511627776"}
+
+	elements, err := decodeZiplist(data)
+	if err != nil || !equalSlices(elements, expected) {
+		t.Errorf("Expected: %v\nGot: %v, %v", expected, elements, err)
+	}
+
+	if _, err := decodeZiplist(data[:len(data)-3]); err == nil {
+		t.Errorf("Expected an error for a truncated ziplist")
+	}
+}
+
+func TestDecodeIntset(t *testing.T) {
+	data := []byte{0x04, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0x40, 0x42, 0x0F, 0x00}
+	expected := []string{"-1", "1000000"}
+
+	members, err := decodeIntset(data)
+	if err != nil || !equalSlices(members, expected) {
+		t.Errorf("Expected: %v\nGot: %v, %v", expected, members, err)
+	}
+
+	if _, err := decodeIntset(data[:12]); err == nil {
+		t.Errorf("Expected an error for a truncated intset")
+	}
+}
+
+func TestReadRDBQuicklist(t *testing.T) {
+	var buf bytes.Buffer
+	e := &encoder{w: &buf}
+	e.writeLength(2)
+	e.writeLength(quicklistContainer.PACKED)
+	e.writeString(string(encodeListpack([]string{"a", "1", "b"})))
+	e.writeLength(quicklistContainer.PLAIN)
+	e.writeString("plain")
+
+	d := newDecoder(bytes.NewReader(buf.Bytes()))
+	list := d.readRDBQuicklist(true)
+	expected := []string{"a", "1", "b", "plain"}
+	if d.err != nil || d.offset != uint64(buf.Len()) || !equalSlices(list, expected) {
+		t.Errorf("Expected: bytes_read = %d, list: %v\nGot: bytes_read = %d, list: %v, %v", buf.Len(), expected, d.offset, list, d.err)
+	}
+
+	buf.Reset()
+	e.writeLength(1)
+	e.writeString(string(ziplist(2, 0x00, 0x01, 'x', 0x03, 0xF2)))
+	d = newDecoder(bytes.NewReader(buf.Bytes()))
+	list = d.readRDBQuicklist(false)
+	if d.err != nil || !equalSlices(list, []string{"x", "1"}) {
+		t.Errorf("Expected: [x 1]\nGot: %v, %v", list, d.err)
+	}
+}
+
+func TestDecodeCompactValue(t *testing.T) {
+	value, err := decodeCompactValue(rdbValueTypes.HASH_LISTPACK, encodeListpack([]string{"field", "value", "n", "12"}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	hash := value.(*core.Hash)
+	if got, _ := hash.Get("n"); hash.Len() != 2 || got != "12" {
+		t.Errorf("Expected: {field: value, n: 12}\nGot: %v", hash)
+	}
+
+	value, err = decodeCompactValue(rdbValueTypes.SORTED_SET_ZIPLIST, ziplist(4, 0x00, 0x01, 'a', 0x03, 0xF3, 0x02, 0x01, 'b', 0x03, 0x03, '1', '.', '5'))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	entries := value.(*core.SortedSet).Entries()
+	if len(entries) != 2 || entries[0] != (core.ZEntry{Member: "b", Score: 1.5}) || entries[1] != (core.ZEntry{Member: "a", Score: 2}) {
+		t.Errorf("Expected: [{b 1.5} {a 2}]\nGot: %v", entries)
+	}
+
+	value, err = decodeCompactValue(rdbValueTypes.SET_LISTPACK, encodeListpack([]string{"x", "y", "z"}))
+	if err != nil || value.(*core.Set).Len() != 3 {
+		t.Errorf("Expected a set of 3 members\nGot: %v, %v", value, err)
+	}
+
+	if _, err := decodeCompactValue(rdbValueTypes.HASH_LISTPACK, encodeListpack([]string{"field"})); err == nil {
+		t.Errorf("Expected an error for a hash with a field and no value")
+	}
+}
+
+func TestReadRDBSortedSetStringScores(t *testing.T) {
+	data := []byte{
+		0x03,
+		0x01, 'a', 0x03, '1', '.', '5',
+		0x01, 'b', 0xFE,
+		0x01, 'c', 0xFF,
+	}
+	d := newDecoder(bytes.NewReader(data))
+	sorted_set := d.readRDBSortedSet(false)
+	if d.err != nil || d.offset != uint64(len(data)) {
+		t.Fatalf("Expected: bytes_read = %d\nGot: bytes_read = %d, %v", len(data), d.offset, d.err)
+	}
+	entries := sorted_set.Entries()
+	want := []core.ZEntry{{Member: "c", Score: math.Inf(-1)}, {Member: "a", Score: 1.5}, {Member: "b", Score: math.Inf(1)}}
+	if len(entries) != len(want) || entries[0] != want[0] || entries[1] != want[1] || entries[2] != want[2] {
+		t.Errorf("Expected: %v\nGot: %v", want, entries)
+	}
+
+	d = newDecoder(bytes.NewReader([]byte{0x01, 0x01, 'a', 0xFD}))
+	if d.readRDBSortedSet(false); d.err == nil {
+		t.Errorf("Expected an error for a NaN score")
+	}
+}
+
+func TestDecodeZipmap(t *testing.T) {
+	data := []byte{0x02, 0x03, 'f', 'o', 'o', 0x03, 0x02, 'b', 'a', 'r', 0x00, 0x00, 0x01, 'n', 0x01, 0x00, '7', 0xFF}
+	expected := []string{"foo", "bar", "n", "7"}
+
+	elements, err := decodeZipmap(data)
+	if err != nil || !equalSlices(elements, expected) {
+		t.Errorf("Expected: %v\nGot: %v, %v", expected, elements, err)
+	}
+
+	if _, err := decodeZipmap(data[:len(data)-3]); err == nil {
+		t.Errorf("Expected an error for a truncated zipmap")
+	}
+}
+
+func TestReadHashListpackEx(t *testing.T) {
+	var buf bytes.Buffer
+	e := &encoder{w: &buf}
+	e.writeString("hash")
+	e.writeMillis(1700000000000)
+	e.writeString(string(encodeListpack([]string{"temporary", "a", "1700000000000", "field", "b", "0"})))
+
+	d := newDecoder(bytes.NewReader(buf.Bytes()))
+	key, value := d.readKeyValue(rdbValueTypes.HASH_LISTPACK_EX)
+	if d.err != nil || d.offset != uint64(buf.Len()) || key != "hash" {
+		t.Fatalf("Expected: bytes_read = %d, key = hash\nGot: bytes_read = %d, key = %s, %v", buf.Len(), d.offset, key, d.err)
+	}
+	hash := value.(*core.Hash)
+	if expiry, ok := hash.FieldExpiry("temporary"); !ok || expiry != 1700000000000 {
+		t.Errorf("Expected temporary to expire at 1700000000000\nGot: %d, %t", expiry, ok)
+	}
+	if _, ok := hash.FieldExpiry("field"); ok {
+		t.Errorf("Expected field to have no expiry")
+	}
+	if got, _ := hash.Get("field"); got != "b" {
+		t.Errorf("Expected: b\nGot: %s", got)
+	}
+}
+
+func TestSkipModuleAux(t *testing.T) {
+	var buf bytes.Buffer
+	e := &encoder{w: &buf}
+	e.writeLength(0x1234567890ABCDEF)
+	e.writeLength(moduleOpcodeUInt)
+	e.writeLength(2)
+	e.writeLength(moduleOpcodeString)
+	e.writeString("state")
+	e.writeLength(moduleOpcodeDouble)
+	e.write(make([]byte, 8))
+	e.writeLength(moduleOpcodeFloat)
+	e.write(make([]byte, 4))
+	e.writeLength(moduleOpcodeSInt)
+	e.writeLength(42)
+	e.writeLength(moduleOpcodeEOF)
+	data := append(buf.Bytes(), opCodes.EOF)
+
+	d := newDecoder(bytes.NewReader(data))
+	name := d.skipModuleAux()
+	if d.err != nil || d.offset != uint64(len(data)-1) {
+		t.Errorf("Expected: bytes_read = %d\nGot: bytes_read = %d, %v", len(data)-1, d.offset, d.err)
+	}
+	// The name is held by the top 54 bits of the module id.
+	if name != "EjRWeJCrz" {
+		t.Errorf("Expected: EjRWeJCrz\nGot: %s", name)
+	}
+
+	// Skipped parts are reported to the scanner rather than logged.
+	var file bytes.Buffer
+	e = &encoder{w: &file}
+	e.write([]byte("REDIS0012"))
+	e.writeByte(opCodes.MODULE_AUX)
+	e.write(data[:len(data)-1])
+	e.writeByte(opCodes.FUNCTION2)
+	e.writeString("#!lua name=lib")
+	e.writeByte(opCodes.EOF)
+	e.write(make([]byte, 8))
+
+	var skipped []string
+	scanner := Scanner{
+		Skipped: func(reason string) { skipped = append(skipped, reason) },
+		Key:     func(entry Entry) error { return nil },
+	}
+	if err := scanner.Scan(bytes.NewReader(file.Bytes())); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{
+		"the auxiliary data of module EjRWeJCrz, modules are not supported",
+		"a function library, functions are not supported",
+	}
+	if !equalSlices(skipped, expected) {
+		t.Errorf("Expected: %v\nGot: %v", expected, skipped)
+	}
+}
+
+// testFile returns an RDB file holding a value of every type written.
+func testFile() []byte {
+	store := new(core.Store)
+	store.Init()
+	store.Set("string", resp.BulkString("hello"))
+	store.SetWithAbsoluteExpiry("counter", core.Int(42), 1<<45)
+	store.Set("list", core.NewList("a", "b"))
+	store.Set("set", core.NewSet("x", "y"))
+	hash := core.NewHash()
+	hash.Set("field", "value")
+	hash.SetFieldExpiry("field", 1<<45)
+	store.Set("hash", hash)
+	sorted_set := core.NewSortedSet()
+	sorted_set.Add("member", 1.5)
+	store.Set("zset", sorted_set)
+	stream := &resp.Stream{}
+	stream.AddEntry("1-1", map[string]resp.Object{"a": resp.BulkString("1")})
+	stream.Groups = []*resp.StreamGroup{{
+		Name:      "group",
+		LastID:    "1-1",
+		Pending:   []resp.StreamPendingEntry{{Id: "1-1", Consumer: "consumer", DeliveryCount: 1}},
+		Consumers: []resp.StreamConsumer{{Name: "consumer"}},
+	}}
+	store.Set("stream", stream)
+	store.DB(3).Set("other", resp.BulkString("db3"))
+	return GenerateFile(store)
+}
+
+func loadData(data []byte, params map[string]string) (*core.Store, error) {
+	store := new(core.Store)
+	store.Init()
+	for key, value := range params {
+		store.SetParam(key, value)
+	}
+	return store, Read(bytes.NewReader(data), store)
+}
+
+func TestLoadTruncated(t *testing.T) {
+	data := testFile()
+	if _, err := loadData(data, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for length := 0; length < len(data); length++ {
+		_, err := loadData(data[:length], nil)
+		var format_err *FormatError
+		if !errors.As(err, &format_err) || !errors.Is(err, ErrTruncated) {
+			t.Fatalf("Expected a truncated file error for the first %d bytes\nGot: %v", length, err)
+		}
+		if format_err.Offset != uint64(length) {
+			t.Errorf("Expected the error at offset %d\nGot: %d", length, format_err.Offset)
+		}
+	}
+}
+
+func TestLoadChecksum(t *testing.T) {
+	data := testFile()
+	corrupted := bytes.Clone(data)
+	corrupted[len(corrupted)-1] ^= 0xFF
+
+	if _, err := loadData(corrupted, nil); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Expected: %v\nGot: %v", ErrChecksum, err)
+	}
+	if _, err := loadData(corrupted, map[string]string{"rdbchecksum": "no"}); err != nil {
+		t.Errorf("Expected the checksum to be ignored\nGot: %v", err)
+	}
+
+	// A checksum of 0 marks files written without one.
+	binary.LittleEndian.PutUint64(corrupted[len(corrupted)-8:], 0)
+	if _, err := loadData(corrupted, nil); err != nil {
+		t.Errorf("Expected a file without checksum to load\nGot: %v", err)
+	}
+
+	// Corrupted data is detected by the checksum even when it still parses.
+	corrupted = bytes.Clone(data)
+	index := bytes.Index(corrupted, []byte("hello"))
+	corrupted[index] = 'j'
+	if _, err := loadData(corrupted, nil); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Expected: %v\nGot: %v", ErrChecksum, err)
+	}
+}
+
+func TestLoadVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		want_err  error
+		malformed bool
+	}{
+		{name: "current", header: "REDIS0012"},
+		{name: "without checksum", header: "REDIS0004"},
+		{name: "later version", header: "REDIS0013", want_err: ErrUnsupportedVersion},
+		{name: "invalid version", header: "REDIS00x1", malformed: true},
+		{name: "unknown header", header: "RDB000012", malformed: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := append([]byte(test.header), opCodes.SELECTDB, 0x00, rdbValueTypes.STRING, 0x01, 'k', 0x01, 'v', opCodes.EOF)
+			if test.header != "REDIS0004" {
+				data = append(data, make([]byte, 8)...)
+			}
+			store, err := loadData(data, nil)
+			switch {
+			case test.malformed:
+				var format_err *FormatError
+				if !errors.As(err, &format_err) {
+					t.Errorf("Expected a format error\nGot: %v", err)
+				}
+			case test.want_err != nil:
+				if !errors.Is(err, test.want_err) {
+					t.Errorf("Expected: %v\nGot: %v", test.want_err, err)
+				}
+			case err != nil:
+				t.Errorf("Unexpected error: %v", err)
+			default:
+				if value, _ := store.Get("k"); value != resp.BulkString("v") {
+					t.Errorf("Expected: v\nGot: %v", value)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadMalformed(t *testing.T) {
+	header := []byte("REDIS0012")
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "unknown opcode", data: append(header, 0xF0)},
+		{name: "module value", data: append(header, rdbValueTypes.MODULE_2, 0x01, 'k')},
+		{name: "database out of range", data: append(header, opCodes.SELECTDB, 0x40, 0xFF)},
+		{name: "length as string", data: append(header, rdbValueTypes.LIST, 0x01, 'k', 0xC3)},
+		{name: "nan score", data: append(header, rdbValueTypes.SORTED_SET, 0x01, 'k', 0x01, 0x01, 'm', 0xFD)},
+		{name: "bad listpack", data: append(header, rdbValueTypes.HASH_LISTPACK, 0x01, 'k', 0x02, 0x00, 0x00)},
+		{name: "huge string", data: append(header, rdbValueTypes.STRING, 0x01, 'k', 0x81, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)},
+		{name: "huge list", data: append(header, rdbValueTypes.LIST, 0x01, 'k', 0x81, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := loadData(test.data, nil)
+			var format_err *FormatError
+			if !errors.As(err, &format_err) {
+				t.Errorf("Expected a format error\nGot: %v", err)
+			}
+		})
+	}
+}
+
+func FuzzLoad(f *testing.F) {
+	f.Add(testFile())
+	f.Add([]byte("REDIS0012\xff\x00\x00\x00\x00\x00\x00\x00\x00"))
+	f.Add([]byte("REDIS0003\xfe\x00\x00\x01k\x01v\xff"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Checksums would reject almost every input before the end.
+		_, err := loadData(data, map[string]string{"rdbchecksum": "no"})
+		var format_err *FormatError
+		if err != nil && !errors.As(err, &format_err) {
+			t.Errorf("Expected a format error\nGot: %v", err)
+		}
+	})
+}
+
+func FuzzDecodeCompactValue(f *testing.F) {
+	f.Add(rdbValueTypes.HASH_LISTPACK, encodeListpack([]string{"field", "value"}))
+	f.Add(rdbValueTypes.ZIPLIST, ziplist(2, 0x00, 0x01, 'x', 0x03, 0xF2))
+	f.Add(rdbValueTypes.INTSET, []byte{0x02, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x07, 0x00})
+	f.Add(rdbValueTypes.ZIPMAP, []byte{0x01, 0x01, 'f', 0x01, 0x00, 'v', 0xFF})
+	f.Add(rdbValueTypes.HASH_LISTPACK_EX, encodeListpack([]string{"field", "value", "0"}))
+
+	f.Fuzz(func(t *testing.T, data_type byte, encoded []byte) {
+		// Only the compact types are stored as a single string.
+		switch data_type {
+		case rdbValueTypes.ZIPMAP, rdbValueTypes.ZIPLIST, rdbValueTypes.INTSET, rdbValueTypes.SORTED_SET_ZIPLIST,
+			rdbValueTypes.HASHMAP_ZIPLIST, rdbValueTypes.HASH_LISTPACK, rdbValueTypes.SORTED_SET_LISTPACK,
+			rdbValueTypes.SET_LISTPACK, rdbValueTypes.HASH_LISTPACK_EX_PRE_GA, rdbValueTypes.HASH_LISTPACK_EX:
+			decodeCompactValue(data_type, encoded)
+		}
+	})
+}
+
+func FuzzReadStreamNode(f *testing.F) {
+	// The entries of a node, as the space separated elements of its
+	// listpack: the master entry, then entries with their own fields.
+	f.Add("1 0 1 f 0 0 0 0 1 g v 4")
+	f.Add("1 0 1 f 0 2 0 0 v 3")
+	f.Add("1 0 1 f 0 0 0 0 4611686018427387904 0")
+	f.Add("1 0 1 f 0 0 0 0 9223372036854775807 0")
+
+	f.Fuzz(func(t *testing.T, node string) {
+		readStreamNode(&resp.Stream{}, streamID{}, strings.Split(node, " "))
+	})
+}
+
+func TestReadStreamNodeOverflow(t *testing.T) {
+	elements := strings.Split("1 0 1 f 0 0 0 0 4611686018427387904 0", " ")
+	if err := readStreamNode(&resp.Stream{}, streamID{}, elements); err == nil {
+		t.Errorf("Expected a malformed node error for a huge field count")
+	}
+}
+
+func TestReadStreaming(t *testing.T) {
+	data := testFile()
+
+	// Values are decoded across reads returning a single byte.
+	store := new(core.Store)
+	store.Init()
+	if err := Read(iotest.OneByteReader(bytes.NewReader(data)), store); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if value, _ := store.Get("string"); value != resp.BulkString("hello") {
+		t.Errorf("Expected: hello\nGot: %v", value)
+	}
+
+	// Errors of the reader are returned as they are, not as malformed data.
+	r := io.MultiReader(bytes.NewReader(data[:20]), iotest.ErrReader(iotest.ErrTimeout))
+	err := Read(r, store)
+	var format_err *FormatError
+	if !errors.Is(err, iotest.ErrTimeout) || errors.As(err, &format_err) {
+		t.Errorf("Expected: %v\nGot: %v", iotest.ErrTimeout, err)
+	}
+}
+
+func TestReadLongString(t *testing.T) {
+	store := new(core.Store)
+	store.Init()
+	value := strings.Repeat("abcdefgh", 3*readChunkSize/8+5)
+	store.Set("long", resp.BulkString(value))
+
+	loaded := new(core.Store)
+	loaded.Init()
+	if err := Read(bytes.NewReader(GenerateFile(store)), loaded); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got, _ := loaded.Get("long"); got != resp.BulkString(value) {
+		t.Errorf("Expected a string of %d bytes to round trip", len(value))
+	}
+}
+
+func TestScan(t *testing.T) {
+	data := testFile()
+	entries := make(map[string]Entry)
+	aux := make(map[string]string)
+	scanner := Scanner{
+		VerifyChecksum: true,
+		Aux:            func(key string, value string) { aux[key] = value },
+		Key: func(entry Entry) error {
+			entries[entry.Key] = entry
+			return nil
+		},
+	}
+	if err := scanner.Scan(bytes.NewReader(data)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if scanner.Version != rdbVersion || aux["redis-ver"] == "" {
+		t.Errorf("Expected: version %d and the redis-ver field\nGot: version %d, %v", rdbVersion, scanner.Version, aux)
+	}
+
+	if len(entries) != 8 {
+		t.Fatalf("Expected: 8 keys\nGot: %d", len(entries))
+	}
+	var size uint64
+	for _, entry := range entries {
+		if entry.Size == 0 {
+			t.Errorf("Expected a size for %q", entry.Key)
+		}
+		size += entry.Size
+	}
+	if size >= uint64(len(data)) {
+		t.Errorf("Expected the keys to take less than the %d bytes of the file\nGot: %d", len(data), size)
+	}
+	if counter := entries["counter"]; !counter.HasExpiry || counter.Expiry != 1<<45 || counter.Value != resp.BulkString("42") {
+		t.Errorf("Expected: counter = 42 expiring at %d\nGot: %+v", int64(1<<45), counter)
+	}
+	if str := entries["string"]; str.HasExpiry || str.Size != uint64(len("\x00\x06string\x05hello")) {
+		t.Errorf("Expected: string without expiry, taking 14 bytes\nGot: %+v", str)
+	}
+	if other := entries["other"]; other.DB != 3 {
+		t.Errorf("Expected: other in db 3\nGot: db %d", other.DB)
+	}
+
+	// Errors returned by Key stop the scan as they are.
+	stop := errors.New("stop")
+	keys := 0
+	scanner = Scanner{Key: func(entry Entry) error {
+		keys++
+		return stop
+	}}
+	if err := scanner.Scan(bytes.NewReader(data)); err != stop || keys != 1 {
+		t.Errorf("Expected: %v after 1 key\nGot: %v after %d keys", stop, err, keys)
+	}
+}
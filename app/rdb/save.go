@@ -0,0 +1,198 @@
+package rdb
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+)
+
+var ErrSaveInProgress = errors.New("ERR Background save already in progress")
+
+// SavePoint triggers a background save once Changes writes were made and
+// Seconds have passed since the last save.
+type SavePoint struct {
+	Seconds int64
+	Changes int64
+}
+
+// savePointsCheckInterval is how often the save points are checked.
+const savePointsCheckInterval = 100 * time.Millisecond
+
+// saveRetryDelay is how long to wait before retrying a failed save.
+const saveRetryDelay = 5 * time.Second
+
+// ParseSavePoints parses save points in the form of the save configuration
+// directive, "<seconds> <changes>" pairs separated by spaces. An empty
+// string disables automatic saves.
+func ParseSavePoints(config string) ([]SavePoint, error) {
+	fields := strings.Fields(config)
+	if len(fields)%2 != 0 {
+		return nil, fmt.Errorf("invalid save points %q", config)
+	}
+
+	points := make([]SavePoint, 0, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		seconds, err_seconds := strconv.ParseInt(fields[i], 10, 64)
+		changes, err_changes := strconv.ParseInt(fields[i+1], 10, 64)
+		if err_seconds != nil || err_changes != nil || seconds < 1 || changes < 0 {
+			return nil, fmt.Errorf("invalid save points %q", config)
+		}
+		points = append(points, SavePoint{Seconds: seconds, Changes: changes})
+	}
+	return points, nil
+}
+
+// Filename returns the path of the RDB file of store, from its dir and
+// dbfilename parameters.
+func Filename(store *core.Store) string {
+	dir, _ := store.GetParam("dir")
+	dbfilename, _ := store.GetParam("dbfilename")
+	return filepath.Join(dir, dbfilename)
+}
+
+// SaveFile writes store to filename. The snapshot is written to a temporary
+// file in the same directory, which then replaces filename, so that a
+// failed save never leaves a partial file behind.
+func SaveFile(filename string, store *core.Store) error {
+	file, err := os.CreateTemp(filepath.Dir(filename), "temp-*.rdb")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary RDB file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	w := bufio.NewWriter(file)
+	err = Write(w, store)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if close_err := file.Close(); err == nil {
+		err = close_err
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write RDB file: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), filename); err != nil {
+		return fmt.Errorf("unable to replace RDB file: %w", err)
+	}
+	return nil
+}
+
+// WriteBulk writes store to w as a bulk string without its trailing CRLF,
+// the way a master sends its snapshot to a replica. The length comes first,
+// so the snapshot is written to a temporary file in the directory of the RDB
+// file, then copied to w, as Redis does when replication is not diskless.
+func WriteBulk(w io.Writer, store *core.Store) error {
+	file, err := os.CreateTemp(filepath.Dir(Filename(store)), "temp-sync-*.rdb")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary RDB file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	buffered := bufio.NewWriter(file)
+	err = Write(buffered, store)
+	if err == nil {
+		err = buffered.Flush()
+	}
+	var size int64
+	if err == nil {
+		size, err = file.Seek(0, io.SeekCurrent)
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write RDB file: %w", err)
+	}
+
+	if _, err := fmt.Fprintf(w, "$%d\r\n", size); err != nil {
+		return err
+	}
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// Save writes store to its RDB file, blocking until done. The caller must
+// hold the store lock.
+func Save(store *core.Store) error {
+	stats := store.SaveStats()
+	if stats.InProgress {
+		return ErrSaveInProgress
+	}
+
+	err := SaveFile(Filename(store), store)
+	store.RecordSave(stats.Dirty, err)
+	return err
+}
+
+// BackgroundSave writes a snapshot of store to its RDB file on a goroutine,
+// so that clients can keep using the store meanwhile. The caller must hold
+// the store lock, under which the snapshot is taken.
+func BackgroundSave(store *core.Store) error {
+	dirty, ok := store.StartBackgroundSave()
+	if !ok {
+		return ErrSaveInProgress
+	}
+
+	snapshot := store.Snapshot()
+	filename := Filename(store)
+	go func() {
+		err := SaveFile(filename, snapshot)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Background saving failed: %v\n", err)
+		}
+		store.FinishBackgroundSave(dirty, err)
+	}()
+	return nil
+}
+
+// RunSavePoints starts a background save whenever one of the save points is
+// reached or a save was scheduled. Failed saves are retried after
+// saveRetryDelay.
+func RunSavePoints(store *core.Store, points []SavePoint, stop <-chan struct{}) {
+	ticker := time.NewTicker(savePointsCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			store.Lock()
+			if shouldSave(store.SaveStats(), points, time.Now()) {
+				BackgroundSave(store)
+			}
+			store.Unlock()
+		}
+	}
+}
+
+func shouldSave(stats core.SaveStats, points []SavePoint, now time.Time) bool {
+	if stats.InProgress {
+		return false
+	}
+	if stats.Scheduled {
+		return true
+	}
+	if stats.LastError != nil && now.Sub(stats.LastAttempt) < saveRetryDelay {
+		return false
+	}
+	for _, point := range points {
+		if stats.Dirty >= point.Changes && stats.Dirty > 0 && now.Sub(stats.LastSave) >= time.Duration(point.Seconds)*time.Second {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package rdb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+func TestParseSavePoints(t *testing.T) {
+	points, err := ParseSavePoints("3600 1 300 100")
+	expected := []SavePoint{{3600, 1}, {300, 100}}
+	if err != nil || len(points) != 2 || points[0] != expected[0] || points[1] != expected[1] {
+		t.Errorf("Expected: %v\nGot: %v, %v", expected, points, err)
+	}
+
+	if points, err := ParseSavePoints(""); err != nil || len(points) != 0 {
+		t.Errorf("Expected no save points\nGot: %v, %v", points, err)
+	}
+	for _, config := range []string{"3600", "a 1", "0 1", "60 -1"} {
+		if _, err := ParseSavePoints(config); err == nil {
+			t.Errorf("Expected an error for %q", config)
+		}
+	}
+}
+
+func TestShouldSave(t *testing.T) {
+	now := time.Now()
+	points := []SavePoint{{60, 10}, {300, 1}}
+	tests := []struct {
+		name     string
+		stats    core.SaveStats
+		expected bool
+	}{
+		{"clean", core.SaveStats{LastSave: now.Add(-time.Hour)}, false},
+		{"too few changes", core.SaveStats{Dirty: 5, LastSave: now.Add(-2 * time.Minute)}, false},
+		{"enough changes", core.SaveStats{Dirty: 10, LastSave: now.Add(-2 * time.Minute)}, true},
+		{"long enough", core.SaveStats{Dirty: 1, LastSave: now.Add(-6 * time.Minute)}, true},
+		{"in progress", core.SaveStats{Dirty: 10, LastSave: now.Add(-time.Hour), InProgress: true}, false},
+		{"scheduled", core.SaveStats{Scheduled: true, LastSave: now}, true},
+		{"recent failure", core.SaveStats{Dirty: 10, LastSave: now.Add(-time.Hour), LastAttempt: now, LastError: errors.New("failed")}, false},
+		{"old failure", core.SaveStats{Dirty: 10, LastSave: now.Add(-time.Hour), LastAttempt: now.Add(-time.Minute), LastError: errors.New("failed")}, true},
+	}
+
+	for _, test := range tests {
+		if got := shouldSave(test.stats, points, now); got != test.expected {
+			t.Errorf("%s\nExpected: %v\nGot: %v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestBackgroundSave(t *testing.T) {
+	dir := t.TempDir()
+	store := new(core.Store)
+	store.Init()
+	store.SetParam("dir", dir)
+	store.SetParam("dbfilename", "dump.rdb")
+	store.Set("key", resp.BulkString("before"))
+
+	store.Lock()
+	if err := BackgroundSave(store); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	store.Set("key", resp.BulkString("after"))
+	store.Unlock()
+
+	for store.SaveStats().InProgress {
+		time.Sleep(time.Millisecond)
+	}
+	if err := store.SaveStats().LastError; err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	loaded := new(core.Store)
+	loaded.Init()
+	if err := ReadFile(filepath.Join(dir, "dump.rdb"), loaded); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if value, _ := loaded.Get("key"); value != resp.BulkString("before") {
+		t.Errorf("Expected: before\nGot: %v", value)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only dump.rdb to be left, got %d files", len(entries))
+	}
+}
+
+func TestWriteBulk(t *testing.T) {
+	store := new(core.Store)
+	store.Init()
+	store.SetParam("dir", t.TempDir())
+	store.SetParam("dbfilename", "dump.rdb")
+	store.Set("key", resp.BulkString("value"))
+
+	var buf bytes.Buffer
+	if err := WriteBulk(&buf, store); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	header, data, _ := strings.Cut(buf.String(), "\r\n")
+	if header != "$"+strconv.Itoa(len(data)) {
+		t.Errorf("Expected: $%d\nGot: %s", len(data), header)
+	}
+
+	// The snapshot is followed by the commands propagated to the replica,
+	// which must be left unread.
+	r := strings.NewReader(data + "*1\r\n$4\r\nPING\r\n")
+	loaded := new(core.Store)
+	loaded.Init()
+	if err := Read(io.LimitReader(r, int64(len(data))), loaded); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if value, _ := loaded.Get("key"); value != resp.BulkString("value") {
+		t.Errorf("Expected: value\nGot: %v", value)
+	}
+	if rest, _ := io.ReadAll(r); string(rest) != "*1\r\n$4\r\nPING\r\n" {
+		t.Errorf("Expected the commands to be left unread\nGot: %q", rest)
+	}
+}
@@ -0,0 +1,508 @@
+package rdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// rdbVersion is the version of the files written, that of Redis 7.4, which
+// introduced hash field expiries.
+const rdbVersion = 12
+
+// streamNodeEntries is the number of entries stored per listpack node of a
+// stream, Redis' stream-node-max-entries default.
+const streamNodeEntries = 100
+
+// crcTable computes the CRC-64/Jones checksum ending RDB files. The table is
+// built from the polynomial in reversed bit order, as hash/crc64 expects.
+var crcTable = crc64.MakeTable(0x95ac9329ac4bc9b5)
+
+// updateChecksum extends an RDB checksum with data. hash/crc64 inverts the
+// checksum before and after each update, which Redis does not do, so the
+// inversions are undone here.
+func updateChecksum(crc uint64, data []byte) uint64 {
+	return ^crc64.Update(^crc, crcTable, data)
+}
+
+// Write serializes every database of store to w in the RDB format.
+func Write(w io.Writer, store *core.Store) error {
+	return write(w, store, false)
+}
+
+// WriteAOFBase serializes store like Write, as the base file of an append
+// only file, which the aof-base field marks.
+func WriteAOFBase(w io.Writer, store *core.Store) error {
+	return write(w, store, true)
+}
+
+func write(w io.Writer, store *core.Store, aof_base bool) error {
+	e := &encoder{w: w}
+	e.write([]byte(fmt.Sprintf("REDIS%04d", rdbVersion)))
+	e.writeAux("redis-ver", "7.4.0")
+	e.writeAux("redis-bits", "64")
+	e.writeAux("ctime", strconv.FormatInt(time.Now().Unix(), 10))
+	if aof_base {
+		e.writeAux("aof-base", "1")
+	} else {
+		e.writeAux("aof-base", "0")
+	}
+
+	for i := 0; i < store.Databases(); i++ {
+		entries, expires := liveEntries(store.DB(i))
+		if len(entries) == 0 {
+			continue
+		}
+		e.writeByte(opCodes.SELECTDB)
+		e.writeLength(uint64(i))
+		e.writeByte(opCodes.RESIZEDB)
+		e.writeLength(uint64(len(entries)))
+		e.writeLength(uint64(expires))
+
+		for _, entry := range entries {
+			if entry.expiry != 0 {
+				e.writeByte(opCodes.EXPIRETIMEMS)
+				e.writeMillis(entry.expiry)
+			}
+			e.writeKeyValue(entry.key, entry.value)
+		}
+	}
+
+	e.writeByte(opCodes.EOF)
+	checksum := e.crc
+	if !ChecksumEnabled(store) {
+		// A checksum of 0 tells readers not to verify it.
+		checksum = 0
+	}
+	e.write(binary.LittleEndian.AppendUint64(nil, checksum))
+	return e.err
+}
+
+type dbEntry struct {
+	key    string
+	value  resp.Object
+	expiry int64
+}
+
+// liveEntries returns the keys of db that have not expired, along with the
+// number of them with an expiry, so that the RESIZEDB hint matches what is
+// written. The expired fields of hashes are evicted first, and the hashes
+// left empty are dropped, as they would be on their next access.
+func liveEntries(db *core.Store) ([]dbEntry, int) {
+	var entries []dbEntry
+	db.Range(func(key string, value resp.Object, expiry int64) bool {
+		entries = append(entries, dbEntry{key, value, expiry})
+		return true
+	})
+
+	// Range holds the store lock, so hashes are only expired once it returns.
+	live := entries[:0]
+	expires := 0
+	for _, entry := range entries {
+		if hash, ok := entry.value.(*core.Hash); ok && !db.ExpireHashFields(entry.key, hash) {
+			continue
+		}
+		if entry.expiry != 0 {
+			expires++
+		}
+		live = append(live, entry)
+	}
+	return live, expires
+}
+
+// GenerateFile returns the RDB serialization of store.
+func GenerateFile(store *core.Store) []byte {
+	var buf bytes.Buffer
+	// Writing to a bytes.Buffer cannot fail.
+	Write(&buf, store)
+	return buf.Bytes()
+}
+
+// encoder writes RDB data while computing its checksum. The first error is
+// kept and stops further writes, so it only has to be checked at the end.
+type encoder struct {
+	w   io.Writer
+	crc uint64
+	err error
+}
+
+func (e *encoder) write(data []byte) {
+	if e.err != nil {
+		return
+	}
+	e.crc = updateChecksum(e.crc, data)
+	_, e.err = e.w.Write(data)
+}
+
+func (e *encoder) writeByte(b byte) {
+	e.write([]byte{b})
+}
+
+// writeLength writes a length in the 1, 2, 5 or 9 byte encoding, depending on
+// its size.
+func (e *encoder) writeLength(length uint64) {
+	switch {
+	case length < 1<<6:
+		e.writeByte(byte(length))
+	case length < 1<<14:
+		e.write([]byte{0x40 | byte(length>>8), byte(length)})
+	case length <= math.MaxUint32:
+		e.write(binary.BigEndian.AppendUint32([]byte{0x80}, uint32(length)))
+	default:
+		e.write(binary.BigEndian.AppendUint64([]byte{0x81}, length))
+	}
+}
+
+// writeString writes a string, as an integer if it is a short one in
+// canonical form, like Redis does.
+func (e *encoder) writeString(str string) {
+	if len(str) <= 11 {
+		if value, ok := canonicalInt(str); ok {
+			switch {
+			case value >= math.MinInt8 && value <= math.MaxInt8:
+				e.write([]byte{0xC0, byte(value)})
+				return
+			case value >= math.MinInt16 && value <= math.MaxInt16:
+				e.write(binary.LittleEndian.AppendUint16([]byte{0xC1}, uint16(value)))
+				return
+			case value >= math.MinInt32 && value <= math.MaxInt32:
+				e.write(binary.LittleEndian.AppendUint32([]byte{0xC2}, uint32(value)))
+				return
+			}
+		}
+	}
+	e.writeLength(uint64(len(str)))
+	e.write([]byte(str))
+}
+
+func (e *encoder) writeMillis(ms int64) {
+	e.write(binary.LittleEndian.AppendUint64(nil, uint64(ms)))
+}
+
+func (e *encoder) writeAux(key string, value string) {
+	e.writeByte(opCodes.AUX)
+	e.writeString(key)
+	e.writeString(value)
+}
+
+func (e *encoder) writeKeyValue(key string, value resp.Object) {
+	switch value := value.(type) {
+	case resp.BulkString:
+		e.writeByte(rdbValueTypes.STRING)
+		e.writeString(key)
+		e.writeString(string(value))
+	case resp.SimpleString:
+		e.writeByte(rdbValueTypes.STRING)
+		e.writeString(key)
+		e.writeString(string(value))
+	case core.Int:
+		e.writeByte(rdbValueTypes.STRING)
+		e.writeString(key)
+		e.writeString(value.String())
+	case *core.List:
+		e.writeByte(rdbValueTypes.LIST)
+		e.writeString(key)
+		e.writeStrings(value.Range(0, -1))
+	case *core.Set:
+		e.writeByte(rdbValueTypes.SET)
+		e.writeString(key)
+		e.writeStrings(value.Members())
+	case *core.Hash:
+		e.writeHash(key, value)
+	case *core.SortedSet:
+		e.writeByte(rdbValueTypes.SORTED_SET_2)
+		e.writeString(key)
+		entries := value.Entries()
+		e.writeLength(uint64(len(entries)))
+		// Redis writes the members from the highest score, so that loading
+		// them appends to the skiplist.
+		for i := len(entries) - 1; i >= 0; i-- {
+			e.writeString(entries[i].Member)
+			e.write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(entries[i].Score)))
+		}
+	case *resp.Stream:
+		e.writeStream(key, value)
+	default:
+		e.err = fmt.Errorf("cannot serialize value of key %q: unsupported type %T", key, value)
+	}
+}
+
+func (e *encoder) writeStrings(strs []string) {
+	e.writeLength(uint64(len(strs)))
+	for _, str := range strs {
+		e.writeString(str)
+	}
+}
+
+// writeHash writes a hash, with the expiries of its fields if it has any.
+// Field expiries are stored relative to the earliest one, plus one so that 0
+// can stand for fields without an expiry.
+func (e *encoder) writeHash(key string, hash *core.Hash) {
+	var fields, values []string
+	hash.Range(func(field string, value string) bool {
+		fields = append(fields, field)
+		values = append(values, value)
+		return true
+	})
+
+	var min_expiry int64
+	for _, field := range fields {
+		if expiry, ok := hash.FieldExpiry(field); ok && (min_expiry == 0 || expiry < min_expiry) {
+			min_expiry = expiry
+		}
+	}
+
+	if min_expiry == 0 {
+		e.writeByte(rdbValueTypes.HASH)
+		e.writeString(key)
+		e.writeLength(uint64(len(fields)))
+		for i := range fields {
+			e.writeString(fields[i])
+			e.writeString(values[i])
+		}
+		return
+	}
+
+	e.writeByte(rdbValueTypes.HASH_METADATA)
+	e.writeString(key)
+	e.writeMillis(min_expiry)
+	e.writeLength(uint64(len(fields)))
+	for i := range fields {
+		var ttl uint64
+		if expiry, ok := hash.FieldExpiry(fields[i]); ok {
+			ttl = uint64(expiry-min_expiry) + 1
+		}
+		e.writeLength(ttl)
+		e.writeString(fields[i])
+		e.writeString(values[i])
+	}
+}
+
+// writeStream writes a stream as listpack nodes of up to streamNodeEntries
+// entries, keyed by the id of their first entry, followed by the stream
+// metadata and its consumer groups.
+func (e *encoder) writeStream(key string, stream *resp.Stream) {
+	stream.Mu.Lock()
+	defer stream.Mu.Unlock()
+
+	ids := make([]streamID, len(stream.Entries))
+	for i, entry := range stream.Entries {
+		id, err := parseStreamID(entry.Id)
+		if err != nil {
+			e.err = fmt.Errorf("cannot serialize stream %q: %w", key, err)
+			return
+		}
+		ids[i] = id
+	}
+
+	var first, last, max_deleted streamID
+	if len(ids) > 0 {
+		first, last = ids[0], ids[len(ids)-1]
+	}
+	var err error
+	if stream.LastID != "" {
+		last, err = parseStreamID(stream.LastID)
+	}
+	if err == nil && stream.MaxDeletedID != "" {
+		max_deleted, err = parseStreamID(stream.MaxDeletedID)
+	}
+	if err != nil {
+		e.err = fmt.Errorf("cannot serialize stream %q: %w", key, err)
+		return
+	}
+
+	e.writeByte(rdbValueTypes.STREAM_LISTPACKS_3)
+	e.writeString(key)
+
+	nodes := (len(ids) + streamNodeEntries - 1) / streamNodeEntries
+	e.writeLength(uint64(nodes))
+	for start := 0; start < len(ids); start += streamNodeEntries {
+		end := min(start+streamNodeEntries, len(ids))
+		e.writeString(string(ids[start].bytes()))
+		e.writeString(string(encodeListpack(streamNodeElements(stream, ids, start, end))))
+	}
+
+	e.writeLength(uint64(len(ids)))
+	e.writeLength(last.ms)
+	e.writeLength(last.seq)
+	e.writeLength(first.ms)
+	e.writeLength(first.seq)
+	e.writeLength(max_deleted.ms)
+	e.writeLength(max_deleted.seq)
+	e.writeLength(max(stream.EntriesAdded, uint64(len(ids))))
+
+	e.writeLength(uint64(len(stream.Groups)))
+	for _, group := range stream.Groups {
+		if err := e.writeStreamGroup(group); err != nil {
+			e.err = fmt.Errorf("cannot serialize stream %q: %w", key, err)
+			return
+		}
+	}
+}
+
+// writeStreamGroup writes a consumer group: its last delivered id, the
+// number of entries it read, its pending entries sorted by id along with
+// their delivery details, and its consumers with the ids of the pending
+// entries delivered to each of them.
+func (e *encoder) writeStreamGroup(group *resp.StreamGroup) error {
+	last, err := parseStreamID(group.LastID)
+	if err != nil {
+		return err
+	}
+	pending := make([]streamID, len(group.Pending))
+	for i, entry := range group.Pending {
+		if pending[i], err = parseStreamID(entry.Id); err != nil {
+			return err
+		}
+	}
+	order := make([]int, len(pending))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return pending[order[i]].less(pending[order[j]]) })
+
+	e.writeString(group.Name)
+	e.writeLength(last.ms)
+	e.writeLength(last.seq)
+	// An unknown count is written as the largest length, as by Redis.
+	e.writeLength(uint64(group.EntriesRead))
+
+	e.writeLength(uint64(len(order)))
+	for _, i := range order {
+		e.write(pending[i].bytes())
+		e.writeMillis(group.Pending[i].DeliveryTime)
+		e.writeLength(group.Pending[i].DeliveryCount)
+	}
+
+	e.writeLength(uint64(len(group.Consumers)))
+	for _, consumer := range group.Consumers {
+		e.writeString(consumer.Name)
+		e.writeMillis(consumer.SeenTime)
+		e.writeMillis(consumer.ActiveTime)
+		owned := []int{}
+		for _, i := range order {
+			if group.Pending[i].Consumer == consumer.Name {
+				owned = append(owned, i)
+			}
+		}
+		e.writeLength(uint64(len(owned)))
+		for _, i := range owned {
+			e.write(pending[i].bytes())
+		}
+	}
+	return nil
+}
+
+// streamNodeElements returns the listpack elements of a stream node holding
+// the entries from start to end. The node starts with a master entry made of
+// the entry count, the deleted entry count and the fields of the first
+// entry. Entries with the same fields only store their values.
+func streamNodeElements(stream *resp.Stream, ids []streamID, start int, end int) []string {
+	master_id := ids[start]
+	master_fields := sortedFields(stream.Entries[start].Data)
+
+	elements := []string{strconv.Itoa(end - start), "0", strconv.Itoa(len(master_fields))}
+	elements = append(elements, master_fields...)
+	elements = append(elements, "0")
+
+	for i := start; i < end; i++ {
+		data := stream.Entries[i].Data
+		fields := sortedFields(data)
+		same_fields := equalStrings(fields, master_fields)
+
+		flags := streamItemFlagNone
+		if same_fields {
+			flags = streamItemFlagSameFields
+		}
+		elements = append(elements,
+			strconv.Itoa(flags),
+			// The sequence difference is negative for entries with a later
+			// millisecond time and a lower sequence number.
+			strconv.FormatInt(int64(ids[i].ms-master_id.ms), 10),
+			strconv.FormatInt(int64(ids[i].seq-master_id.seq), 10),
+		)
+
+		count := 3 + len(fields)
+		if !same_fields {
+			elements = append(elements, strconv.Itoa(len(fields)))
+			count += len(fields) + 1
+		}
+		for _, field := range fields {
+			value, _ := resp.ToString(data[field])
+			if same_fields {
+				elements = append(elements, value)
+			} else {
+				elements = append(elements, field, value)
+			}
+		}
+		elements = append(elements, strconv.Itoa(count))
+	}
+	return elements
+}
+
+func sortedFields(data map[string]resp.Object) []string {
+	fields := make([]string, 0, len(data))
+	for field := range data {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// The flags of the entries of a stream node.
+const (
+	streamItemFlagNone       = 0
+	streamItemFlagDeleted    = 1
+	streamItemFlagSameFields = 2
+)
+
+type streamID struct {
+	ms  uint64
+	seq uint64
+}
+
+func parseStreamID(id string) (streamID, error) {
+	ms, seq, ok := strings.Cut(id, "-")
+	parsed_ms, err_ms := strconv.ParseUint(ms, 10, 64)
+	parsed_seq, err_seq := strconv.ParseUint(seq, 10, 64)
+	if !ok || err_ms != nil || err_seq != nil {
+		return streamID{}, fmt.Errorf("invalid stream id %q", id)
+	}
+	return streamID{parsed_ms, parsed_seq}, nil
+}
+
+func (id streamID) less(other streamID) bool {
+	return id.ms < other.ms || (id.ms == other.ms && id.seq < other.seq)
+}
+
+func (id streamID) String() string {
+	return fmt.Sprintf("%d-%d", id.ms, id.seq)
+}
+
+// bytes returns the big endian encoding of the id, used as the key of the
+// stream nodes.
+func (id streamID) bytes() []byte {
+	return binary.BigEndian.AppendUint64(binary.BigEndian.AppendUint64(nil, id.ms), id.seq)
+}
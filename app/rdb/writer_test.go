@@ -0,0 +1,275 @@
+package rdb
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/core"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+func TestChecksum(t *testing.T) {
+	// The check value of CRC-64/Jones, as used by Redis' own tests.
+	var expected uint64 = 0xe9c6d914c4b8d9ca
+	if got := updateChecksum(0, []byte("123456789")); got != expected {
+		t.Errorf("Expected: %x\nGot: %x", expected, got)
+	}
+	if got := updateChecksum(updateChecksum(0, []byte("1234")), []byte("56789")); got != expected {
+		t.Errorf("Expected incremental updates to match: %x\nGot: %x", expected, got)
+	}
+}
+
+func TestListpackRoundTrip(t *testing.T) {
+	elements := []string{
+		"0", "127", "128", "-1", "-4096", "4095", "-32768", "32767", "8388607", "-8388608",
+		"2147483647", "-2147483648", "9223372036854775807", "-9223372036854775808",
+		"", "a", "007", "+1", strings.Repeat("x", 63), strings.Repeat("y", 200), strings.Repeat("z", 5000),
+	}
+
+	decoded, err := decodeListpack(encodeListpack(elements))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !equalSlices(decoded, elements) {
+		t.Errorf("Expected: %v\nGot: %v", elements, decoded)
+	}
+}
+
+func TestWriteThenReadFile(t *testing.T) {
+	store := new(core.Store)
+	store.Init()
+	future := time.Now().Add(time.Hour).UnixMilli()
+
+	store.Set("string", resp.BulkString("hello"))
+	store.Set("negative", resp.BulkString("-42"))
+	store.Set("counter", core.Int(1<<40))
+	store.SetWithAbsoluteExpiry("expiring", resp.BulkString("soon"), uint64(future))
+	store.Set("list", core.NewList("a", "b", "c"))
+	store.Set("set", core.NewSet("x", "y"))
+
+	hash := core.NewHash()
+	hash.Set("field", "value")
+	hash.Set("temporary", "value")
+	hash.SetFieldExpiry("temporary", future+123)
+	store.Set("hash", hash)
+
+	sorted_set := core.NewSortedSet()
+	sorted_set.Add("one", 1)
+	sorted_set.Add("pi", 3.14159)
+	sorted_set.Add("low", -2.5)
+	store.Set("zset", sorted_set)
+
+	stream := &resp.Stream{}
+	for i := 0; i < 150; i++ {
+		data := map[string]resp.Object{"n": resp.BulkString(strconv.Itoa(i))}
+		if i%7 == 0 {
+			data["extra"] = resp.BulkString("field")
+		}
+		// Sequence numbers going down make the stored differences negative.
+		stream.AddEntry(strconv.Itoa(1700000000000+i)+"-"+strconv.Itoa(150-i), data)
+	}
+	store.Set("stream", stream)
+
+	store.DB(5).Set("other", resp.BulkString("db5"))
+
+	filename := filepath.Join(t.TempDir(), "dump.rdb")
+	if err := os.WriteFile(filename, GenerateFile(store), 0o644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	loaded := new(core.Store)
+	loaded.Init()
+	if err := ReadFile(filename, loaded); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	strings_want := map[string]string{"string": "hello", "negative": "-42", "counter": "1099511627776", "expiring": "soon"}
+	for key, want := range strings_want {
+		value, _ := loaded.Get(key)
+		if got, _ := resp.ToString(value); got != want {
+			t.Errorf("Expected %s to be %s\nGot: %v", key, want, value)
+		}
+	}
+	if expiry, _ := loaded.Expiry("expiring"); expiry != future {
+		t.Errorf("Expected: %d\nGot: %d", future, expiry)
+	}
+
+	value, _ := loaded.Get("list")
+	if got := value.(*core.List).Range(0, -1); !equalSlices(got, []string{"a", "b", "c"}) {
+		t.Errorf("Expected: [a b c]\nGot: %v", got)
+	}
+	value, _ = loaded.Get("set")
+	if set := value.(*core.Set); set.Len() != 2 || !set.Contains("x") || !set.Contains("y") {
+		t.Errorf("Expected: [x y]\nGot: %v", set.Members())
+	}
+
+	value, _ = loaded.Get("hash")
+	loaded_hash := value.(*core.Hash)
+	if got, _ := loaded_hash.Get("field"); got != "value" {
+		t.Errorf("Expected: value\nGot: %s", got)
+	}
+	if _, ok := loaded_hash.FieldExpiry("field"); ok {
+		t.Errorf("Expected field to have no expiry")
+	}
+	if got, _ := loaded_hash.FieldExpiry("temporary"); got != future+123 {
+		t.Errorf("Expected: %d\nGot: %d", future+123, got)
+	}
+
+	value, _ = loaded.Get("zset")
+	entries := value.(*core.SortedSet).Entries()
+	want_entries := []core.ZEntry{{Member: "low", Score: -2.5}, {Member: "one", Score: 1}, {Member: "pi", Score: 3.14159}}
+	if len(entries) != len(want_entries) {
+		t.Fatalf("Expected: %v\nGot: %v", want_entries, entries)
+	}
+	for i := range entries {
+		if entries[i] != want_entries[i] {
+			t.Errorf("Expected: %v\nGot: %v", want_entries[i], entries[i])
+		}
+	}
+
+	value, _ = loaded.Get("stream")
+	loaded_stream := value.(*resp.Stream)
+	if len(loaded_stream.Entries) != len(stream.Entries) {
+		t.Fatalf("Expected %d stream entries, got %d", len(stream.Entries), len(loaded_stream.Entries))
+	}
+	for i, entry := range loaded_stream.Entries {
+		original := stream.Entries[i]
+		if entry.Id != original.Id || len(entry.Data) != len(original.Data) || entry.Data["n"] != original.Data["n"] {
+			t.Errorf("Expected: %v\nGot: %v", original, entry)
+		}
+	}
+
+	if loaded.Len() != store.Len() {
+		t.Errorf("Expected %d keys in database 0, got %d", store.Len(), loaded.Len())
+	}
+	if value, _ := loaded.DB(5).Get("other"); value != resp.BulkString("db5") {
+		t.Errorf("Expected the key of database 5 to stay there, got %v", value)
+	}
+}
+
+func TestWriteThenReadStreamGroups(t *testing.T) {
+	store := new(core.Store)
+	store.Init()
+
+	stream := &resp.Stream{}
+	stream.AddEntry("1-1", map[string]resp.Object{"a": resp.BulkString("1")})
+	stream.AddEntry("2-1", map[string]resp.Object{"a": resp.BulkString("2")})
+	stream.AddEntry("3-1", map[string]resp.Object{"a": resp.BulkString("3")})
+	stream.LastID = "5-0"
+	stream.MaxDeletedID = "4-0"
+	stream.EntriesAdded = 5
+	stream.Groups = []*resp.StreamGroup{
+		{
+			Name:        "workers",
+			LastID:      "3-1",
+			EntriesRead: 3,
+			Pending: []resp.StreamPendingEntry{
+				{Id: "3-1", Consumer: "bob", DeliveryTime: 1700000000300, DeliveryCount: 1},
+				{Id: "1-1", Consumer: "alice", DeliveryTime: 1700000000100, DeliveryCount: 4},
+				{Id: "2-1", Consumer: "alice", DeliveryTime: 1700000000200, DeliveryCount: 2},
+			},
+			Consumers: []resp.StreamConsumer{
+				{Name: "alice", SeenTime: 1700000001000, ActiveTime: 1700000000900},
+				{Name: "bob", SeenTime: 1700000002000, ActiveTime: 1700000002000},
+				{Name: "idle", SeenTime: 1700000003000, ActiveTime: 1700000000000},
+			},
+		},
+		{Name: "fresh", LastID: "0-0", EntriesRead: -1},
+	}
+	store.Set("stream", stream)
+
+	filename := filepath.Join(t.TempDir(), "dump.rdb")
+	if err := os.WriteFile(filename, GenerateFile(store), 0o644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	loaded := new(core.Store)
+	loaded.Init()
+	if err := ReadFile(filename, loaded); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	value, _ := loaded.Get("stream")
+	got := value.(*resp.Stream)
+	if got.LastID != "5-0" || got.MaxDeletedID != "4-0" || got.EntriesAdded != 5 || len(got.Entries) != 3 {
+		t.Errorf("Expected: last id 5-0, max deleted id 4-0, 5 entries added, 3 entries\nGot: %s, %s, %d, %d", got.LastID, got.MaxDeletedID, got.EntriesAdded, len(got.Entries))
+	}
+	if len(got.Groups) != 2 {
+		t.Fatalf("Expected 2 groups, got %d", len(got.Groups))
+	}
+
+	workers := got.Groups[0]
+	if workers.Name != "workers" || workers.LastID != "3-1" || workers.EntriesRead != 3 {
+		t.Errorf("Expected: workers, 3-1, 3\nGot: %s, %s, %d", workers.Name, workers.LastID, workers.EntriesRead)
+	}
+	// The pending entries are stored sorted by id.
+	want_pending := []resp.StreamPendingEntry{
+		{Id: "1-1", Consumer: "alice", DeliveryTime: 1700000000100, DeliveryCount: 4},
+		{Id: "2-1", Consumer: "alice", DeliveryTime: 1700000000200, DeliveryCount: 2},
+		{Id: "3-1", Consumer: "bob", DeliveryTime: 1700000000300, DeliveryCount: 1},
+	}
+	if len(workers.Pending) != len(want_pending) {
+		t.Fatalf("Expected: %v\nGot: %v", want_pending, workers.Pending)
+	}
+	for i := range want_pending {
+		if workers.Pending[i] != want_pending[i] {
+			t.Errorf("Expected: %v\nGot: %v", want_pending[i], workers.Pending[i])
+		}
+	}
+	if len(workers.Consumers) != 3 {
+		t.Fatalf("Expected 3 consumers, got %v", workers.Consumers)
+	}
+	for i, consumer := range stream.Groups[0].Consumers {
+		if workers.Consumers[i] != consumer {
+			t.Errorf("Expected: %v\nGot: %v", consumer, workers.Consumers[i])
+		}
+	}
+
+	fresh := got.Groups[1]
+	if fresh.Name != "fresh" || fresh.LastID != "0-0" || fresh.EntriesRead != -1 || len(fresh.Pending) != 0 || len(fresh.Consumers) != 0 {
+		t.Errorf("Expected: an empty group fresh with an unknown entries read count\nGot: %+v", fresh)
+	}
+}
+
+func TestWriteSkipsExpiredKeys(t *testing.T) {
+	store := new(core.Store)
+	store.Init()
+	past := time.Now().Add(-time.Hour).UnixMilli()
+
+	store.Set("kept", resp.BulkString("value"))
+	store.SetWithAbsoluteExpiry("expired", resp.BulkString("value"), uint64(past))
+	hash := core.NewHash()
+	hash.Set("field", "value")
+	hash.SetFieldExpiry("field", past)
+	store.Set("hash", hash)
+	store.DB(1).SetWithAbsoluteExpiry("expired", resp.BulkString("value"), uint64(past))
+
+	data := GenerateFile(store)
+	// Database 0 holds a single key, without an expiry.
+	resize := []byte{opCodes.SELECTDB, 0, opCodes.RESIZEDB, 1, 0}
+	if !bytes.Contains(data, resize) {
+		t.Errorf("Expected the RESIZEDB hint %v\nGot: %v", resize, data)
+	}
+	if bytes.Contains(data, []byte{opCodes.SELECTDB, 1}) {
+		t.Errorf("Expected database 1 not to be written\nGot: %v", data)
+	}
+
+	var keys []string
+	scanner := Scanner{Key: func(entry Entry) error {
+		keys = append(keys, entry.Key)
+		return nil
+	}}
+	if err := scanner.Scan(bytes.NewReader(data)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !equalSlices(keys, []string{"kept"}) {
+		t.Errorf("Expected: [kept]\nGot: %v", keys)
+	}
+	if _, ok := store.Get("hash"); ok {
+		t.Errorf("Expected the emptied hash to be deleted")
+	}
+}
@@ -0,0 +1,126 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+)
+
+// A ziplist is the serialized list that preceded listpacks, still found in
+// RDB files written before Redis 7. It starts with its total size in bytes,
+// the offset of its last element and its number of elements, and ends with
+// 0xFF. Each element is the length of the previous element, its encoding
+// and its data.
+
+const ziplistEnd = 0xFF
+
+var errMalformedZiplist = errors.New("malformed ziplist")
+
+// decodeZiplist returns the elements of a ziplist, with integers formatted
+// as decimal strings.
+func decodeZiplist(data []byte) ([]string, error) {
+	if len(data) < 11 || int(binary.LittleEndian.Uint32(data)) != len(data) {
+		return nil, errMalformedZiplist
+	}
+
+	elements := []string{}
+	current := 10
+	for current < len(data) && data[current] != ziplistEnd {
+		// The length of the previous element, only needed to walk backwards.
+		if data[current] == 0xFE {
+			current += 5
+		} else {
+			current++
+		}
+		if current >= len(data) {
+			return nil, errMalformedZiplist
+		}
+
+		element, n, err := decodeZiplistElement(data[current:])
+		if err != nil {
+			return nil, err
+		}
+		current += n
+		elements = append(elements, element)
+	}
+	if current != len(data)-1 {
+		return nil, errMalformedZiplist
+	}
+	return elements, nil
+}
+
+// decodeZiplistElement decodes the element at the start of data, returning
+// it along with the size of its encoding and data.
+func decodeZiplistElement(data []byte) (string, int, error) {
+	need := func(n int) bool { return len(data) >= n }
+	encoding := data[0]
+
+	switch encoding >> 6 {
+	case 0b00:
+		size := int(encoding & 0x3F)
+		if !need(1 + size) {
+			return "", 0, errMalformedZiplist
+		}
+		return string(data[1 : 1+size]), 1 + size, nil
+	case 0b01:
+		if !need(2) {
+			return "", 0, errMalformedZiplist
+		}
+		size := int(encoding&0x3F)<<8 | int(data[1])
+		if !need(2 + size) {
+			return "", 0, errMalformedZiplist
+		}
+		return string(data[2 : 2+size]), 2 + size, nil
+	case 0b10:
+		if !need(5) {
+			return "", 0, errMalformedZiplist
+		}
+		size := int(binary.BigEndian.Uint32(data[1:]))
+		if size < 0 || !need(5+size) {
+			return "", 0, errMalformedZiplist
+		}
+		return string(data[5 : 5+size]), 5 + size, nil
+	}
+
+	var value int64
+	var n int
+	switch encoding {
+	case 0xC0:
+		n = 3
+		if need(n) {
+			value = int64(int16(binary.LittleEndian.Uint16(data[1:])))
+		}
+	case 0xD0:
+		n = 5
+		if need(n) {
+			value = int64(int32(binary.LittleEndian.Uint32(data[1:])))
+		}
+	case 0xE0:
+		n = 9
+		if need(n) {
+			value = int64(binary.LittleEndian.Uint64(data[1:]))
+		}
+	case 0xF0:
+		n = 4
+		if need(n) {
+			unsigned := uint32(data[1]) | uint32(data[2])<<8 | uint32(data[3])<<16
+			value = int64(int32(unsigned<<8) >> 8)
+		}
+	case 0xFE:
+		n = 2
+		if need(n) {
+			value = int64(int8(data[1]))
+		}
+	default:
+		// Integers from 0 to 12 are stored in the encoding itself, plus one.
+		immediate := encoding & 0x0F
+		if encoding&0xF0 != 0xF0 || immediate < 1 || immediate > 13 {
+			return "", 0, errMalformedZiplist
+		}
+		return strconv.Itoa(int(immediate) - 1), 1, nil
+	}
+	if !need(n) {
+		return "", 0, errMalformedZiplist
+	}
+	return strconv.FormatInt(value, 10), n, nil
+}
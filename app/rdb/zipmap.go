@@ -0,0 +1,68 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// A zipmap is the serialized hash of RDB files written before Redis 2.6. It
+// starts with its number of pairs, or 254 or more when it has to be counted,
+// and ends with 0xFF. Each pair is the length and data of the field, then
+// the length of the value, the number of free bytes following it and its
+// data. Lengths below 254 are stored on one byte, longer ones as 254
+// followed by the length on 4 bytes.
+
+const (
+	zipmapBigLength = 0xFE
+	zipmapEnd       = 0xFF
+)
+
+var errMalformedZipmap = errors.New("malformed zipmap")
+
+// decodeZipmap returns the fields and values of a zipmap, alternating.
+func decodeZipmap(data []byte) ([]string, error) {
+	if len(data) < 2 {
+		return nil, errMalformedZipmap
+	}
+
+	elements := []string{}
+	current := 1
+	for current < len(data) && data[current] != zipmapEnd {
+		for _, has_free := range []bool{false, true} {
+			length, n, err := decodeZipmapLength(data[current:])
+			if err != nil {
+				return nil, err
+			}
+			current += n
+			free := 0
+			if has_free {
+				if current >= len(data) {
+					return nil, errMalformedZipmap
+				}
+				free = int(data[current])
+				current++
+			}
+			if length > len(data)-current {
+				return nil, errMalformedZipmap
+			}
+			elements = append(elements, string(data[current:current+length]))
+			current += length + free
+		}
+	}
+	if current != len(data)-1 {
+		return nil, errMalformedZipmap
+	}
+	return elements, nil
+}
+
+func decodeZipmapLength(data []byte) (int, int, error) {
+	switch {
+	case len(data) == 0 || data[0] == zipmapEnd:
+		return 0, 0, errMalformedZipmap
+	case data[0] < zipmapBigLength:
+		return int(data[0]), 1, nil
+	case len(data) < 5:
+		return 0, 0, errMalformedZipmap
+	}
+	return int(binary.LittleEndian.Uint32(data[1:])), 5, nil
+}
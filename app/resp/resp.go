@@ -15,6 +15,7 @@ type Integer int
 type BulkString string
 type NullBulkString struct{}
 type Array []Object
+type NullArray struct{}
 type Set map[Object]struct{}
 type Map map[Object]Object
 type Boolean bool
@@ -24,6 +25,45 @@ type Stream struct {
 		Id   string
 		Data map[string]Object
 	}
+	// LastID is the id of the last entry ever added, which new entries must
+	// be greater than even if it was deleted.
+	LastID string
+	// MaxDeletedID is the largest id of the deleted entries, or empty if
+	// none was deleted.
+	MaxDeletedID string
+	// EntriesAdded counts the entries ever added.
+	EntriesAdded uint64
+	Groups       []*StreamGroup
+}
+
+// StreamGroup is a consumer group of a stream, with the entries delivered
+// to its consumers and not acknowledged yet, ordered by id.
+type StreamGroup struct {
+	Name   string
+	LastID string
+	// EntriesRead is the number of entries the group read, or -1 if unknown.
+	EntriesRead int64
+	Pending     []StreamPendingEntry
+	Consumers   []StreamConsumer
+}
+
+// StreamPendingEntry is an entry delivered to a consumer of a group and not
+// acknowledged yet.
+type StreamPendingEntry struct {
+	Id       string
+	Consumer string
+	// DeliveryTime is the unix time in milliseconds of the last delivery.
+	DeliveryTime  int64
+	DeliveryCount uint64
+}
+
+// StreamConsumer is a consumer of a group. SeenTime and ActiveTime are the
+// unix times in milliseconds of its last interaction and of its last
+// successful read.
+type StreamConsumer struct {
+	Name       string
+	SeenTime   int64
+	ActiveTime int64
 }
 
 func (r SimpleString) Encode() []byte {
@@ -45,10 +85,6 @@ func (r SimpleError) Encode() []byte {
 func (r Integer) Encode() []byte {
 	ret := make([]byte, 0)
 	ret = append(ret, ':')
-	if r < 0 {
-		ret = append(ret, '-')
-		r = -r
-	}
 	ret = append(ret, strconv.Itoa(int(r))...)
 	ret = append(ret, "\r\n"...)
 	return ret
@@ -68,6 +104,10 @@ func (r NullBulkString) Encode() []byte {
 	return []byte("$-1\r\n")
 }
 
+func (r NullArray) Encode() []byte {
+	return []byte("*-1\r\n")
+}
+
 func (r Array) Encode() []byte {
 	ret := make([]byte, 0)
 	ret = append(ret, '*')
@@ -114,10 +154,35 @@ func (r Map) Encode() []byte {
 	return ret
 }
 
-func (r Stream) Encode() []byte {
+func (r *Stream) Encode() []byte {
 	return nil
 }
 
+// Copy returns a stream with the same entries that shares no memory with r.
+func (r *Stream) Copy() *Stream {
+	r.Mu.Lock()
+	defer r.Mu.Unlock()
+
+	copied := &Stream{}
+	for _, entry := range r.Entries {
+		data := make(map[string]Object, len(entry.Data))
+		for key, value := range entry.Data {
+			data[key] = value
+		}
+		copied.AddEntry(entry.Id, data)
+	}
+	copied.LastID = r.LastID
+	copied.MaxDeletedID = r.MaxDeletedID
+	copied.EntriesAdded = r.EntriesAdded
+	for _, group := range r.Groups {
+		copied_group := *group
+		copied_group.Pending = append([]StreamPendingEntry{}, group.Pending...)
+		copied_group.Consumers = append([]StreamConsumer{}, group.Consumers...)
+		copied.Groups = append(copied.Groups, &copied_group)
+	}
+	return copied
+}
+
 func (r *Stream) AddEntry(id string, data map[string]Object) {
 	r.Entries = append(r.Entries, struct {
 		Id   string
@@ -126,6 +191,8 @@ func (r *Stream) AddEntry(id string, data map[string]Object) {
 		Id:   id,
 		Data: data,
 	})
+	r.LastID = id
+	r.EntriesAdded++
 }
 
 func StringsToArray(arr []string) Array {
@@ -262,10 +329,17 @@ func decodeBulkString(in <-chan byte) (int, BulkString) {
 		return n, ""
 	}
 
-	nn, str := decodeSimpleString(in)
-	n += nn
+	// Bulk strings are binary safe, so the payload is read by length rather
+	// than up to the next CRLF.
+	buf := make([]byte, length)
+	for i := range buf {
+		buf[i] = <-in
+	}
+	<-in
+	<-in
+	n += int(length) + 2
 
-	return n, BulkString(str)
+	return n, BulkString(buf)
 }
 
 func decodeArray(in <-chan byte) (int, Array) {
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"math/rand"
 	"net"
 	"os"
@@ -11,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/codecrafters-io/redis-starter-go/app/aof"
 	"github.com/codecrafters-io/redis-starter-go/app/commands"
 	"github.com/codecrafters-io/redis-starter-go/app/core"
 	"github.com/codecrafters-io/redis-starter-go/app/rdb"
@@ -18,24 +20,57 @@ import (
 )
 
 type serverFlags struct {
-	dir        string
-	dbfilename string
-	port       string
-	replicaof  string
+	dir                  string
+	dbfilename           string
+	port                 string
+	replicaof            string
+	active_expire_effort int
+	databases            int
+	save                 string
+	appendonly           string
+	appendfilename       string
+	appendfsync          string
+	aof_load_truncated   string
+	appenddirname        string
+	auto_aof_rewrite_pct int
+	auto_aof_rewrite_min string
+	rdbchecksum          string
 }
 
 func main() {
 	dir_ptr := flag.String("dir", "", "the directory of the RDB config file")
-	dbfilename_ptr := flag.String("dbfilename", "", "the name of the RDB config file")
+	dbfilename_ptr := flag.String("dbfilename", "dump.rdb", "the name of the RDB config file")
 	port_ptr := flag.String("port", "6379", "the port to run the server on")
 	replicaof_ptr := flag.String("replicaof", "", "indicate if the server is a replica of another. In the form of '<MASTER_HOST> <MASTER_PORT>'")
+	active_expire_effort_ptr := flag.Int("active-expire-effort", 1, "the effort, from 1 to 10, spent on evicting expired keys in the background")
+	databases_ptr := flag.Int("databases", core.DefaultDatabases, "the number of logical databases")
+	save_ptr := flag.String("save", "3600 1 300 100 60 10000", "the save points, as '<seconds> <changes>' pairs, or '' to disable snapshotting")
+	appendonly_ptr := flag.String("appendonly", "no", "whether to log every write to the append only file, yes or no")
+	appendfilename_ptr := flag.String("appendfilename", "appendonly.aof", "the name of the append only file")
+	appendfsync_ptr := flag.String("appendfsync", "everysec", "when to flush the append only file to disk: always, everysec or no")
+	aof_load_truncated_ptr := flag.String("aof-load-truncated", "yes", "whether to load an append only file that ends in the middle of a command, yes or no")
+	appenddirname_ptr := flag.String("appenddirname", "appendonlydir", "the name of the directory, within --dir, holding the parts of the append only file")
+	auto_aof_rewrite_pct_ptr := flag.Int("auto-aof-rewrite-percentage", 100, "rewrite the append only file once it grew by this percentage since the last rewrite, or 0 to disable automatic rewrites")
+	auto_aof_rewrite_min_ptr := flag.String("auto-aof-rewrite-min-size", "64mb", "the size the append only file must reach before it is rewritten automatically")
+	rdbchecksum_ptr := flag.String("rdbchecksum", "yes", "whether to end RDB files with a checksum and verify it when loading them, yes or no")
 	flag.Parse()
 
 	err := startServer(serverFlags{
-		dir:        *dir_ptr,
-		dbfilename: *dbfilename_ptr,
-		port:       *port_ptr,
-		replicaof:  *replicaof_ptr,
+		dir:                  *dir_ptr,
+		dbfilename:           *dbfilename_ptr,
+		port:                 *port_ptr,
+		replicaof:            *replicaof_ptr,
+		active_expire_effort: *active_expire_effort_ptr,
+		databases:            *databases_ptr,
+		save:                 *save_ptr,
+		appendonly:           *appendonly_ptr,
+		appendfilename:       *appendfilename_ptr,
+		appendfsync:          *appendfsync_ptr,
+		aof_load_truncated:   *aof_load_truncated_ptr,
+		appenddirname:        *appenddirname_ptr,
+		auto_aof_rewrite_pct: *auto_aof_rewrite_pct_ptr,
+		auto_aof_rewrite_min: *auto_aof_rewrite_min_ptr,
+		rdbchecksum:          *rdbchecksum_ptr,
 	}, nil)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
@@ -44,6 +79,60 @@ func main() {
 }
 
 func startServer(flags serverFlags, stop <-chan struct{}) error {
+	if flags.active_expire_effort == 0 {
+		flags.active_expire_effort = 1
+	}
+	if flags.active_expire_effort < 1 || flags.active_expire_effort > 10 {
+		return fmt.Errorf("--active-expire-effort must be between 1 and 10")
+	}
+	if flags.databases == 0 {
+		flags.databases = core.DefaultDatabases
+	}
+	if flags.databases < 1 {
+		return fmt.Errorf("--databases must be at least 1")
+	}
+	if flags.dbfilename == "" {
+		flags.dbfilename = "dump.rdb"
+	}
+	save_points, err := rdb.ParseSavePoints(flags.save)
+	if err != nil {
+		return fmt.Errorf("--save: %w", err)
+	}
+	appendonly, err := parseYesNo(flags.appendonly, false)
+	if err != nil {
+		return fmt.Errorf("--appendonly: %w", err)
+	}
+	if flags.appendfilename == "" {
+		flags.appendfilename = "appendonly.aof"
+	}
+	if flags.appendfsync == "" {
+		flags.appendfsync = string(aof.FsyncEverySec)
+	}
+	fsync, err := aof.ParseFsync(flags.appendfsync)
+	if err != nil {
+		return fmt.Errorf("--appendfsync: %w", err)
+	}
+	aof_load_truncated, err := parseYesNo(flags.aof_load_truncated, true)
+	if err != nil {
+		return fmt.Errorf("--aof-load-truncated: %w", err)
+	}
+	if flags.appenddirname == "" {
+		flags.appenddirname = "appendonlydir"
+	}
+	if flags.auto_aof_rewrite_pct < 0 {
+		return fmt.Errorf("--auto-aof-rewrite-percentage must not be negative")
+	}
+	if flags.auto_aof_rewrite_min == "" {
+		flags.auto_aof_rewrite_min = "64mb"
+	}
+	auto_aof_rewrite_min, err := parseMemory(flags.auto_aof_rewrite_min)
+	if err != nil {
+		return fmt.Errorf("--auto-aof-rewrite-min-size: %w", err)
+	}
+	rdbchecksum, err := parseYesNo(flags.rdbchecksum, true)
+	if err != nil {
+		return fmt.Errorf("--rdbchecksum: %w", err)
+	}
 
 	l, err := net.Listen("tcp", "0.0.0.0:"+flags.port)
 	if err != nil {
@@ -51,17 +140,53 @@ func startServer(flags serverFlags, stop <-chan struct{}) error {
 	}
 	defer l.Close()
 
-	var store *core.Store
-	store = new(core.Store)
-	store.Init()
-	rdb_file := filepath.Join(flags.dir, flags.dbfilename)
-
-	store, err = rdb.ReadFile(rdb_file)
-	if err != nil {
-		return err
-	}
+	store := new(core.Store)
+	store.InitWithDatabases(flags.databases)
 	store.SetParam("dir", flags.dir)
 	store.SetParam("dbfilename", flags.dbfilename)
+	store.SetParam("save", flags.save)
+	store.SetParam("appendonly", formatYesNo(appendonly))
+	store.SetParam("appendfilename", flags.appendfilename)
+	store.SetParam("appendfsync", flags.appendfsync)
+	store.SetParam("aof-load-truncated", formatYesNo(aof_load_truncated))
+	store.SetParam("appenddirname", flags.appenddirname)
+	store.SetParam("auto-aof-rewrite-percentage", strconv.Itoa(flags.auto_aof_rewrite_pct))
+	store.SetParam("auto-aof-rewrite-min-size", strconv.FormatInt(auto_aof_rewrite_min, 10))
+	store.SetParam("rdbchecksum", formatYesNo(rdbchecksum))
+	store.SetParam("active-expire-effort", strconv.Itoa(flags.active_expire_effort))
+	store.SetParam("databases", strconv.Itoa(flags.databases))
+
+	// The append only file is more up to date than the snapshot, so it is
+	// the one loaded when enabled. Without one yet, the snapshot is loaded
+	// and becomes the base of the append only file.
+	loaded := false
+	aof_dir := filepath.Join(flags.dir, flags.appenddirname)
+	if appendonly {
+		legacy_file := filepath.Join(flags.dir, flags.appendfilename)
+		if err := aof.UpgradeLegacyFile(legacy_file, aof_dir, flags.appendfilename); err != nil {
+			return err
+		}
+		loaded, err = aof.Load(aof_dir, flags.appendfilename, store, aof_load_truncated)
+		if err != nil {
+			return err
+		}
+		store.ClearDirty()
+	}
+	if !loaded {
+		if err := rdb.ReadFile(rdb.Filename(store), store); err != nil {
+			return err
+		}
+	}
+	var log *aof.File
+	if appendonly {
+		log, err = aof.Open(aof_dir, flags.appendfilename, fsync, store)
+		if err != nil {
+			return err
+		}
+		defer log.Close()
+		store.SetAppendOnlyLog(log)
+		go log.RunFsync(stop)
+	}
 
 	if flags.replicaof != "" {
 		strs := strings.Split(flags.replicaof, " ")
@@ -78,6 +203,16 @@ func startServer(flags serverFlags, stop <-chan struct{}) error {
 		store.SetParam("master_repl_offset", "0")
 	}
 
+	// The background jobs read the parameters, so they only start once all
+	// of them are set.
+	if log != nil {
+		go aof.RunAutoRewrite(store, log, int64(flags.auto_aof_rewrite_pct), auto_aof_rewrite_min, stop)
+	}
+	go store.RunActiveExpiry(stop)
+	if len(save_points) > 0 {
+		go rdb.RunSavePoints(store, save_points, stop)
+	}
+
 	for {
 		select {
 		case <-stop:
@@ -104,21 +239,35 @@ func handleConnection(conn net.Conn, store *core.Store) {
 func acceptCommands(conn *core.Conn, store *core.Store) {
 	for {
 		n, response := resp.Decode(conn.ByteChan)
+		if response == nil {
+			select {
+			case <-conn.Closed:
+				return
+			default:
+				continue
+			}
+		}
 		fmt.Printf("decoded %d bytes from %v\n", n, conn.Conn.RemoteAddr())
 
 		call := commands.GetRespArrayCall(response)
 
+		// Commands run one at a time, as in Redis, so that blocking list
+		// commands and the commands serving them see consistent keys. The
+		// handlers propagate their own writes to the replicas.
+		store.Lock()
 		res := commands.HandleCommand(call, conn, store)
-		if res != nil {
-			conn.Write(res.Encode())
-		}
+		store.ServeBlockedClients()
+		store.Unlock()
 
+		// Commands propagated by the master are applied silently, except for
+		// the REPLCONF GETACK requests the master expects an answer to.
+		from_master := store.Master != nil && conn.Conn == store.Master.Conn
 		command_name, _ := commands.GetCommandName(call)
-		if command_name == "SET" {
-			store.PropagateToReplicas(call)
+		if res != nil && (!from_master || command_name == "REPLCONF") {
+			conn.Write(res.Encode())
 		}
 
-		if store.Master != nil && conn.Conn == store.Master.Conn {
+		if from_master {
 			conn.Mu.Lock()
 			conn.Offset += n
 			conn.Mu.Unlock()
@@ -126,6 +275,55 @@ func acceptCommands(conn *core.Conn, store *core.Store) {
 	}
 }
 
+// parseYesNo parses a yes or no configuration value, empty meaning
+// default_value.
+func parseYesNo(value string, default_value bool) (bool, error) {
+	switch strings.ToLower(value) {
+	case "":
+		return default_value, nil
+	case "yes":
+		return true, nil
+	case "no":
+		return false, nil
+	}
+	return false, fmt.Errorf("expected yes or no, got %q", value)
+}
+
+func formatYesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+// parseMemory parses a number of bytes, optionally followed by a unit such as
+// k, kb, m, mb, g or gb. As in Redis, k is 1000 bytes and kb 1024 bytes.
+func parseMemory(value string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+		{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000},
+		{"b", 1},
+	}
+
+	lower := strings.ToLower(value)
+	var multiplier int64 = 1
+	for _, unit := range units {
+		if strings.HasSuffix(lower, unit.suffix) {
+			lower = strings.TrimSuffix(lower, unit.suffix)
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	size, err := strconv.ParseInt(lower, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid memory size %q", value)
+	}
+	return size * multiplier, nil
+}
+
 var seededRand *rand.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
 
 func generateRandomID(length int) string {
@@ -193,9 +391,21 @@ func performMasterHandshake(listening_port string, master_ip_port string, store
 		os.Exit(1)
 	}
 	_, raw_int := resp.DecodeInteger(master_conn.ByteChan)
-	n := int(raw_int)
-	for i := 0; i < n; i++ {
-		<-master_conn.ByteChan
+	// The snapshot replaces the data of the replica. It is decoded as it is
+	// received, stopping at its end, after which the master propagates its
+	// commands.
+	snapshot := io.LimitReader(master_conn.Reader(), int64(raw_int))
+	store.Lock()
+	store.FlushAll()
+	err = rdb.Read(snapshot, store)
+	store.Unlock()
+	if err == nil {
+		// Anything after the checksum is not part of the data.
+		_, err = io.Copy(io.Discard, snapshot)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load the RDB file sent by the master: %v\n", err)
+		os.Exit(1)
 	}
 
 	go acceptCommands(master_conn, store)